@@ -0,0 +1,145 @@
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+)
+
+// joseContentType is the content type of the JWS-signed requests sent to the
+// ACME server.
+const joseContentType = "application/jose+json"
+
+// expandPKCS11AccountKey returns the account key for the key referenced by
+// account_key_pkcs11_uri, along with the signer for the key in the token.
+//
+// lego only signs requests with an *rsa.PrivateKey or *ecdsa.PrivateKey held
+// in memory, and uses the same key to compute key authorizations. The key
+// returned here is an ECDSA key with the public key from the token and a
+// placeholder private scalar: lego uses the public key as is, and the
+// signatures it makes with the placeholder are replaced using the token by
+// pkcs11JWSTransport before the requests are sent. Only ECDSA keys on P-256
+// and P-384 are supported, as these are the only account keys that lego
+// supports other than RSA.
+func expandPKCS11AccountKey(uri string) (*ecdsa.PrivateKey, crypto.Signer, error) {
+	signer, err := pkcs11SignerForURI(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pub, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok || (pub.Curve != elliptic.P256() && pub.Curve != elliptic.P384()) {
+		return nil, nil, errors.New("account keys in a PKCS#11 token must be ECDSA keys on the P-256 or P-384 curve")
+	}
+
+	return &ecdsa.PrivateKey{PublicKey: *pub, D: big.NewInt(1)}, signer, nil
+}
+
+// pkcs11JWSTransport is an http.RoundTripper that signs the JWS in the body
+// of each request to the ACME server again with a key in a PKCS#11 token. See
+// expandPKCS11AccountKey.
+type pkcs11JWSTransport struct {
+	base   http.RoundTripper
+	signer crypto.Signer
+}
+
+func (t *pkcs11JWSTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Header.Get("Content-Type") != joseContentType {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err = signJWS(body, t.signer)
+	if err != nil {
+		return nil, fmt.Errorf("error signing request with PKCS#11 account key: %w", err)
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// signJWS replaces the signature of a JWS in the flattened JSON serialization
+// with one made by signer, which must hold an ECDSA key. The protected header
+// and payload are kept as they are.
+func signJWS(body []byte, signer crypto.Signer) ([]byte, error) {
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(body, &jws); err != nil {
+		return nil, fmt.Errorf("error reading JWS: %w", err)
+	}
+
+	protected, err := base64.RawURLEncoding.DecodeString(jws.Protected)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JWS protected header: %w", err)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(protected, &header); err != nil {
+		return nil, fmt.Errorf("error reading JWS protected header: %w", err)
+	}
+
+	var hash crypto.Hash
+	switch header.Alg {
+	case "ES256":
+		hash = crypto.SHA256
+	case "ES384":
+		hash = crypto.SHA384
+	default:
+		return nil, fmt.Errorf("unsupported JWS algorithm %q", header.Alg)
+	}
+
+	pub, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T", signer.Public())
+	}
+
+	h := hash.New()
+	h.Write([]byte(jws.Protected + "." + jws.Payload))
+	der, err := signer.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// JWS uses the fixed-size r || s encoding of ECDSA signatures (RFC 7518,
+	// section 3.4), rather than the ASN.1 encoding returned by crypto.Signer.
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("error reading ECDSA signature: %w", err)
+	}
+
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+
+	jws.Signature = base64.RawURLEncoding.EncodeToString(raw)
+	return json.Marshal(jws)
+}
@@ -0,0 +1,103 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+func TestPKCS11JWSTransport(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384()} {
+		t.Run(curve.Params().Name, func(t *testing.T) {
+			// The key stands in for the key in the token, and the placeholder
+			// is the one returned by expandPKCS11AccountKey.
+			key, err := ecdsa.GenerateKey(curve, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			placeholder := &ecdsa.PrivateKey{PublicKey: key.PublicKey, D: big.NewInt(1)}
+			alg := jose.ES256
+			if curve == elliptic.P384() {
+				alg = jose.ES384
+			}
+
+			var received []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received, _ = io.ReadAll(r.Body)
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: &pkcs11JWSTransport{base: http.DefaultTransport, signer: key}}
+			signer, err := jose.NewSigner(
+				jose.SigningKey{Algorithm: alg, Key: jose.JSONWebKey{Key: placeholder}},
+				&jose.SignerOptions{EmbedJWK: true},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signed, err := signer.Sign([]byte(`{"termsOfServiceAgreed":true}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body := signed.FullSerialize()
+			if _, err := signed.Verify(&key.PublicKey); err == nil {
+				t.Fatal("expected the placeholder signature not to verify")
+			}
+
+			resp, err := client.Post(srv.URL, joseContentType, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			jws, err := jose.ParseSigned(string(received), []jose.SignatureAlgorithm{alg})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload, err := jws.Verify(&key.PublicKey)
+			if err != nil {
+				t.Fatalf("expected the signature to verify with the key: %s", err)
+			}
+
+			if string(payload) != `{"termsOfServiceAgreed":true}` {
+				t.Fatalf("expected payload to be kept, got %q", payload)
+			}
+
+			// Other requests are sent as they are.
+			resp, err = client.Post(srv.URL, "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if string(received) != body {
+				t.Fatalf("expected body to be unchanged, got %q", received)
+			}
+		})
+	}
+}
+
+func TestSignJWSUnsupportedAlgorithm(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// {"alg":"RS256"}
+	_, err = signJWS([]byte(`{"protected":"eyJhbGciOiJSUzI1NiJ9","payload":"","signature":""}`), key)
+	if err == nil || !strings.Contains(err.Error(), `unsupported JWS algorithm "RS256"`) {
+		t.Fatalf("expected unsupported algorithm error, got %v", err)
+	}
+}
@@ -14,14 +14,17 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
 	"github.com/go-acme/lego/v4/lego"
 	"github.com/go-acme/lego/v4/registration"
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"software.sslmate.com/src/go-pkcs12"
+	"golang.org/x/net/idna"
 )
 
 // acmeUser implements acme.User.
@@ -34,6 +37,10 @@ type acmeUser struct {
 
 	// The private key for the account.
 	key crypto.PrivateKey
+
+	// The signer for an account key held in a PKCS#11 token, in which case
+	// key is a placeholder (see expandPKCS11AccountKey).
+	signer crypto.Signer
 }
 
 func (u acmeUser) GetEmail() string {
@@ -47,16 +54,27 @@ func (u acmeUser) GetPrivateKey() crypto.PrivateKey {
 }
 
 // expandACMEUser creates a new instance of an ACME user from set
-// email_address and private_key_pem fields, and a registration
-// if one exists.
+// email_address and account_key_pem or account_key_pkcs11_uri fields, and a
+// registration if one exists.
 func expandACMEUser(d *schema.ResourceData) (*acmeUser, error) {
-	key, err := privateKeyFromPEM([]byte(d.Get("account_key_pem").(string)))
-	if err != nil {
-		return nil, err
-	}
+	user := &acmeUser{}
+
+	// only use account_key_pkcs11_uri if it's in the schema and set.
+	if v, ok := d.GetOk("account_key_pkcs11_uri"); ok {
+		key, signer, err := expandPKCS11AccountKey(v.(string))
+		if err != nil {
+			return nil, err
+		}
 
-	user := &acmeUser{
-		key: key,
+		user.key = key
+		user.signer = signer
+	} else {
+		key, err := privateKeyFromPEM([]byte(d.Get("account_key_pem").(string)))
+		if err != nil {
+			return nil, err
+		}
+
+		user.key = key
 	}
 
 	// only set these email if it's in the schema.
@@ -103,14 +121,31 @@ func expandACMEClient(d *schema.ResourceData, meta any, loadReg bool) (*lego.Cli
 	return client, user, nil
 }
 
-func expandACMEClient_config(d *schema.ResourceData, meta any, user registration.User) *lego.Config {
+func expandACMEClient_config(d *schema.ResourceData, meta any, user *acmeUser) *lego.Config {
 	config := lego.NewConfig(user)
 	config.CADirURL = meta.(*Config).ServerURL
 
+	// Requests signed with a placeholder for a PKCS#11 account key are signed
+	// again with the key in the token.
+	if user.signer != nil {
+		client := *config.HTTPClient
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+
+		client.Transport = &pkcs11JWSTransport{base: base, signer: user.signer}
+		config.HTTPClient = &client
+	}
+
 	// Note this function is used by both the registration and certificate
 	// resources, but key type is not necessary during registration, so
 	// it's okay if it's empty for that.
-	if v, ok := d.GetOk("key_type"); ok {
+	if v, ok := d.GetOk("key_types"); ok {
+		// The first entry in key_types is the primary key type, the keys for
+		// the rest are generated separately.
+		config.Certificate.KeyType = certcrypto.KeyType(v.([]any)[0].(string))
+	} else if v, ok := d.GetOk("key_type"); ok {
 		config.Certificate.KeyType = certcrypto.KeyType(v.(string))
 	}
 
@@ -129,6 +164,15 @@ type resourceDataOrDiff interface {
 	Get(string) any
 	GetOk(string) (any, bool)
 	GetChange(string) (any, any)
+	Id() string
+}
+
+// resourceDataOrDiffWithConfig is a resourceDataOrDiff that also exposes the
+// raw configuration, for checking whether values were set in configuration
+// versus computed.
+type resourceDataOrDiffWithConfig interface {
+	resourceDataOrDiff
+	GetRawConfig() cty.Value
 }
 
 // expandCertificateResource takes saved state in the certificate resource
@@ -161,7 +205,7 @@ func expandCertificateResource(d resourceDataOrDiff) *certificate.Resource {
 }
 
 // saveCertificateResource takes an certificate.Resource and sets fields.
-func saveCertificateResource(d *schema.ResourceData, cert *certificate.Resource, password string) error {
+func saveCertificateResource(d *schema.ResourceData, cert *certificate.Resource, opts certificateEncodeOptions) error {
 	d.Set("certificate_url", cert.CertURL)
 	d.Set("certificate_domain", cert.Domain)
 	d.Set("private_key_pem", string(cert.PrivateKey))
@@ -170,28 +214,106 @@ func saveCertificateResource(d *schema.ResourceData, cert *certificate.Resource,
 		return err
 	}
 
+	ips, err := certIPAddresses(issued)
+	if err != nil {
+		return err
+	}
+
+	if err := saveCertificateChainFormats(d, cert.Certificate); err != nil {
+		return err
+	}
+
 	d.Set("certificate_pem", string(issued))
+	d.Set("certificate_ip_addresses", ips)
 	d.Set("issuer_pem", string(issuer))
 	d.Set("certificate_not_before", issuedNotBefore)
 	d.Set("certificate_not_after", issuedNotAfter)
 	d.Set("certificate_serial", issuedSerial)
 
-	// Set PKCS12 data. This is only set if there is a private key
+	// Set PKCS12 and JKS data. These are only set if there is a private key
 	// present.
 	if len(cert.PrivateKey) > 0 {
-		pfxB64, err := bundleToPKCS12(cert.Certificate, cert.PrivateKey, password)
+		pfxB64, err := bundleToPKCS12(cert.Certificate, cert.PrivateKey, opts)
+		if err != nil {
+			return err
+		}
+
+		jksB64, err := bundleToJKS(cert.Certificate, cert.PrivateKey, opts.Password, opts.FriendlyName)
 		if err != nil {
 			return err
 		}
 
 		d.Set("certificate_p12", string(pfxB64))
+		d.Set("certificate_jks", string(jksB64))
 	} else {
 		d.Set("certificate_p12", "")
+		d.Set("certificate_jks", "")
 	}
 
 	return nil
 }
 
+// saveCertificateChainFormats sets the fields for the formats of the
+// certificate and its chain that do not include the private key, along with
+// the details parsed from the certificate.
+func saveCertificateChainFormats(d *schema.ResourceData, bundle []byte) error {
+	issued, _, _, _, issuer, err := splitPEMBundle(bundle)
+	if err != nil {
+		return err
+	}
+
+	certs, err := parsePEMBundle(issued)
+	if err != nil {
+		return err
+	}
+
+	scts, err := parseCertificateSCTs(certs[0])
+	if err != nil {
+		return err
+	}
+
+	p7b, err := bundleToPKCS7(bundle)
+	if err != nil {
+		return err
+	}
+
+	d.Set("certificate_pem_fullchain", string(issued)+string(issuer))
+	d.Set("certificate_der", pemToBase64DER(issued))
+	d.Set("issuer_der", pemToBase64DER(issuer))
+	d.Set("certificate_p7b", string(p7b))
+	d.Set("certificate_info", flattenCertificateInfo(certs[0]))
+	d.Set("certificate_scts", flattenCertificateSCTs(scts))
+
+	return nil
+}
+
+// pemToBase64DER returns the first block in PEM data as base64-encoded DER,
+// or an empty string if there is no PEM data.
+func pemToBase64DER(data []byte) string {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return ""
+	}
+
+	return base64.StdEncoding.EncodeToString(block.Bytes)
+}
+
+// certIPAddresses returns the IP address SANs of the first certificate in a
+// PEM bundle.
+func certIPAddresses(bundle []byte) ([]string, error) {
+	certs, err := parsePEMBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(certs[0].IPAddresses))
+	for _, ip := range certs[0].IPAddresses {
+		result = append(result, ip.String())
+	}
+
+	return result, nil
+}
+
 // certSecondsRemaining takes an certificate.Resource, parses the
 // certificate, and computes the seconds that it has remaining.
 func certSecondsRemaining(cert *certificate.Resource, now time.Time) (int64, error) {
@@ -219,8 +341,8 @@ func certDaysRemaining(cert *certificate.Resource, now time.Time) (int64, error)
 	return remaining / 86400, nil
 }
 
-// certLifetimeDays returns the total certificate lifetime in days.
-func certLifetimeDays(cert *certificate.Resource) (float64, error) {
+// certLifetime returns the total certificate lifetime.
+func certLifetime(cert *certificate.Resource) (time.Duration, error) {
 	x509Certs, err := parsePEMBundle(cert.Certificate)
 	if err != nil {
 		return 0, err
@@ -231,7 +353,7 @@ func certLifetimeDays(cert *certificate.Resource) (float64, error) {
 		return 0, fmt.Errorf("first certificate is a CA certificate")
 	}
 
-	return c.NotAfter.Sub(c.NotBefore).Round(time.Hour).Hours() / 24.0, nil
+	return c.NotAfter.Sub(c.NotBefore), nil
 }
 
 // splitPEMBundle gets a slice of x509 certificates from
@@ -276,10 +398,12 @@ func splitPEMBundle(bundle []byte) (
 
 // bundleToPKCS12 packs an issued certificate (and any supplied
 // intermediates) into a PFX file.  The private key is included in
-// the archive if it is a non-zero value.
+// the archive if it is a non-zero value. The archive is encoded with
+// the encoder for opts.P12Encoding, and the private key is given
+// opts.FriendlyName, if set.
 //
 // The returned archive is base64-encoded.
-func bundleToPKCS12(bundle, key []byte, password string) ([]byte, error) {
+func bundleToPKCS12(bundle, key []byte, opts certificateEncodeOptions) ([]byte, error) {
 	cb, err := parsePEMBundle(bundle)
 	if err != nil {
 		return nil, err
@@ -295,11 +419,22 @@ func bundleToPKCS12(bundle, key []byte, password string) ([]byte, error) {
 		return nil, err
 	}
 
-	pfxData, err := pkcs12.Modern2023.Encode(pk, cb[0], cb[1:], password)
+	encoder, err := pkcs12Encoder(opts.P12Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	pfxData, err := encoder.Encode(pk, cb[0], cb[1:], opts.Password)
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.FriendlyName != "" {
+		if pfxData, err = pkcs12SetFriendlyName(pfxData, opts.Password, opts.FriendlyName); err != nil {
+			return nil, err
+		}
+	}
+
 	buf := make([]byte, base64.StdEncoding.EncodedLen(len(pfxData)))
 	base64.StdEncoding.Encode(buf, pfxData)
 	return buf, nil
@@ -389,6 +524,61 @@ func csrFromPEM(pemData []byte) (*x509.CertificateRequest, error) {
 	}
 }
 
+// normalizeDomain returns the form of a domain name that is sent to the CA:
+// lower-cased, without a trailing dot, and with internationalized labels
+// converted to punycode (IDNA). Wildcard prefixes are preserved.
+func normalizeDomain(domain string) (string, error) {
+	result := strings.TrimSuffix(strings.ToLower(domain), ".")
+	if result == "" {
+		return "", errors.New("domain name cannot be empty")
+	}
+
+	result, err := idna.ToASCII(result)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain name %q: %w", domain, err)
+	}
+
+	return result, nil
+}
+
+// hashDomain is a schema.SchemaSetFunc for sets of domain names, which hashes
+// the normalized form of the name, so that names that only differ in case,
+// trailing dots, or IDN encoding are considered the same.
+func hashDomain(v any) int {
+	domain, err := normalizeDomain(v.(string))
+	if err != nil {
+		domain = v.(string)
+	}
+
+	return schema.HashString(domain)
+}
+
+// suppressEquivalentDomainDiff is a schema.SchemaDiffSuppressFunc that
+// suppresses diffs between domain names with the same normalized form.
+func suppressEquivalentDomainDiff(_, old, new string, _ *schema.ResourceData) bool {
+	o, err := normalizeDomain(old)
+	if err != nil {
+		return false
+	}
+
+	n, err := normalizeDomain(new)
+	if err != nil {
+		return false
+	}
+
+	return o == n
+}
+
+// validateDomain validates that a domain name can be normalized with
+// normalizeDomain.
+func validateDomain(v any, k string) (ws []string, errors []error) {
+	if _, err := normalizeDomain(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%s: %w", k, err))
+	}
+
+	return
+}
+
 // validateKeyType validates a key_type resource parameter is correct.
 func validateKeyType(v any, k string) (ws []string, errors []error) {
 	value := v.(string)
@@ -405,6 +595,37 @@ func validateKeyType(v any, k string) (ws []string, errors []error) {
 	return
 }
 
+// validateCertificatePrivateKey validates that a certificate private key is a
+// PEM-encoded RSA or ECDSA key.
+func validateCertificatePrivateKey(v any, k string) (ws []string, errors []error) {
+	key, err := privateKeyFromPEM([]byte(v.(string)))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%s: error parsing private key: %w", k, err))
+		return
+	}
+
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		errors = append(errors, fmt.Errorf("%s: private key must be an RSA or ECDSA key, got %T", k, key))
+	}
+	return
+}
+
+// validatePKCS11URI validates that a PKCS#11 URI is well-formed, and that
+// the provider was built with PKCS#11 support.
+func validatePKCS11URI(v any, k string) (ws []string, errors []error) {
+	if !pkcs11Supported {
+		errors = append(errors, fmt.Errorf("%s: %s", k, errPKCS11Unsupported))
+		return
+	}
+
+	if _, err := parsePKCS11URI(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%s: %w", k, err))
+	}
+	return
+}
+
 // validateDNSChallengeConfig ensures that the values supplied to the
 // dns_challenge resource parameter in the acme_certificate resource
 // are string values only.
@@ -446,6 +667,13 @@ const (
 	keyECDSACurveP521 = "P521"
 )
 
+// Key rotation policies for the key_rotation setting in acme_certificate.
+const (
+	keyRotationReuse      = "reuse"
+	keyRotationAlways     = "always"
+	keyRotationMaxAgeDays = "max_age_days"
+)
+
 func generatePrivateKey(algo string, rsaBits int, ecCruve string) (string, error) {
 	var privateKeyPem *pem.Block
 	switch algo {
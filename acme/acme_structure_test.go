@@ -1,11 +1,18 @@
 package acme
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"math"
+	"math/big"
+	"net"
 	"reflect"
 	"strings"
 	"testing"
@@ -138,6 +145,112 @@ Rb4po3kWJaGezLmNO+JyUOr5HnCjLSD/4WNHGAJbfOVES4hOz+oiTlWd7HflS+00
 iITbUq4IV5mAI5yceK+3rYWGYG47cu0BG9ngevUZ
 -----END CERTIFICATE-----`
 
+// testCertificateBundle is a test certificate generated by
+// testGenerateCertificateBundle.
+type testCertificateBundle struct {
+	// The issued certificate, in PEM format.
+	CertPEM []byte
+
+	// The issuing CA certificate, in PEM format.
+	IssuerPEM []byte
+
+	// The private key of the issued certificate, in PEM format.
+	KeyPEM []byte
+
+	// The parsed issued certificate and issuing CA certificate.
+	Cert   *x509.Certificate
+	Issuer *x509.Certificate
+
+	// The private key of the issuing CA.
+	IssuerKey crypto.Signer
+}
+
+// Bundle returns the issued certificate followed by the issuer, the same way
+// that lego returns a bundled certificate.
+func (b *testCertificateBundle) Bundle() []byte {
+	return append(append([]byte{}, b.CertPEM...), b.IssuerPEM...)
+}
+
+// testGenerateCertificateBundle generates a throwaway CA and a certificate
+// issued by it for the supplied domains. The first domain is used as the
+// common name. The template can be modified by the supplied function before
+// the certificate is signed.
+func testGenerateCertificateBundle(
+	t *testing.T,
+	notBefore, notAfter time.Time,
+	domains []string,
+	modify func(tmpl *x509.Certificate),
+) *testCertificateBundle {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             notBefore.Add(-time.Hour),
+		NotAfter:              notAfter.Add(time.Hour * 24 * 365),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		DNSNames:     domains,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if modify != nil {
+		modify(tmpl)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCertificateBundle{
+		CertPEM:   pem.EncodeToMemory(&pem.Block{Type: preambleCertificate, Bytes: der}),
+		IssuerPEM: pem.EncodeToMemory(&pem.Block{Type: preambleCertificate, Bytes: caDER}),
+		KeyPEM:    pem.EncodeToMemory(&pem.Block{Type: preambleECPrivateKey, Bytes: keyDER}),
+		Cert:      cert,
+		Issuer:    caCert,
+		IssuerKey: caKey,
+	}
+}
+
 func registrationResourceData() *schema.ResourceData {
 	r := resourceACMERegistration()
 	d := r.TestResourceData()
@@ -265,12 +378,81 @@ func TestACME_saveCertificateResource_badCert(t *testing.T) {
 		Certificate: []byte(b),
 	}
 	d := blankCertificateResource()
-	err := saveCertificateResource(d, c, "")
+	err := saveCertificateResource(d, c, certificateEncodeOptions{})
 	if err == nil {
 		t.Fatalf("expected error due to bad cert data")
 	}
 }
 
+func TestNormalizeDomain(t *testing.T) {
+	testCases := []struct {
+		domain      string
+		expected    string
+		expectedErr bool
+	}{
+		{domain: "www.example.com", expected: "www.example.com"},
+		{domain: "WWW.Example.COM.", expected: "www.example.com"},
+		{domain: "*.Example.com", expected: "*.example.com"},
+		{domain: "bücher.example", expected: "xn--bcher-kva.example"},
+		{domain: "*.BÜCHER.example.", expected: "*.xn--bcher-kva.example"},
+		{domain: "xn--bcher-kva.example", expected: "xn--bcher-kva.example"},
+		{domain: "_acme-challenge.example.com", expected: "_acme-challenge.example.com"},
+		{domain: ".", expectedErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.domain, func(t *testing.T) {
+			actual, err := normalizeDomain(tc.domain)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.expected != actual {
+				t.Fatalf("expected %q, got %q", tc.expected, actual)
+			}
+
+			if hashDomain(tc.domain) != hashDomain(tc.expected) {
+				t.Fatal("expected domain to hash the same as its normalized form")
+			}
+		})
+	}
+}
+
+func TestACME_certIPAddresses(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"www.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := certIPAddresses(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"192.0.2.1", "2001:db8::1"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected %#v, got %#v", expected, actual)
+	}
+}
+
 func TestACME_certDaysRemaining_CACert(t *testing.T) {
 	b := testBadCertBundleText
 	c := &certificate.Resource{
@@ -282,12 +464,12 @@ func TestACME_certDaysRemaining_CACert(t *testing.T) {
 	}
 }
 
-func TestACME_certLifetimeDays_CACert(t *testing.T) {
+func TestACME_certLifetime_CACert(t *testing.T) {
 	b := testBadCertBundleText
 	c := &certificate.Resource{
 		Certificate: []byte(b),
 	}
-	_, err := certLifetimeDays(c)
+	_, err := certLifetime(c)
 	if err == nil {
 		t.Fatalf("expected error due to cert being a CA")
 	}
@@ -312,7 +494,7 @@ func TestACME_splitPEMBundle_CAFirst(t *testing.T) {
 func TestACME_bundleToPKCS12_base64IsPadded(t *testing.T) {
 	b := testPaddingBundle
 	key := testPrivateKeyPKCS1Text
-	pfxBase64, err := bundleToPKCS12([]byte(b), []byte(key), "")
+	pfxBase64, err := bundleToPKCS12([]byte(b), []byte(key), certificateEncodeOptions{})
 
 	if err != nil {
 		t.Fatalf("bad: %#v", err)
@@ -0,0 +1,120 @@
+package acme
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// The values of verify_chain.
+const (
+	verifyChainOff  = "off"
+	verifyChainWarn = "warn"
+	verifyChainFail = "fail"
+)
+
+// verifyCertificateChain builds and verifies a path from the certificate in
+// certPEM through the intermediates in issuerPEM to one of the roots in
+// rootsPEM, or to the system roots if rootsPEM is empty. The error includes
+// the subject of the root that the chain leads to, to make it easier to tell
+// when the CA has switched to a different chain.
+func verifyCertificateChain(certPEM, issuerPEM, rootsPEM string, now time.Time) error {
+	certs, err := parsePEMBundle([]byte(certPEM))
+	if err != nil {
+		return err
+	}
+
+	issuers, err := parsePEMBundle([]byte(issuerPEM))
+	if err != nil {
+		return fmt.Errorf("error reading issuer certificates: %w", err)
+	}
+
+	var roots *x509.CertPool
+	if rootsPEM != "" {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM([]byte(rootsPEM)) {
+			return errors.New("trusted_roots_pem does not contain any certificates")
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, issuer := range issuers {
+		intermediates.AddCert(issuer)
+	}
+
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		// The root is the issuer of the last certificate in the chain, or the
+		// certificate itself if the CA includes the root in the chain.
+		return fmt.Errorf("error verifying certificate chain with root %q: %w", issuers[len(issuers)-1].Issuer.String(), err)
+	}
+
+	return nil
+}
+
+// resourceACMECertificateVerifyChainRefresh verifies the certificate chain in
+// state if verify_chain is enabled, and records the result in
+// chain_verification_error. A failure is never returned here, so that refresh
+// is not blocked by a chain that no longer verifies, but it is reported as a
+// warning (see resourceACMECertificateDiagnostics).
+func resourceACMECertificateVerifyChainRefresh(d *schema.ResourceData) {
+	if mode := d.Get("verify_chain").(string); mode == "" || mode == verifyChainOff {
+		d.Set("chain_verification_error", "")
+		return
+	}
+
+	err := verifyCertificateChain(
+		d.Get("certificate_pem").(string),
+		d.Get("issuer_pem").(string),
+		d.Get("trusted_roots_pem").(string),
+		time.Now(),
+	)
+	if err != nil {
+		d.Set("chain_verification_error", err.Error())
+	} else {
+		d.Set("chain_verification_error", "")
+	}
+}
+
+// resourceACMECertificateVerifyChain verifies the certificate chain after it
+// has been issued, renewed or re-selected, returning an error if verify_chain
+// is set to fail and the chain does not verify.
+func resourceACMECertificateVerifyChain(d *schema.ResourceData) error {
+	resourceACMECertificateVerifyChainRefresh(d)
+	if v := d.Get("chain_verification_error").(string); v != "" && d.Get("verify_chain").(string) == verifyChainFail {
+		return errors.New(v)
+	}
+
+	return nil
+}
+
+// resourceACMECertificateDiagnostics wraps a CRUD function for
+// acme_certificate, adding a warning if the certificate chain failed
+// verification.
+func resourceACMECertificateDiagnostics(f func(*schema.ResourceData, any) error) func(context.Context, *schema.ResourceData, any) diag.Diagnostics {
+	return func(_ context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+		if err := f(d, meta); err != nil {
+			return diag.FromErr(err)
+		}
+
+		if v := d.Get("chain_verification_error").(string); v != "" {
+			return diag.Diagnostics{{
+				Severity: diag.Warning,
+				Summary:  "Certificate chain verification failed",
+				Detail:   v,
+			}}
+		}
+
+		return nil
+	}
+}
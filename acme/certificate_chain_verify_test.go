@@ -0,0 +1,107 @@
+package acme
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestVerifyCertificateChain(t *testing.T) {
+	now := time.Now()
+	b := testGenerateCertificateBundle(t, now.Add(-time.Hour), now.Add(time.Hour), []string{"www.example.com"}, nil)
+	other := testGenerateCertificateBundle(t, now.Add(-time.Hour), now.Add(time.Hour), []string{"www.example.com"}, nil)
+
+	testCases := []struct {
+		name        string
+		rootsPEM    string
+		expectError string
+	}{
+		{
+			name:     "trusted root",
+			rootsPEM: string(other.IssuerPEM) + string(b.IssuerPEM),
+		},
+		{
+			name:        "untrusted root",
+			rootsPEM:    string(other.IssuerPEM),
+			expectError: `root "CN=Test Intermediate CA"`,
+		},
+		{
+			name:        "system roots",
+			expectError: `root "CN=Test Intermediate CA"`,
+		},
+		{
+			name:        "no roots",
+			rootsPEM:    "bad",
+			expectError: "trusted_roots_pem does not contain any certificates",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyCertificateChain(string(b.CertPEM), string(b.IssuerPEM), tc.rootsPEM, now)
+			if tc.expectError == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+				t.Fatalf("expected error containing %q, got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestResourceACMECertificateVerifyChain(t *testing.T) {
+	now := time.Now()
+	b := testGenerateCertificateBundle(t, now.Add(-time.Hour), now.Add(time.Hour), []string{"www.example.com"}, nil)
+	other := testGenerateCertificateBundle(t, now.Add(-time.Hour), now.Add(time.Hour), []string{"www.example.com"}, nil)
+
+	d := blankCertificateResource()
+	d.Set("certificate_pem", string(b.CertPEM))
+	d.Set("issuer_pem", string(b.IssuerPEM))
+	d.Set("trusted_roots_pem", string(other.IssuerPEM))
+	if err := resourceACMECertificateVerifyChain(d); err != nil {
+		t.Fatalf("expected no verification when off, got: %s", err)
+	}
+
+	if v := d.Get("chain_verification_error").(string); v != "" {
+		t.Fatalf("expected no verification error when off, got %q", v)
+	}
+
+	d.Set("verify_chain", verifyChainWarn)
+	if err := resourceACMECertificateVerifyChain(d); err != nil {
+		t.Fatalf("expected no error in warn mode, got: %s", err)
+	}
+
+	if v := d.Get("chain_verification_error").(string); v == "" {
+		t.Fatal("expected verification error in warn mode")
+	}
+
+	d.Set("verify_chain", verifyChainFail)
+	if err := resourceACMECertificateVerifyChain(d); err == nil {
+		t.Fatal("expected error in fail mode")
+	}
+
+	diags := resourceACMECertificateDiagnostics(func(d *schema.ResourceData, _ any) error {
+		resourceACMECertificateVerifyChainRefresh(d)
+		return nil
+	})(t.Context(), d, nil)
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Fatalf("expected a warning on refresh, got: %#v", diags)
+	}
+
+	d.Set("trusted_roots_pem", string(b.IssuerPEM))
+	if err := resourceACMECertificateVerifyChain(d); err != nil {
+		t.Fatalf("expected chain to verify, got: %s", err)
+	}
+
+	if v := d.Get("chain_verification_error").(string); v != "" {
+		t.Fatalf("expected no verification error, got %q", v)
+	}
+}
@@ -0,0 +1,250 @@
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// certificateChain is a certificate chain offered by the CA for the
+// certificate, either the default chain or an alternate chain (RFC 8555
+// section 7.4.2).
+type certificateChain struct {
+	URL            string
+	IssuerPEM      string
+	RootCommonName string
+}
+
+// certificateChainFields are the fields that change when a different
+// certificate chain is selected.
+var certificateChainFields = []string{
+	"certificate_url",
+	"issuer_pem",
+	"issuer_der",
+	"certificate_pem_fullchain",
+	"certificate_p7b",
+	"certificate_p12",
+	"certificate_jks",
+}
+
+// expandACMECore creates a connection to the ACME server for making requests
+// that are not exposed by lego.Client.
+func expandACMECore(d *schema.ResourceData, meta any) (*api.Core, error) {
+	_, user, err := expandACMEClient(d, meta, true)
+	if err != nil {
+		return nil, err
+	}
+
+	config := expandACMEClient_config(d, meta, user)
+	return api.New(config.HTTPClient, config.UserAgent, config.CADirURL, user.GetRegistration().URI, user.GetPrivateKey())
+}
+
+// fetchCertificateChains fetches the certificate at certURL, along with any
+// alternate chains offered for it. The chain at certURL is returned first,
+// followed by the alternate chains sorted by URL.
+func fetchCertificateChains(d *schema.ResourceData, meta any, certURL string) ([]certificateChain, error) {
+	core, err := expandACMECore(d, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := core.Certificates.GetAll(certURL, true)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching certificate chains: %w", err)
+	}
+
+	return certificateChainsFromRaw(raw, certURL)
+}
+
+// certificateChainsFromRaw returns the chains fetched for the certificate at
+// certURL, in the same order as fetchCertificateChains.
+func certificateChainsFromRaw(raw map[string]*acme.RawCertificate, certURL string) ([]certificateChain, error) {
+	var chains []certificateChain
+	for u, rc := range raw {
+		issuers, err := parsePEMBundle(rc.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("error reading certificate chain at %s: %w", u, err)
+		}
+
+		// The issuers are encoded in the same way as issuer_pem, so that they
+		// can be compared with it.
+		chain := certificateChain{
+			URL:       u,
+			IssuerPEM: string(encodePEMBundle(issuers)),
+		}
+		if len(issuers) > 0 {
+			chain.RootCommonName = issuers[len(issuers)-1].Issuer.CommonName
+		}
+
+		chains = append(chains, chain)
+	}
+
+	sort.Slice(chains, func(i, j int) bool {
+		if chains[i].URL == certURL || chains[j].URL == certURL {
+			return chains[i].URL == certURL
+		}
+
+		return chains[i].URL < chains[j].URL
+	})
+
+	return chains, nil
+}
+
+// flattenCertificateChains returns the available_chains attribute for a list
+// of chains.
+func flattenCertificateChains(chains []certificateChain) []any {
+	result := make([]any, len(chains))
+	for i, chain := range chains {
+		result[i] = map[string]any{
+			"url":              chain.URL,
+			"issuer_pem":       chain.IssuerPEM,
+			"root_common_name": chain.RootCommonName,
+		}
+	}
+
+	return result
+}
+
+// expandCertificateChains returns the chains in available_chains.
+func expandCertificateChains(d resourceDataOrDiff) []certificateChain {
+	var chains []certificateChain
+	for _, v := range d.Get("available_chains").([]any) {
+		m := v.(map[string]any)
+		chains = append(chains, certificateChain{
+			URL:            m["url"].(string),
+			IssuerPEM:      m["issuer_pem"].(string),
+			RootCommonName: m["root_common_name"].(string),
+		})
+	}
+
+	return chains
+}
+
+// selectCertificateChain selects a chain in the same way as lego does on
+// issuance: the first chain with a root issued by preferredChain. If
+// preferredChain is not set or no chain matches it, the chain at currentURL
+// is kept.
+func selectCertificateChain(chains []certificateChain, currentURL, preferredChain string) (certificateChain, bool) {
+	if preferredChain != "" {
+		for _, chain := range chains {
+			if chain.RootCommonName == preferredChain {
+				return chain, true
+			}
+		}
+	}
+
+	for _, chain := range chains {
+		if chain.URL == currentURL {
+			return chain, true
+		}
+	}
+
+	return certificateChain{}, false
+}
+
+// resourceACMECertificateChainChanged returns true if the chain selected from
+// available_chains differs from the chain in state.
+func resourceACMECertificateChainChanged(d resourceDataOrDiff) bool {
+	certURL, _ := d.GetChange("certificate_url")
+	issuerPEM, _ := d.GetChange("issuer_pem")
+	chain, ok := selectCertificateChain(expandCertificateChains(d), certURL.(string), d.Get("preferred_chain").(string))
+	return ok && (chain.URL != certURL.(string) || !pemBundlesEqual(chain.IssuerPEM, issuerPEM.(string)))
+}
+
+// encodePEMBundle returns certs as a PEM bundle, encoded in the same way as
+// by splitPEMBundle.
+func encodePEMBundle(certs []*x509.Certificate) []byte {
+	var bundle []byte
+	for _, cert := range certs {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: preambleCertificate, Bytes: cert.Raw})...)
+	}
+
+	return bundle
+}
+
+// pemBundlesEqual returns true if two PEM bundles contain the same
+// certificates, in the same order, regardless of how they are encoded.
+func pemBundlesEqual(a, b string) bool {
+	ac, err := parsePEMBundle([]byte(a))
+	if err != nil {
+		return a == b
+	}
+
+	bc, err := parsePEMBundle([]byte(b))
+	if err != nil || len(ac) != len(bc) {
+		return false
+	}
+
+	for i := range ac {
+		if !ac[i].Equal(bc[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resourceACMECertificateChainRefresh re-fetches the certificate's chains
+// from the CA when refresh_chain is enabled. A change in the selected chain is
+// not applied here, but is planned in CustomizeDiff and applied in Update, as
+// the bundles that include the private key can't always be rebuilt during
+// refresh.
+func resourceACMECertificateChainRefresh(d *schema.ResourceData, meta any) error {
+	if !d.Get("refresh_chain").(bool) {
+		d.Set("available_chains", nil)
+		return nil
+	}
+
+	chains, err := fetchCertificateChains(d, meta, d.Get("certificate_url").(string))
+	if err != nil {
+		return err
+	}
+
+	d.Set("available_chains", flattenCertificateChains(chains))
+	return nil
+}
+
+// resourceACMECertificateUpdateChain applies the chain selected by
+// preferred_chain, or a chain that has changed at the CA, without reissuing
+// the certificate. The chains are fetched if preferred_chain has changed, or
+// they have not been fetched during refresh. The saved certificate is
+// returned.
+func resourceACMECertificateUpdateChain(d *schema.ResourceData, meta any) (*certificate.Resource, error) {
+	certURL, _ := d.GetChange("certificate_url")
+	chains := expandCertificateChains(d)
+	if d.HasChange("preferred_chain") || len(chains) == 0 {
+		var err error
+		chains, err = fetchCertificateChains(d, meta, certURL.(string))
+		if err != nil {
+			return nil, err
+		}
+
+		if d.Get("refresh_chain").(bool) {
+			d.Set("available_chains", flattenCertificateChains(chains))
+		}
+	}
+
+	chain, ok := selectCertificateChain(chains, certURL.(string), d.Get("preferred_chain").(string))
+	if !ok {
+		// Keep the chain in state, which still needs to be saved as it's
+		// computed in the plan.
+		issuerPEM, _ := d.GetChange("issuer_pem")
+		chain = certificateChain{URL: certURL.(string), IssuerPEM: issuerPEM.(string)}
+	}
+
+	cert, err := resourceACMECertificateExpand(d)
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM, _ := d.GetChange("certificate_pem")
+	cert.CertURL = chain.URL
+	cert.Certificate = []byte(certPEM.(string) + chain.IssuerPEM)
+	return cert, resourceACMECertificateSave(d, cert)
+}
@@ -0,0 +1,140 @@
+package acme
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func testCertificateChains() []certificateChain {
+	return []certificateChain{
+		{URL: "https://ca.example.com/cert/1", IssuerPEM: "default", RootCommonName: "Root A"},
+		{URL: "https://ca.example.com/cert/1/alternate/1", IssuerPEM: "alternate 1", RootCommonName: "Root B"},
+		{URL: "https://ca.example.com/cert/1/alternate/2", IssuerPEM: "alternate 2", RootCommonName: "Root B"},
+	}
+}
+
+func TestSelectCertificateChain(t *testing.T) {
+	testCases := []struct {
+		name           string
+		currentURL     string
+		preferredChain string
+		expectedURL    string
+		expectedOK     bool
+	}{
+		{
+			name:        "no preferred chain",
+			currentURL:  "https://ca.example.com/cert/1/alternate/2",
+			expectedURL: "https://ca.example.com/cert/1/alternate/2",
+			expectedOK:  true,
+		},
+		{
+			name:           "preferred chain",
+			currentURL:     "https://ca.example.com/cert/1",
+			preferredChain: "Root B",
+			expectedURL:    "https://ca.example.com/cert/1/alternate/1",
+			expectedOK:     true,
+		},
+		{
+			name:           "no match",
+			currentURL:     "https://ca.example.com/cert/1",
+			preferredChain: "Root C",
+			expectedURL:    "https://ca.example.com/cert/1",
+			expectedOK:     true,
+		},
+		{
+			name:       "current chain not offered",
+			currentURL: "https://ca.example.com/cert/2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chain, ok := selectCertificateChain(testCertificateChains(), tc.currentURL, tc.preferredChain)
+			if ok != tc.expectedOK {
+				t.Fatalf("expected ok to be %t, got %t", tc.expectedOK, ok)
+			}
+
+			if chain.URL != tc.expectedURL {
+				t.Fatalf("expected chain %q, got %q", tc.expectedURL, chain.URL)
+			}
+		})
+	}
+}
+
+func TestResourceACMECertificateChainChanged(t *testing.T) {
+	d := resourceACMECertificate().Data(&terraform.InstanceState{
+		ID: "certificate",
+		Attributes: map[string]string{
+			"certificate_url": "https://ca.example.com/cert/1",
+			"issuer_pem":      "old",
+		},
+	})
+	if resourceACMECertificateChainChanged(d) {
+		t.Fatal("expected no change without available chains")
+	}
+
+	d.Set("available_chains", flattenCertificateChains(testCertificateChains()))
+	if actual := expandCertificateChains(d); len(actual) != 3 || actual[1] != testCertificateChains()[1] {
+		t.Fatalf("unexpected chains after round trip: %#v", actual)
+	}
+
+	// The intermediates of the current chain have changed at the CA.
+	if !resourceACMECertificateChainChanged(d) {
+		t.Fatal("expected change for updated intermediates")
+	}
+
+	d.Set("preferred_chain", "Root B")
+	if !resourceACMECertificateChainChanged(d) {
+		t.Fatal("expected change for preferred chain")
+	}
+
+	d.Set("preferred_chain", "Root C")
+	d.Set("available_chains", flattenCertificateChains([]certificateChain{
+		{URL: "https://ca.example.com/cert/1", IssuerPEM: "old", RootCommonName: "Root A"},
+	}))
+	if resourceACMECertificateChainChanged(d) {
+		t.Fatal("expected no change for the current chain")
+	}
+}
+
+func TestCertificateChainsFromRaw(t *testing.T) {
+	now := time.Now()
+	b := testGenerateCertificateBundle(t, now, now.Add(time.Hour), []string{"www.example.com"}, nil)
+
+	// The CA's encoding of the chain differs from the one saved in
+	// issuer_pem, with CRLF line endings and text around the certificate.
+	issuer := "Intermediate\r\n" + strings.ReplaceAll(string(b.IssuerPEM), "\n", "\r\n")
+	chains, err := certificateChainsFromRaw(map[string]*acme.RawCertificate{
+		"https://ca.example.com/cert/1": {Cert: b.CertPEM, Issuer: []byte(issuer)},
+	}, "https://ca.example.com/cert/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chains) != 1 || chains[0].IssuerPEM != string(b.IssuerPEM) {
+		t.Fatalf("expected the issuer to be re-encoded, got %#v", chains)
+	}
+
+	if chains[0].RootCommonName != b.Issuer.Issuer.CommonName {
+		t.Fatalf("expected root common name %q, got %q", b.Issuer.Issuer.CommonName, chains[0].RootCommonName)
+	}
+
+	// A chain in state that was saved with the CA's encoding is unchanged.
+	d := resourceACMECertificate().Data(&terraform.InstanceState{
+		ID: "certificate",
+		Attributes: map[string]string{
+			"certificate_url": "https://ca.example.com/cert/1",
+			"issuer_pem":      string(b.IssuerPEM),
+		},
+	})
+	d.Set("available_chains", flattenCertificateChains([]certificateChain{
+		{URL: "https://ca.example.com/cert/1", IssuerPEM: issuer},
+	}))
+	if resourceACMECertificateChainChanged(d) {
+		t.Fatal("expected no change for a differently encoded chain")
+	}
+}
@@ -0,0 +1,218 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Values for ACME_DEPLOY_EVENT, the event that a deploy hook is run for.
+const (
+	deployHookEventCreate = "create"
+	deployHookEventRenew  = "renew"
+	deployHookEventChain  = "chain"
+)
+
+func deployHookSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"command": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"args": {
+					Type:     schema.TypeList,
+					Optional: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"environment": {
+					Type:      schema.TypeMap,
+					Optional:  true,
+					Sensitive: true,
+					Elem:      &schema.Schema{Type: schema.TypeString},
+				},
+				"timeout": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "5m",
+					ValidateFunc: validateDeployHookTimeout,
+				},
+			},
+		},
+	}
+}
+
+// validateDeployHookTimeout validates deploy_hook.timeout, which must be a
+// positive duration.
+func validateDeployHookTimeout(v any, k string) (ws []string, errors []error) {
+	timeout, err := time.ParseDuration(v.(string))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%s: %w", k, err))
+	} else if timeout <= 0 {
+		errors = append(errors, fmt.Errorf("%s: must be greater than 0", k))
+	}
+	return
+}
+
+// deployHook is a command run after a certificate is issued, from the
+// deploy_hook block.
+type deployHook struct {
+	Command     string
+	Args        []string
+	Environment map[string]string
+	Timeout     time.Duration
+}
+
+// expandDeployHook returns the deploy hook for the certificate, or nil if
+// deploy_hook is not set.
+func expandDeployHook(d resourceDataOrDiff) *deployHook {
+	v := d.Get("deploy_hook").([]any)
+	if len(v) == 0 || v[0] == nil {
+		return nil
+	}
+
+	m := v[0].(map[string]any)
+	h := &deployHook{
+		Command:     m["command"].(string),
+		Environment: make(map[string]string),
+	}
+
+	for _, arg := range m["args"].([]any) {
+		s, _ := arg.(string)
+		h.Args = append(h.Args, s)
+	}
+
+	for k, v := range m["environment"].(map[string]any) {
+		h.Environment[k] = v.(string)
+	}
+
+	if v, _ := m["timeout"].(string); v != "" {
+		// Validated in the schema.
+		h.Timeout, _ = time.ParseDuration(v)
+	}
+
+	return h
+}
+
+// deployHookCertificate is the certificate that a deploy hook is run for.
+type deployHookCertificate struct {
+	Domain      string
+	URL         string
+	Certificate []byte
+	Chain       []byte
+	FullChain   []byte
+	PrivateKey  []byte
+}
+
+// expandDeployHookCertificate returns the certificate for a deploy hook from
+// the certificate saved to state, with privateKey, which is not in state if
+// it was supplied as write-only or omit_private_key_pem is set.
+func expandDeployHookCertificate(d *schema.ResourceData, privateKey []byte) deployHookCertificate {
+	return deployHookCertificate{
+		Domain:      d.Get("certificate_domain").(string),
+		URL:         d.Get("certificate_url").(string),
+		Certificate: []byte(d.Get("certificate_pem").(string)),
+		Chain:       []byte(d.Get("issuer_pem").(string)),
+		FullChain:   []byte(d.Get("certificate_pem_fullchain").(string)),
+		PrivateKey:  privateKey,
+	}
+}
+
+// Run runs the hook for cert. The certificate, chain, full chain and private
+// key are written to a temporary directory, which is removed once the hook
+// has finished, and their paths are passed to the hook in the environment.
+// The hook's output is included in the returned error if it fails.
+func (h *deployHook) Run(cert deployHookCertificate, event string) error {
+	dir, err := os.MkdirTemp("", "terraform-provider-acme-deploy-")
+	if err != nil {
+		return fmt.Errorf("error creating directory for deploy_hook: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []struct {
+		env  string
+		name string
+		data []byte
+	}{
+		{"ACME_CERTIFICATE_PATH", "cert.pem", cert.Certificate},
+		{"ACME_CHAIN_PATH", "chain.pem", cert.Chain},
+		{"ACME_FULLCHAIN_PATH", "fullchain.pem", cert.FullChain},
+		{"ACME_PRIVATE_KEY_PATH", "privkey.pem", cert.PrivateKey},
+	}
+
+	env := append(os.Environ(),
+		"ACME_DEPLOY_EVENT="+event,
+		"ACME_CERTIFICATE_DOMAIN="+cert.Domain,
+		"ACME_CERTIFICATE_URL="+cert.URL,
+	)
+	for _, f := range files {
+		if len(f.data) == 0 {
+			// No private key is available for a certificate issued from a CSR
+			// or a PKCS#11 key.
+			env = append(env, f.env+"=")
+			continue
+		}
+
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, f.data, 0o600); err != nil {
+			return fmt.Errorf("error writing %s for deploy_hook: %w", f.name, err)
+		}
+
+		env = append(env, f.env+"="+path)
+	}
+
+	for k, v := range h.Environment {
+		env = append(env, k+"="+v)
+	}
+
+	ctx := context.Background()
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...)
+	cmd.Env = env
+	// Don't wait on the output of any processes started by the hook after it
+	// has been killed.
+	cmd.WaitDelay = 10 * time.Second
+
+	log.Printf("[DEBUG] running deploy_hook %q for %s", h.Command, event)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", h.Timeout)
+		}
+
+		return fmt.Errorf("deploy_hook %q failed: %w\n\nOutput:\n%s", h.Command, err, strings.TrimSpace(string(out)))
+	}
+
+	log.Printf("[DEBUG] deploy_hook %q output:\n%s", h.Command, out)
+	return nil
+}
+
+// resourceACMECertificateRunDeployHook runs deploy_hook, if it is set, for
+// the certificate saved to state, once it has been fully saved. A failure
+// fails the apply, but leaves the certificate in state. On creation, this
+// also taints the certificate, so that it's replaced on the next apply.
+func resourceACMECertificateRunDeployHook(d *schema.ResourceData, privateKey []byte, event string) error {
+	h := expandDeployHook(d)
+	if h == nil {
+		return nil
+	}
+
+	return h.Run(expandDeployHookCertificate(d, privateKey), event)
+}
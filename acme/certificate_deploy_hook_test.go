@@ -0,0 +1,168 @@
+package acme
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDeployHookRun(t *testing.T) {
+	now := time.Now()
+	b := testGenerateCertificateBundle(t, now, now.Add(time.Hour), []string{"www.example.com"}, nil)
+
+	// lego returns the certificate bundled with its issuer.
+	d := blankCertificateResource()
+	if err := resourceACMECertificateSave(d, &certificate.Resource{
+		Domain:            "www.example.com",
+		CertURL:           "https://example.com/cert/1",
+		Certificate:       append(append([]byte{}, b.CertPEM...), b.IssuerPEM...),
+		IssuerCertificate: b.IssuerPEM,
+		PrivateKey:        b.KeyPEM,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	d.Set("deploy_hook", []any{map[string]any{
+		"command": "/bin/sh",
+		"args": []any{
+			"-c",
+			`cp "$ACME_CERTIFICATE_PATH" "$ACME_CHAIN_PATH" "$ACME_FULLCHAIN_PATH" "$ACME_PRIVATE_KEY_PATH" "$1" && ` +
+				`echo "$ACME_DEPLOY_EVENT $ACME_CERTIFICATE_DOMAIN $ACME_CERTIFICATE_URL $FOO" > "$1/env"`,
+			"hook",
+			out,
+		},
+		"environment": map[string]any{"FOO": "bar"},
+	}})
+
+	if err := resourceACMECertificateRunDeployHook(d, b.KeyPEM, deployHookEventRenew); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"cert.pem":      string(b.CertPEM),
+		"chain.pem":     string(b.IssuerPEM),
+		"fullchain.pem": string(b.CertPEM) + string(b.IssuerPEM),
+		"privkey.pem":   string(b.KeyPEM),
+		"env":           "renew www.example.com https://example.com/cert/1 bar\n",
+	}
+	for name, v := range expected {
+		actual, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(actual) != v {
+			t.Fatalf("expected %s to be:\n%s\ngot:\n%s", name, v, actual)
+		}
+	}
+}
+
+func TestDeployHookRunNoPrivateKey(t *testing.T) {
+	now := time.Now()
+	b := testGenerateCertificateBundle(t, now, now.Add(time.Hour), []string{"www.example.com"}, nil)
+	cert := deployHookCertificate{
+		Certificate: b.CertPEM,
+		Chain:       b.IssuerPEM,
+		FullChain:   append(append([]byte{}, b.CertPEM...), b.IssuerPEM...),
+	}
+
+	h := &deployHook{
+		Command: "/bin/sh",
+		Args:    []string{"-c", `test -z "$ACME_PRIVATE_KEY_PATH" && test -f "$ACME_CERTIFICATE_PATH"`},
+	}
+
+	if err := h.Run(cert, deployHookEventCreate); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeployHookRunFailure(t *testing.T) {
+	testCases := []struct {
+		name        string
+		hook        *deployHook
+		expectError []string
+	}{
+		{
+			name: "exit status",
+			hook: &deployHook{
+				Command: "/bin/sh",
+				Args:    []string{"-c", "echo reload failed >&2; exit 3"},
+			},
+			expectError: []string{"exit status 3", "reload failed"},
+		},
+		{
+			name: "timeout",
+			hook: &deployHook{
+				Command: "/bin/sh",
+				Args:    []string{"-c", "echo waiting; exec sleep 10"},
+				Timeout: 100 * time.Millisecond,
+			},
+			expectError: []string{"timed out after 100ms", "waiting"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.hook.Run(deployHookCertificate{}, deployHookEventCreate)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+
+			for _, s := range tc.expectError {
+				if !strings.Contains(err.Error(), s) {
+					t.Fatalf("expected error containing %q, got: %s", s, err)
+				}
+			}
+		})
+	}
+}
+
+func TestResourceACMECertificateDeployHookFailure(t *testing.T) {
+	d := blankCertificateResource()
+	d.Set("deploy_hook", []any{map[string]any{
+		"command": "/bin/sh",
+		"args":    []any{"-c", "exit 1"},
+	}})
+
+	for _, event := range []string{deployHookEventCreate, deployHookEventRenew, deployHookEventChain} {
+		t.Run(event, func(t *testing.T) {
+			diags := resourceACMECertificateDiagnostics(func(d *schema.ResourceData, _ any) error {
+				return resourceACMECertificateRunDeployHook(d, nil, event)
+			})(context.Background(), d, nil)
+			if len(diags) != 1 || diags[0].Severity != diag.Error {
+				t.Fatalf("expected a single error, got %#v", diags)
+			}
+		})
+	}
+}
+
+func TestValidateDeployHookTimeout(t *testing.T) {
+	testCases := []struct {
+		value       string
+		expectError bool
+	}{
+		{value: "30s"},
+		{value: "1h30m"},
+		{value: "0s", expectError: true},
+		{value: "-1m", expectError: true},
+		{value: "10%", expectError: true},
+		{value: "5", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			_, errs := validateDeployHookTimeout(tc.value, "deploy_hook.0.timeout")
+			if (len(errs) > 0) != tc.expectError {
+				t.Fatalf("expected error %t, got %v", tc.expectError, errs)
+			}
+		})
+	}
+}
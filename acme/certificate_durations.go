@@ -0,0 +1,164 @@
+package acme
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// certificateDuration is a duration given either as a Go duration string
+// (e.g. 36h), or as a percentage of the certificate's lifetime (e.g. 33%).
+type certificateDuration struct {
+	Duration time.Duration
+	Percent  float64
+}
+
+// parseCertificateDuration parses a duration string, or a percentage if
+// allowPercent is set.
+func parseCertificateDuration(v string, allowPercent bool) (certificateDuration, error) {
+	if p, ok := strings.CutSuffix(v, "%"); ok {
+		if !allowPercent {
+			return certificateDuration{}, errors.New("must be a duration, such as 144h")
+		}
+
+		percent, err := strconv.ParseFloat(p, 64)
+		if err != nil || percent <= 0 || percent >= 100 {
+			return certificateDuration{}, errors.New("percentage must be greater than 0 and less than 100")
+		}
+
+		return certificateDuration{Percent: percent}, nil
+	}
+
+	duration, err := time.ParseDuration(v)
+	if err != nil {
+		return certificateDuration{}, err
+	}
+
+	if duration <= 0 {
+		return certificateDuration{}, errors.New("duration must be greater than 0")
+	}
+
+	return certificateDuration{Duration: duration}, nil
+}
+
+// Of returns the duration for a certificate with the given lifetime.
+func (c certificateDuration) Of(lifetime time.Duration) time.Duration {
+	if c.Percent != 0 {
+		return time.Duration(float64(lifetime) * c.Percent / 100)
+	}
+
+	return c.Duration
+}
+
+func (c certificateDuration) String() string {
+	if c.Percent != 0 {
+		return strconv.FormatFloat(c.Percent, 'f', -1, 64) + "%"
+	}
+
+	return c.Duration.String()
+}
+
+// validateCertificateDuration validates a duration or percentage, for
+// renew_before.
+func validateCertificateDuration(v any, k string) (ws []string, errors []error) {
+	if _, err := parseCertificateDuration(v.(string), true); err != nil {
+		errors = append(errors, fmt.Errorf("%s: %w", k, err))
+	}
+	return
+}
+
+// validateCertificateLifetime validates a duration, for validity.
+func validateCertificateLifetime(v any, k string) (ws []string, errors []error) {
+	if _, err := parseCertificateDuration(v.(string), false); err != nil {
+		errors = append(errors, fmt.Errorf("%s: %w", k, err))
+	}
+	return
+}
+
+// resourceACMECertificateValidity returns the requested lifetime of the
+// certificate from validity or validity_days, and the name of the attribute
+// it was set in. An empty name is returned if neither is set.
+func resourceACMECertificateValidity(d resourceDataOrDiff) (time.Duration, string) {
+	if v, ok := d.GetOk("validity"); ok {
+		// Validated in the schema.
+		c, _ := parseCertificateDuration(v.(string), false)
+		return c.Duration, "validity"
+	}
+
+	if v, ok := d.GetOk("validity_days"); ok {
+		return time.Duration(v.(int)) * 24 * time.Hour, "validity_days"
+	}
+
+	return 0, ""
+}
+
+// resourceACMECertificateNotAfter returns the requested expiry time of a
+// certificate ordered now, or the zero time to use the CA's default lifetime.
+func resourceACMECertificateNotAfter(d resourceDataOrDiff) time.Time {
+	validity, k := resourceACMECertificateValidity(d)
+	if k == "" {
+		return time.Time{}
+	}
+
+	// Note that we add a 15 minute skew to the duration to account for delays
+	// between the start of the challenge and the actual order.
+	return time.Now().Add(validity + time.Minute*15)
+}
+
+// resourceACMECertificateRenewBefore returns how long before expiry a
+// certificate with the given lifetime is renewed, from renew_before or
+// min_days_remaining, and the name of the attribute it was set in. An empty
+// name is returned if min_days_dynamic is used instead, or if the certificate
+// is never renewed because min_days_remaining is negative.
+func resourceACMECertificateRenewBefore(d resourceDataOrDiff, lifetime time.Duration) (time.Duration, string) {
+	if v, ok := d.GetOk("renew_before"); ok {
+		// Validated in the schema.
+		c, _ := parseCertificateDuration(v.(string), true)
+		return c.Of(lifetime), "renew_before"
+	}
+
+	if d.Get("min_days_dynamic").(bool) {
+		return 0, ""
+	}
+
+	mindays := d.Get("min_days_remaining").(int)
+	if mindays < 0 {
+		return 0, ""
+	}
+
+	return time.Duration(mindays) * 24 * time.Hour, "min_days_remaining"
+}
+
+// validateCertificateRenewalThreshold validates that the requested lifetime of
+// the certificate is longer than its renewal threshold, which would otherwise
+// cause the certificate to be renewed on every apply.
+func validateCertificateRenewalThreshold(d resourceDataOrDiff) error {
+	validity, validityKey := resourceACMECertificateValidity(d)
+	if validityKey == "" {
+		return nil
+	}
+
+	renewBefore, renewBeforeKey := resourceACMECertificateRenewBefore(d, validity)
+	if renewBeforeKey == "" || validity > renewBefore {
+		return nil
+	}
+
+	if validityKey == "validity_days" && renewBeforeKey == "min_days_remaining" {
+		return fmt.Errorf(
+			"validity_days (%d day(s)) is within min_days_remaining (%d); "+
+				"this would trigger immediate renewal on every apply - "+
+				"reduce min_days_remaining or increase validity_days",
+			d.Get("validity_days").(int), d.Get("min_days_remaining").(int),
+		)
+	}
+
+	return fmt.Errorf(
+		"%s (%s) is within %s (%s); "+
+			"this would trigger immediate renewal on every apply - "+
+			"reduce %s or increase %s",
+		validityKey, validity, renewBeforeKey, renewBefore,
+		renewBeforeKey, validityKey,
+	)
+}
@@ -0,0 +1,185 @@
+package acme
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestParseCertificateDuration(t *testing.T) {
+	testCases := []struct {
+		value        string
+		allowPercent bool
+		expected     certificateDuration
+		expectError  bool
+	}{
+		{value: "36h", expected: certificateDuration{Duration: 36 * time.Hour}},
+		{value: "90m", allowPercent: true, expected: certificateDuration{Duration: 90 * time.Minute}},
+		{value: "33.3%", allowPercent: true, expected: certificateDuration{Percent: 33.3}},
+		{value: "50%", expectError: true},
+		{value: "0%", allowPercent: true, expectError: true},
+		{value: "100%", allowPercent: true, expectError: true},
+		{value: "0s", expectError: true},
+		{value: "-1h", expectError: true},
+		{value: "6d", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			actual, err := parseCertificateDuration(tc.value, tc.allowPercent)
+			if tc.expectError != (err != nil) {
+				t.Fatalf("expected error: %t, got: %v", tc.expectError, err)
+			}
+
+			if actual != tc.expected {
+				t.Fatalf("expected %#v, got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestValidateCertificateRenewalThreshold(t *testing.T) {
+	testCases := []struct {
+		name        string
+		config      map[string]any
+		expectError string
+	}{
+		{
+			name:   "validity_days outside min_days_remaining",
+			config: map[string]any{"validity_days": 31, "min_days_remaining": 30},
+		},
+		{
+			name:        "validity_days within min_days_remaining",
+			config:      map[string]any{"validity_days": 30, "min_days_remaining": 30},
+			expectError: "validity_days (30 day(s)) is within min_days_remaining (30)",
+		},
+		{
+			name:   "validity outside renew_before",
+			config: map[string]any{"validity": "160h", "renew_before": "36h"},
+		},
+		{
+			name:        "validity within renew_before",
+			config:      map[string]any{"validity": "36h", "renew_before": "36h"},
+			expectError: "validity (36h0m0s) is within renew_before (36h0m0s)",
+		},
+		{
+			name:        "validity_days within renew_before",
+			config:      map[string]any{"validity_days": 6, "renew_before": "144h"},
+			expectError: "validity_days (144h0m0s) is within renew_before (144h0m0s)",
+		},
+		{
+			name:        "validity within min_days_remaining",
+			config:      map[string]any{"validity": "160h", "min_days_remaining": 7},
+			expectError: "validity (160h0m0s) is within min_days_remaining (168h0m0s)",
+		},
+		{
+			name:   "percentage",
+			config: map[string]any{"validity": "160h", "renew_before": "99%"},
+		},
+		{
+			name:   "dynamic",
+			config: map[string]any{"validity": "1h", "min_days_dynamic": true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := blankCertificateResource()
+			for k, v := range tc.config {
+				d.Set(k, v)
+			}
+
+			err := validateCertificateRenewalThreshold(d)
+			if tc.expectError == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+				t.Fatalf("expected error containing %q, got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestResourceACMECertificateHasExpired(t *testing.T) {
+	// A 160 hour certificate with 40 hours remaining.
+	now := time.Now()
+	b := testGenerateCertificateBundle(t, now.Add(-120*time.Hour), now.Add(40*time.Hour), []string{"www.example.com"}, nil)
+
+	testCases := []struct {
+		name     string
+		config   map[string]any
+		expected bool
+	}{
+		{
+			name:     "min_days_remaining not reached",
+			config:   map[string]any{"min_days_remaining": 1},
+			expected: false,
+		},
+		{
+			name:     "min_days_remaining reached",
+			config:   map[string]any{"min_days_remaining": 2},
+			expected: true,
+		},
+		{
+			name:     "min_days_remaining never",
+			config:   map[string]any{"min_days_remaining": -1},
+			expected: false,
+		},
+		{
+			name:     "renew_before duration not reached",
+			config:   map[string]any{"renew_before": "36h"},
+			expected: false,
+		},
+		{
+			name:     "renew_before duration reached",
+			config:   map[string]any{"renew_before": "41h"},
+			expected: true,
+		},
+		{
+			name:     "renew_before percentage not reached",
+			config:   map[string]any{"renew_before": "24%"},
+			expected: false,
+		},
+		{
+			name:     "renew_before percentage reached",
+			config:   map[string]any{"renew_before": "26%"},
+			expected: true,
+		},
+		{
+			name:     "dynamic",
+			config:   map[string]any{"min_days_dynamic": true},
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := resourceACMECertificate().Data(&terraform.InstanceState{
+				ID: "certificate",
+				Attributes: map[string]string{
+					"certificate_pem": string(b.CertPEM),
+					"issuer_pem":      string(b.IssuerPEM),
+				},
+			})
+			for k, v := range tc.config {
+				d.Set(k, v)
+			}
+
+			actual, err := resourceACMECertificateHasExpired(d, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual != tc.expected {
+				t.Fatalf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,457 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"unicode/utf16"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// The PEM preamble for PKCS#7 bundles.
+const preamblePKCS7 = "PKCS7"
+
+// Values for p12_encoding.
+const (
+	p12EncodingModern    = "modern"
+	p12EncodingLegacyRC2 = "legacy_rc2"
+	p12EncodingLegacyDES = "legacy_des"
+)
+
+// The default alias for the key entry in certificate_jks, when
+// certificate_friendly_name is not set.
+const defaultJKSAlias = "certificate"
+
+var (
+	oidPKCS7Data       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+	oidPKCS12KeyBag         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS12ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidPKCS9FriendlyName    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidSHA1                 = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+	// The OID of the proprietary key protection algorithm used in JKS files.
+	oidJKSKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+)
+
+// certificateEncodeOptions are the options for the certificate bundles that
+// hold a private key (certificate_p12 and certificate_jks).
+type certificateEncodeOptions struct {
+	// The password for the bundles.
+	Password string
+
+	// The encoder for PKCS#12 bundles, one of the p12Encoding* values. Defaults
+	// to p12EncodingModern.
+	P12Encoding string
+
+	// The friendly name of the private key in PKCS#12 bundles, and its alias in
+	// JKS bundles.
+	FriendlyName string
+}
+
+// expandCertificateEncodeOptions returns the certificateEncodeOptions for the
+// certificate resource.
+func expandCertificateEncodeOptions(d resourceDataOrDiff) certificateEncodeOptions {
+	return certificateEncodeOptions{
+		Password:     d.Get("certificate_p12_password").(string),
+		P12Encoding:  d.Get("p12_encoding").(string),
+		FriendlyName: d.Get("certificate_friendly_name").(string),
+	}
+}
+
+// pkcs12Encoder returns the go-pkcs12 encoder for a p12_encoding value.
+func pkcs12Encoder(encoding string) (*pkcs12.Encoder, error) {
+	switch encoding {
+	case "", p12EncodingModern:
+		return pkcs12.Modern2023, nil
+	case p12EncodingLegacyRC2:
+		return pkcs12.LegacyRC2, nil
+	case p12EncodingLegacyDES:
+		return pkcs12.LegacyDES, nil
+	}
+
+	return nil, fmt.Errorf("unknown PKCS#12 encoding %q", encoding)
+}
+
+// The following types are from RFC 7292 (PKCS#12) and RFC 2315 (PKCS#7).
+
+type pkcs12PFX struct {
+	Version  int
+	AuthSafe pkcs7ContentInfo
+	MacData  pkcs12MacData `asn1:"optional"`
+}
+
+type pkcs12MacData struct {
+	Mac        pkcs12DigestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type pkcs12DigestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pkcs12SafeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue   `asn1:"tag:0,optional"`
+	SignerInfos      []asn1.RawValue `asn1:"set"`
+}
+
+// pkcs12SetFriendlyName adds a friendlyName attribute to the private key in a
+// PKCS#12 bundle created by go-pkcs12, which does not support setting one, and
+// updates the MAC to match. The private key is held in an unencrypted
+// SafeContents (the key itself is shrouded), so the bundle does not need to be
+// decrypted to do this.
+func pkcs12SetFriendlyName(pfxData []byte, password, name string) ([]byte, error) {
+	var pfx pkcs12PFX
+	if _, err := asn1.Unmarshal(pfxData, &pfx); err != nil {
+		return nil, fmt.Errorf("error reading PKCS#12 bundle: %w", err)
+	}
+
+	var authSafeData []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafeData); err != nil {
+		return nil, fmt.Errorf("error reading PKCS#12 bundle: %w", err)
+	}
+
+	var authSafe []pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(authSafeData, &authSafe); err != nil {
+		return nil, fmt.Errorf("error reading PKCS#12 bundle: %w", err)
+	}
+
+	bmpName, err := bmpString(name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedName, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpName})
+	if err != nil {
+		return nil, err
+	}
+
+	friendlyName := pkcs12Attribute{
+		ID:    oidPKCS9FriendlyName,
+		Value: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: encodedName},
+	}
+
+	found := false
+	for i, ci := range authSafe {
+		if !ci.ContentType.Equal(oidPKCS7Data) {
+			continue
+		}
+
+		var data []byte
+		if _, err := asn1.Unmarshal(ci.Content.Bytes, &data); err != nil {
+			return nil, fmt.Errorf("error reading PKCS#12 bundle: %w", err)
+		}
+
+		var bags []pkcs12SafeBag
+		if _, err := asn1.Unmarshal(data, &bags); err != nil {
+			return nil, fmt.Errorf("error reading PKCS#12 bundle: %w", err)
+		}
+
+		for j, bag := range bags {
+			if bag.ID.Equal(oidPKCS12ShroudedKeyBag) || bag.ID.Equal(oidPKCS12KeyBag) {
+				bags[j].Attributes = append(bags[j].Attributes, friendlyName)
+				found = true
+			}
+		}
+
+		if data, err = asn1.Marshal(bags); err != nil {
+			return nil, err
+		}
+
+		if authSafe[i].Content, err = pkcs7ExplicitContent(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if !found {
+		return nil, errors.New("error setting PKCS#12 friendly name: private key not found")
+	}
+
+	if authSafeData, err = asn1.Marshal(authSafe); err != nil {
+		return nil, err
+	}
+
+	if pfx.AuthSafe.Content, err = pkcs7ExplicitContent(authSafeData); err != nil {
+		return nil, err
+	}
+
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) > 0 {
+		if pfx.MacData.Mac.Digest, err = pkcs12MAC(pfx.MacData, authSafeData, password); err != nil {
+			return nil, err
+		}
+	}
+
+	return asn1.Marshal(pfx)
+}
+
+// pkcs7ExplicitContent returns the content of a ContentInfo of type data, as
+// an explicitly tagged OCTET STRING.
+func pkcs7ExplicitContent(data []byte) (asn1.RawValue, error) {
+	octets, err := asn1.Marshal(data)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: octets}, nil
+}
+
+// pkcs12MAC computes the MAC of a PKCS#12 bundle, with the integrity key
+// derived as per RFC 7292 appendix B.2. Only the SHA-1 and SHA-256 MACs used
+// by the go-pkcs12 encoders in pkcs12Encoder are supported. As the key is the
+// same length as the hash, the key derivation only needs a single round.
+func pkcs12MAC(macData pkcs12MacData, message []byte, password string) ([]byte, error) {
+	var h func() hash.Hash
+	switch {
+	case macData.Mac.Algorithm.Algorithm.Equal(oidSHA1):
+		h = sha1.New
+	case macData.Mac.Algorithm.Algorithm.Equal(oidSHA256):
+		h = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported PKCS#12 MAC algorithm %s", macData.Mac.Algorithm.Algorithm)
+	}
+
+	bmpPassword, err := bmpString(password, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both SHA-1 and SHA-256 have a 64 byte block size.
+	const v = 64
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+
+		n := v * ((len(b) + v - 1) / v)
+		return bytes.Repeat(b, (n+len(b)-1)/len(b))[:n]
+	}
+
+	input := append(bytes.Repeat([]byte{3}, v), fill(macData.MacSalt)...)
+	input = append(input, fill(bmpPassword)...)
+	digest := input
+	for i := 0; i < max(macData.Iterations, 1); i++ {
+		hh := h()
+		hh.Write(digest)
+		digest = hh.Sum(nil)
+	}
+
+	mac := hmac.New(h, digest)
+	mac.Write(message)
+	return mac.Sum(nil), nil
+}
+
+// bmpString returns s encoded as a BMPString (UCS-2), optionally with a zero
+// terminator as used for PKCS#12 passwords.
+func bmpString(s string, terminate bool) ([]byte, error) {
+	var result []byte
+	for _, r := range s {
+		if utf16.IsSurrogate(r) || r > 0xffff {
+			return nil, fmt.Errorf("%q contains characters that cannot be encoded in UCS-2", s)
+		}
+
+		result = append(result, byte(r>>8), byte(r))
+	}
+
+	if terminate {
+		result = append(result, 0, 0)
+	}
+
+	return result, nil
+}
+
+// bundleToPKCS7 packs an issued certificate and any intermediates into a
+// certificates-only PKCS#7 bundle (.p7b), in PEM format.
+func bundleToPKCS7(bundle []byte) ([]byte, error) {
+	cb, err := parsePEMBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []byte
+	for _, c := range cb {
+		certs = append(certs, c.Raw...)
+	}
+
+	signedData, err := asn1.Marshal(pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{},
+		ContentInfo:      pkcs7ContentInfo{ContentType: oidPKCS7Data},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos:      []asn1.RawValue{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidPKCS7SignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: preamblePKCS7, Bytes: result}), nil
+}
+
+// bundleToJKS packs an issued certificate, any intermediates, and the private
+// key into a Java KeyStore (JKS), with a single private key entry under the
+// supplied alias. The key and the keystore are both protected with the
+// supplied password.
+//
+// The returned keystore is base64-encoded.
+func bundleToJKS(bundle, key []byte, password, alias string) ([]byte, error) {
+	cb, err := parsePEMBundle(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	if cb[0].IsCA {
+		return nil, fmt.Errorf("first certificate is a CA certificate")
+	}
+
+	pk, err := privateKeyFromPEM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(pk)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling private key: %w", err)
+	}
+
+	passwordBytes := jksPassword(password)
+	protected, err := jksProtectKey(der, passwordBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if alias == "" {
+		alias = defaultJKSAlias
+	}
+
+	// Java lower-cases aliases when loading a keystore.
+	alias = strings.ToLower(alias)
+	if len(alias) > 0xffff {
+		return nil, errors.New("JKS alias is too long")
+	}
+
+	var buf bytes.Buffer
+	write := func(v any) {
+		// Writes to a bytes.Buffer do not fail.
+		_ = binary.Write(&buf, binary.BigEndian, v)
+	}
+	writeUTF := func(s string) {
+		write(uint16(len(s)))
+		buf.WriteString(s)
+	}
+
+	write(uint32(0xfeedfeed)) // magic
+	write(uint32(2))          // version
+	write(uint32(1))          // entries
+
+	write(uint32(1)) // private key entry
+	writeUTF(alias)
+	write(cb[0].NotBefore.UnixMilli())
+	write(uint32(len(protected)))
+	buf.Write(protected)
+	write(uint32(len(cb)))
+	for _, c := range cb {
+		writeUTF("X.509")
+		write(uint32(len(c.Raw)))
+		buf.Write(c.Raw)
+	}
+
+	digest := sha1.New()
+	digest.Write(passwordBytes)
+	digest.Write([]byte("Mighty Aphrodite"))
+	digest.Write(buf.Bytes())
+	buf.Write(digest.Sum(nil))
+
+	result := make([]byte, base64.StdEncoding.EncodedLen(buf.Len()))
+	base64.StdEncoding.Encode(result, buf.Bytes())
+	return result, nil
+}
+
+// jksPassword returns a password as it is used for JKS key protection and
+// integrity: UTF-16, big-endian, with no terminator.
+func jksPassword(password string) []byte {
+	var result []byte
+	for _, c := range utf16.Encode([]rune(password)) {
+		result = append(result, byte(c>>8), byte(c))
+	}
+
+	return result
+}
+
+// jksProtectKey encrypts a PKCS#8 private key with the proprietary key
+// protection algorithm used in JKS files (sun.security.provider.KeyProtector),
+// and returns it as an EncryptedPrivateKeyInfo.
+//
+// The key is XORed with a keystream of chained SHA-1 digests of the password
+// and a random salt, and is followed by a SHA-1 digest of the password and the
+// plaintext key for integrity.
+func jksProtectKey(der, password []byte) ([]byte, error) {
+	salt := make([]byte, sha1.Size)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	protected := append([]byte{}, salt...)
+	digest := salt
+	for i := 0; i < len(der); i += sha1.Size {
+		h := sha1.New()
+		h.Write(password)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for j := 0; j < sha1.Size && i+j < len(der); j++ {
+			protected = append(protected, der[i+j]^digest[j])
+		}
+	}
+
+	h := sha1.New()
+	h.Write(password)
+	h.Write(der)
+	protected = h.Sum(protected)
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidJKSKeyProtector, Parameters: asn1.NullRawValue},
+		EncryptedData: protected,
+	})
+}
@@ -0,0 +1,391 @@
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestBundleToPKCS12(t *testing.T) {
+	testCases := []struct {
+		name         string
+		encoding     string
+		friendlyName string
+	}{
+		{
+			name:     "modern",
+			encoding: p12EncodingModern,
+		},
+		{
+			name:         "modern with friendly name",
+			encoding:     p12EncodingModern,
+			friendlyName: "My Certificate",
+		},
+		{
+			name:         "legacy RC2 with friendly name",
+			encoding:     p12EncodingLegacyRC2,
+			friendlyName: "My Certificate",
+		},
+		{
+			name:         "legacy DES with friendly name",
+			encoding:     p12EncodingLegacyDES,
+			friendlyName: "My Certificate",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, password := range []string{"", "password"} {
+				pfxB64, err := bundleToPKCS12([]byte(testPaddingBundle), []byte(testPrivateKeyPKCS1Text), certificateEncodeOptions{
+					Password:     password,
+					P12Encoding:  tc.encoding,
+					FriendlyName: tc.friendlyName,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				pfxData, err := base64.StdEncoding.DecodeString(string(pfxB64))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// DecodeChain checks the MAC, which is re-computed when the friendly
+				// name is set.
+				_, cert, caCerts, err := pkcs12.DecodeChain(pfxData, password)
+				if err != nil {
+					t.Fatalf("error decoding bundle with password %q: %s", password, err)
+				}
+
+				if len(caCerts) != 1 || cert == nil {
+					t.Fatalf("expected certificate and 1 CA certificate, got %d CA certificates", len(caCerts))
+				}
+
+				if actual := testPKCS12KeyFriendlyName(t, pfxData); actual != tc.friendlyName {
+					t.Fatalf("expected friendly name %q, got %q", tc.friendlyName, actual)
+				}
+			}
+		})
+	}
+}
+
+// TestBundleToPKCS12OpenSSL checks that the friendly name set by
+// bundleToPKCS12 can be read by OpenSSL, if it is installed.
+func TestBundleToPKCS12OpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found in PATH")
+	}
+
+	for _, encoding := range []string{p12EncodingModern, p12EncodingLegacyDES} {
+		t.Run(encoding, func(t *testing.T) {
+			pfxB64, err := bundleToPKCS12([]byte(testPaddingBundle), []byte(testPrivateKeyPKCS1Text), certificateEncodeOptions{
+				Password:     "password",
+				P12Encoding:  encoding,
+				FriendlyName: "my-alias",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pfxData, err := base64.StdEncoding.DecodeString(string(pfxB64))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(t.TempDir(), "cert.p12")
+			testWriteFile(t, path, pfxData)
+
+			out, err := exec.Command(openssl, "pkcs12", "-info", "-in", path, "-passin", "pass:password", "-nodes").CombinedOutput()
+			if err != nil {
+				t.Fatalf("openssl failed to read bundle: %s\n%s", err, out)
+			}
+
+			if !strings.Contains(string(out), "friendlyName: my-alias") {
+				t.Fatalf("expected friendly name in openssl output, got:\n%s", out)
+			}
+		})
+	}
+}
+
+// testPKCS12KeyFriendlyName returns the friendly name of the private key in a
+// PKCS#12 bundle.
+func testPKCS12KeyFriendlyName(t *testing.T, pfxData []byte) string {
+	t.Helper()
+
+	var pfx pkcs12PFX
+	var authSafeData []byte
+	var authSafe []pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(pfxData, &pfx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafeData); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := asn1.Unmarshal(authSafeData, &authSafe); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ci := range authSafe {
+		if !ci.ContentType.Equal(oidPKCS7Data) {
+			continue
+		}
+
+		var data []byte
+		var bags []pkcs12SafeBag
+		if _, err := asn1.Unmarshal(ci.Content.Bytes, &data); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := asn1.Unmarshal(data, &bags); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, bag := range bags {
+			for _, attr := range bag.Attributes {
+				if !attr.ID.Equal(oidPKCS9FriendlyName) {
+					continue
+				}
+
+				var name asn1.RawValue
+				if _, err := asn1.Unmarshal(attr.Value.Bytes, &name); err != nil {
+					t.Fatal(err)
+				}
+
+				var result []rune
+				for i := 0; i+1 < len(name.Bytes); i += 2 {
+					result = append(result, rune(name.Bytes[i])<<8|rune(name.Bytes[i+1]))
+				}
+
+				return string(result)
+			}
+		}
+	}
+
+	return ""
+}
+
+func TestBundleToPKCS7(t *testing.T) {
+	p7b, err := bundleToPKCS7([]byte(testPaddingBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(p7b)
+	if block == nil || block.Type != preamblePKCS7 {
+		t.Fatalf("expected PKCS7 PEM block, got:\n%s", p7b)
+	}
+
+	var ci pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(block.Bytes, &ci); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ci.ContentType.Equal(oidPKCS7SignedData) {
+		t.Fatalf("expected signedData content type, got %s", ci.ContentType)
+	}
+
+	var sd pkcs7SignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		t.Fatal(err)
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := parsePEMBundle([]byte(testPaddingBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(certs) != len(expected) {
+		t.Fatalf("expected %d certificates, got %d", len(expected), len(certs))
+	}
+
+	for i := range certs {
+		if !certs[i].Equal(expected[i]) {
+			t.Fatalf("certificate %d does not match", i)
+		}
+	}
+
+	if openssl, err := exec.LookPath("openssl"); err == nil {
+		path := filepath.Join(t.TempDir(), "cert.p7b")
+		testWriteFile(t, path, p7b)
+		out, err := exec.Command(openssl, "pkcs7", "-in", path, "-print_certs", "-noout").CombinedOutput()
+		if err != nil {
+			t.Fatalf("openssl failed to read bundle: %s\n%s", err, out)
+		}
+
+		if n := strings.Count(string(out), "subject="); n != len(expected) {
+			t.Fatalf("expected openssl to print %d certificates, got:\n%s", len(expected), out)
+		}
+	}
+}
+
+func TestBundleToJKS(t *testing.T) {
+	testCases := []struct {
+		name          string
+		password      string
+		alias         string
+		expectedAlias string
+	}{
+		{
+			name:          "default alias",
+			password:      "changeit",
+			expectedAlias: defaultJKSAlias,
+		},
+		{
+			name:          "friendly name",
+			password:      "changeit",
+			alias:         "My-Alias",
+			expectedAlias: "my-alias",
+		},
+		{
+			name:          "no password",
+			expectedAlias: defaultJKSAlias,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jksB64, err := bundleToJKS([]byte(testPaddingBundle), []byte(testPrivateKeyPKCS1Text), tc.password, tc.alias)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := base64.StdEncoding.DecodeString(string(jksB64))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			password := jksPassword(tc.password)
+			body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+			h := sha1.New()
+			h.Write(password)
+			h.Write([]byte("Mighty Aphrodite"))
+			h.Write(body)
+			if !bytes.Equal(h.Sum(nil), digest) {
+				t.Fatal("keystore integrity check failed")
+			}
+
+			r := bytes.NewReader(body)
+			read := func(v any) {
+				t.Helper()
+				if err := binary.Read(r, binary.BigEndian, v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			readBytes := func(n int) []byte {
+				t.Helper()
+				b := make([]byte, n)
+				if _, err := r.Read(b); err != nil {
+					t.Fatal(err)
+				}
+				return b
+			}
+
+			var magic, version, count, tag, keyLen, chainLen uint32
+			var aliasLen uint16
+			var timestamp int64
+			read(&magic)
+			read(&version)
+			read(&count)
+			read(&tag)
+			if magic != 0xfeedfeed || version != 2 || count != 1 || tag != 1 {
+				t.Fatalf("unexpected header: magic %x, version %d, count %d, tag %d", magic, version, count, tag)
+			}
+
+			read(&aliasLen)
+			if alias := string(readBytes(int(aliasLen))); alias != tc.expectedAlias {
+				t.Fatalf("expected alias %q, got %q", tc.expectedAlias, alias)
+			}
+
+			read(&timestamp)
+			read(&keyLen)
+			var info encryptedPrivateKeyInfo
+			if _, err := asn1.Unmarshal(readBytes(int(keyLen)), &info); err != nil {
+				t.Fatal(err)
+			}
+
+			if !info.Algorithm.Algorithm.Equal(oidJKSKeyProtector) {
+				t.Fatalf("unexpected key protection algorithm %s", info.Algorithm.Algorithm)
+			}
+
+			// Recover the key, as per sun.security.provider.KeyProtector.
+			protected := info.EncryptedData
+			salt := protected[:sha1.Size]
+			encrypted := protected[sha1.Size : len(protected)-sha1.Size]
+			checksum := protected[len(protected)-sha1.Size:]
+			der := make([]byte, len(encrypted))
+			keystream := salt
+			for i := 0; i < len(encrypted); i += sha1.Size {
+				h := sha1.New()
+				h.Write(password)
+				h.Write(keystream)
+				keystream = h.Sum(nil)
+				for j := 0; j < sha1.Size && i+j < len(encrypted); j++ {
+					der[i+j] = encrypted[i+j] ^ keystream[j]
+				}
+			}
+
+			h = sha1.New()
+			h.Write(password)
+			h.Write(der)
+			if !bytes.Equal(h.Sum(nil), checksum) {
+				t.Fatal("key checksum does not match")
+			}
+
+			key, err := x509.ParsePKCS8PrivateKey(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expectedKey, err := privateKeyFromPEM([]byte(testPrivateKeyPKCS1Text))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !expectedKey.(interface{ Equal(crypto.PrivateKey) bool }).Equal(key) {
+				t.Fatal("recovered key does not match")
+			}
+
+			expected, err := parsePEMBundle([]byte(testPaddingBundle))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			read(&chainLen)
+			if int(chainLen) != len(expected) {
+				t.Fatalf("expected chain of %d certificates, got %d", len(expected), chainLen)
+			}
+
+			for i := range expected {
+				var typeLen uint16
+				var certLen uint32
+				read(&typeLen)
+				if certType := string(readBytes(int(typeLen))); certType != "X.509" {
+					t.Fatalf("unexpected certificate type %q", certType)
+				}
+
+				read(&certLen)
+				if !bytes.Equal(readBytes(int(certLen)), expected[i].Raw) {
+					t.Fatalf("certificate %d does not match", i)
+				}
+			}
+
+			if r.Len() != 0 {
+				t.Fatalf("unexpected trailing data: %d bytes", r.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// certificateInfoSchema returns the schema for the computed certificate_info
+// block.
+func certificateInfoSchema() *schema.Schema {
+	computedString := func() *schema.Schema {
+		return &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		}
+	}
+	computedList := func() *schema.Schema {
+		return &schema.Schema{
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		}
+	}
+
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Computed: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"sha256_fingerprint":  computedString(),
+				"sha1_fingerprint":    computedString(),
+				"spki_sha256":         computedString(),
+				"subject":             computedString(),
+				"issuer":              computedString(),
+				"authority_key_id":    computedString(),
+				"subject_key_id":      computedString(),
+				"signature_algorithm": computedString(),
+				"key_algorithm":       computedString(),
+				"key_size": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"dns_names":                computedList(),
+				"ip_addresses":             computedList(),
+				"email_addresses":          computedList(),
+				"uris":                     computedList(),
+				"crl_distribution_points":  computedList(),
+				"ocsp_servers":             computedList(),
+				"issuing_certificate_urls": computedList(),
+				"policy_identifiers":       computedList(),
+			},
+		},
+	}
+}
+
+// flattenCertificateInfo returns the certificate_info block for a
+// certificate.
+func flattenCertificateInfo(cert *x509.Certificate) []any {
+	sha256Sum := sha256.Sum256(cert.Raw)
+	sha1Sum := sha1.Sum(cert.Raw)
+	spkiSum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	keyAlgorithm, keySize := certificatePublicKeyInfo(cert)
+
+	ips := make([]string, len(cert.IPAddresses))
+	for i, ip := range cert.IPAddresses {
+		ips[i] = ip.String()
+	}
+
+	uris := make([]string, len(cert.URIs))
+	for i, uri := range cert.URIs {
+		uris[i] = uri.String()
+	}
+
+	policies := make([]string, len(cert.Policies))
+	for i, oid := range cert.Policies {
+		policies[i] = oid.String()
+	}
+
+	return []any{
+		map[string]any{
+			"sha256_fingerprint":       fingerprintHex(sha256Sum[:]),
+			"sha1_fingerprint":         fingerprintHex(sha1Sum[:]),
+			"spki_sha256":              base64.StdEncoding.EncodeToString(spkiSum[:]),
+			"subject":                  cert.Subject.String(),
+			"issuer":                   cert.Issuer.String(),
+			"authority_key_id":         fingerprintHex(cert.AuthorityKeyId),
+			"subject_key_id":           fingerprintHex(cert.SubjectKeyId),
+			"signature_algorithm":      cert.SignatureAlgorithm.String(),
+			"key_algorithm":            keyAlgorithm,
+			"key_size":                 keySize,
+			"dns_names":                cert.DNSNames,
+			"ip_addresses":             ips,
+			"email_addresses":          cert.EmailAddresses,
+			"uris":                     uris,
+			"crl_distribution_points":  cert.CRLDistributionPoints,
+			"ocsp_servers":             cert.OCSPServer,
+			"issuing_certificate_urls": cert.IssuingCertificateURL,
+			"policy_identifiers":       policies,
+		},
+	}
+}
+
+// certificatePublicKeyInfo returns the algorithm and size in bits of the
+// public key of a certificate.
+func certificatePublicKeyInfo(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", ed25519.PublicKeySize * 8
+	}
+
+	return cert.PublicKeyAlgorithm.String(), 0
+}
+
+// fingerprintHex formats a digest or key identifier as colon-separated,
+// uppercase hex, in the same way as OpenSSL.
+func fingerprintHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i := range b {
+		parts[i] = strings.ToUpper(hex.EncodeToString(b[i : i+1]))
+	}
+
+	return strings.Join(parts, ":")
+}
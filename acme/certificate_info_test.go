@@ -0,0 +1,94 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFingerprintHex(t *testing.T) {
+	if actual := fingerprintHex([]byte{0x0a, 0xbc, 0xff}); actual != "0A:BC:FF" {
+		t.Fatalf("expected 0A:BC:FF, got %q", actual)
+	}
+
+	if actual := fingerprintHex(nil); actual != "" {
+		t.Fatalf("expected empty string, got %q", actual)
+	}
+}
+
+func TestFlattenCertificateInfo(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri, _ := url.Parse("spiffe://example.com/service")
+	policy, err := x509.OIDFromInts([]uint64{2, 23, 140, 1, 2, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "www.example.com", Organization: []string{"Example"}},
+		DNSNames:              []string{"www.example.com", "example.com"},
+		IPAddresses:           []net.IP{net.ParseIP("192.0.2.1")},
+		EmailAddresses:        []string{"nobody@example.com"},
+		URIs:                  []*url.URL{uri},
+		CRLDistributionPoints: []string{"http://crl.example.com/ca.crl"},
+		OCSPServer:            []string{"http://ocsp.example.com"},
+		IssuingCertificateURL: []string{"http://ca.example.com/ca.der"},
+		Policies:              []x509.OID{policy},
+		SubjectKeyId:          []byte{0x01, 0x02},
+		AuthorityKeyId:        []byte{0x03, 0x04},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := flattenCertificateInfo(cert)[0].(map[string]any)
+	expected := map[string]any{
+		"subject":                  "CN=www.example.com,O=Example",
+		"issuer":                   "CN=www.example.com,O=Example",
+		"subject_key_id":           "01:02",
+		"authority_key_id":         "03:04",
+		"signature_algorithm":      "ECDSA-SHA384",
+		"key_algorithm":            "ECDSA",
+		"key_size":                 384,
+		"dns_names":                []string{"www.example.com", "example.com"},
+		"ip_addresses":             []string{"192.0.2.1"},
+		"email_addresses":          []string{"nobody@example.com"},
+		"uris":                     []string{"spiffe://example.com/service"},
+		"crl_distribution_points":  []string{"http://crl.example.com/ca.crl"},
+		"ocsp_servers":             []string{"http://ocsp.example.com"},
+		"issuing_certificate_urls": []string{"http://ca.example.com/ca.der"},
+		"policy_identifiers":       []string{"2.23.140.1.2.1"},
+	}
+	for k, v := range expected {
+		if !reflect.DeepEqual(v, info[k]) {
+			t.Errorf("expected %s to be %#v, got %#v", k, v, info[k])
+		}
+	}
+
+	for k, length := range map[string]int{"sha256_fingerprint": 32*3 - 1, "sha1_fingerprint": 20*3 - 1, "spki_sha256": 44} {
+		if actual := info[k].(string); len(actual) != length {
+			t.Errorf("expected %s to be %d characters, got %q", k, length, actual)
+		}
+	}
+}
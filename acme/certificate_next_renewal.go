@@ -0,0 +1,129 @@
+package acme
+
+import (
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Values for renewal_reason.
+const (
+	renewalReasonPendingOrder     = "pending_order"
+	renewalReasonRevoked          = "revoked"
+	renewalReasonARIWindow        = "ari_window"
+	renewalReasonRenewBefore      = "renew_before"
+	renewalReasonMinDays          = "min_days"
+	renewalReasonDynamicThreshold = "dynamic_threshold"
+	renewalReasonValidityChanged  = "validity_changed"
+	renewalReasonRenewalTriggers  = "renewal_triggers"
+	renewalReasonKeyRotation      = "key_rotation"
+)
+
+// certificateRenewal is when, and why, a certificate is next renewed.
+type certificateRenewal struct {
+	// The time from which the renewal is planned.
+	DueAt time.Time
+
+	// The time that the certificate is renewed. This is later than DueAt when
+	// the update sleeps until the time selected from the ARI window, or when
+	// the renewal is deferred to a renewal window.
+	At time.Time
+
+	// The renewal_reason value.
+	Reason string
+}
+
+// resourceACMECertificateRenewalDueAt returns when the certificate is due for
+// renewal, before the renewal window is taken into account. The zero value is
+// returned if the certificate is never renewed.
+func resourceACMECertificateRenewalDueAt(d resourceDataOrDiff, now time.Time) (certificateRenewal, error) {
+	// An order that failed to complete is resumed on the next apply.
+	if resourceACMECertificateOrderPending(d) {
+		return certificateRenewal{DueAt: now, At: now, Reason: renewalReasonPendingOrder}, nil
+	}
+
+	// A revoked certificate is always reissued.
+	if resourceACMECertificateRevoked(d) {
+		return certificateRenewal{DueAt: now, At: now, Reason: renewalReasonRevoked}, nil
+	}
+
+	var ari certificateRenewal
+	if d.Get("use_renewal_info").(bool) {
+		selected, err := resourceACMECertificateRenewalInfoSelected(d)
+		if err != nil {
+			return certificateRenewal{}, err
+		}
+
+		// The renewal is planned once the update can sleep until the selected
+		// time.
+		ari = certificateRenewal{
+			DueAt:  selected.Add(-time.Second * time.Duration(d.Get("renewal_info_max_sleep").(int))),
+			At:     selected,
+			Reason: renewalReasonARIWindow,
+		}
+	}
+
+	renewAt, reason, err := resourceACMECertificateRenewalThreshold(d, now)
+	if err != nil {
+		return certificateRenewal{}, err
+	}
+
+	if !ari.DueAt.IsZero() && (renewAt.IsZero() || ari.DueAt.Before(renewAt)) {
+		return ari, nil
+	}
+
+	return certificateRenewal{DueAt: renewAt, At: renewAt, Reason: reason}, nil
+}
+
+// resourceACMECertificateNextRenewal returns when the certificate is next
+// renewed, including any deferral to the renewal window.
+func resourceACMECertificateNextRenewal(d resourceDataOrDiff, now time.Time) (certificateRenewal, error) {
+	r, err := resourceACMECertificateRenewalDueAt(d, now)
+	if err != nil || r.DueAt.IsZero() {
+		return r, err
+	}
+
+	// Check the renewal window at the time the renewal is planned.
+	plannedAt := r.DueAt
+	if plannedAt.Before(now) {
+		plannedAt = now
+	}
+
+	deferredUntil, _, err := resourceACMECertificateRenewalWindowCheck(d, plannedAt)
+	if err != nil {
+		return certificateRenewal{}, err
+	}
+
+	if deferredUntil.After(r.At) {
+		r.At = deferredUntil
+	}
+
+	return r, nil
+}
+
+// String returns the next_renewal_at value, which is empty if the certificate
+// is never renewed.
+func (r certificateRenewal) String() string {
+	if r.At.IsZero() {
+		return ""
+	}
+
+	return r.At.UTC().Format(time.RFC3339)
+}
+
+// resourceACMECertificateNextRenewalRefresh sets next_renewal_at, and
+// renewal_reason if setReason is true. renewal_reason is not set at the end of
+// an update, as the reason for the renewal is part of the plan.
+func resourceACMECertificateNextRenewalRefresh(d *schema.ResourceData, now time.Time, setReason bool) {
+	r, err := resourceACMECertificateNextRenewal(d, now)
+	if err != nil {
+		log.Printf("[WARN] unable to calculate the next renewal of the certificate: %s", err)
+		r = certificateRenewal{}
+	}
+
+	d.Set("next_renewal_at", r.String())
+	if setReason {
+		d.Set("renewal_reason", r.Reason)
+	}
+}
@@ -0,0 +1,135 @@
+package acme
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResourceACMECertificateNextRenewal(t *testing.T) {
+	// A Friday evening, with a 90 day certificate that expires in 60 days.
+	now := time.Date(2026, 6, 5, 20, 0, 0, 0, time.UTC)
+	notAfter := now.Add(60 * 24 * time.Hour)
+	b := testGenerateCertificateBundle(t, notAfter.Add(-90*24*time.Hour), notAfter, []string{"www.example.com"}, nil)
+
+	testCases := []struct {
+		name           string
+		attrs          map[string]string
+		expectedAt     time.Time
+		expectedReason string
+	}{
+		{
+			name:           "min_days_remaining",
+			attrs:          map[string]string{"min_days_remaining": "30"},
+			expectedAt:     notAfter.Add(-30 * 24 * time.Hour),
+			expectedReason: renewalReasonMinDays,
+		},
+		{
+			name:           "renew_before",
+			attrs:          map[string]string{"renew_before": "10%"},
+			expectedAt:     notAfter.Add(-9 * 24 * time.Hour),
+			expectedReason: renewalReasonRenewBefore,
+		},
+		{
+			name:           "dynamic",
+			attrs:          map[string]string{"min_days_dynamic": "true"},
+			expectedAt:     notAfter.Add(-30 * 24 * time.Hour),
+			expectedReason: renewalReasonDynamicThreshold,
+		},
+		{
+			name:  "never",
+			attrs: map[string]string{"min_days_remaining": "-1"},
+		},
+		{
+			name: "revoked",
+			attrs: map[string]string{
+				"min_days_remaining": "30",
+				"revocation_status":  revocationStatusRevoked,
+			},
+			expectedAt:     now,
+			expectedReason: renewalReasonRevoked,
+		},
+		{
+			name: "pending order",
+			attrs: map[string]string{
+				"min_days_remaining": "30",
+				"pending_order_url":  "https://example.com/order/1",
+			},
+			expectedAt:     now,
+			expectedReason: renewalReasonPendingOrder,
+		},
+		{
+			name: "ari window first",
+			attrs: map[string]string{
+				"min_days_remaining":           "30",
+				"use_renewal_info":             "true",
+				"renewal_info_max_sleep":       "600",
+				"renewal_info_window_selected": "2026-07-01T10:00:00Z",
+			},
+			expectedAt:     time.Date(2026, 7, 1, 10, 0, 0, 0, time.UTC),
+			expectedReason: renewalReasonARIWindow,
+		},
+		{
+			name: "threshold before ari window",
+			attrs: map[string]string{
+				"min_days_remaining":           "30",
+				"use_renewal_info":             "true",
+				"renewal_info_window_selected": "2026-07-20T10:00:00Z",
+			},
+			expectedAt:     notAfter.Add(-30 * 24 * time.Hour),
+			expectedReason: renewalReasonMinDays,
+		},
+		{
+			// The threshold is reached on Sunday 2026-07-05 at 20:00, which is
+			// deferred to the Tuesday window.
+			name: "renewal window",
+			attrs: map[string]string{
+				"min_days_remaining":             "30",
+				"renewal_window.#":               "1",
+				"renewal_window.0.weekdays.#":    "1",
+				"renewal_window.0.weekdays.0":    "tuesday",
+				"renewal_window.0.timezone":      "UTC",
+				"renewal_window.0.safety_margin": "24h",
+			},
+			expectedAt:     time.Date(2026, 7, 7, 0, 0, 0, 0, time.UTC),
+			expectedReason: renewalReasonMinDays,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			attrs := map[string]string{
+				"certificate_pem": string(b.CertPEM),
+				"issuer_pem":      string(b.IssuerPEM),
+			}
+			for k, v := range tc.attrs {
+				attrs[k] = v
+			}
+
+			d := resourceACMECertificate().Data(&terraform.InstanceState{ID: "certificate", Attributes: attrs})
+			actual, err := resourceACMECertificateNextRenewal(d, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !actual.At.Equal(tc.expectedAt) {
+				t.Fatalf("expected next renewal at %s, got %s", tc.expectedAt, actual.At)
+			}
+
+			if actual.Reason != tc.expectedReason {
+				t.Fatalf("expected reason %q, got %q", tc.expectedReason, actual.Reason)
+			}
+
+			// The renewal is due at the same point as the renewal check.
+			due, err := resourceACMECertificateRenewalDue(d, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected := !actual.DueAt.IsZero() && !now.Before(actual.DueAt); due != expected {
+				t.Fatalf("expected due to be %t, got %t", expected, due)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/crypto/ocsp"
+)
+
+// resourceACMECertificateOCSPEnabled returns true if the OCSP response for the
+// certificate should be fetched.
+func resourceACMECertificateOCSPEnabled(d resourceDataOrDiff) bool {
+	return d.Get("must_staple").(bool) || d.Get("fetch_ocsp_response").(bool)
+}
+
+// ocspResponseNeedsRefresh returns true if resp should be replaced with a
+// fresh response. Responses are refreshed once they are halfway through their
+// validity period, so that there is time to retry before the staple expires.
+// Responses without a next update time are always refreshed.
+func ocspResponseNeedsRefresh(resp *ocsp.Response, now time.Time) bool {
+	if resp.NextUpdate.IsZero() {
+		return true
+	}
+
+	return now.After(resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2))
+}
+
+// fetchCertificateOCSPResponse fetches a response for cert from the OCSP
+// responders in the certificate, trying each in turn. It returns a nil
+// response if the certificate has no OCSP responders.
+func fetchCertificateOCSPResponse(cert, issuer *x509.Certificate) (*ocsp.Response, error) {
+	client := &http.Client{Timeout: revocationCheckTimeout}
+	var errs []error
+	for _, u := range cert.OCSPServer {
+		resp, err := fetchOCSPResponse(client, u, cert, issuer)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if resp.Status != ocsp.Good {
+			errs = append(errs, fmt.Errorf("OCSP %s: certificate status is not good", u))
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// resourceACMECertificateOCSPRefresh fetches the OCSP response for the
+// certificate in state if it is enabled and the response in state is missing,
+// does not match the certificate, or is due to be refreshed. A failed fetch is
+// logged rather than returned, and the response in state is kept until it
+// expires.
+func resourceACMECertificateOCSPRefresh(d *schema.ResourceData, now time.Time) error {
+	if !resourceACMECertificateOCSPEnabled(d) {
+		d.Set("ocsp_response", "")
+		d.Set("ocsp_next_update", "")
+		return nil
+	}
+
+	certs, err := parsePEMBundle([]byte(d.Get("certificate_pem").(string)))
+	if err != nil {
+		return err
+	}
+
+	issuers, err := parsePEMBundle([]byte(d.Get("issuer_pem").(string)))
+	if err != nil {
+		return fmt.Errorf("error reading issuer certificate for OCSP request: %w", err)
+	}
+
+	cert, issuer := certs[0], issuers[0]
+	var current *ocsp.Response
+	if v := d.Get("ocsp_response").(string); v != "" {
+		if der, err := base64.StdEncoding.DecodeString(v); err == nil {
+			// This fails if the response is for a previous certificate.
+			current, _ = ocsp.ParseResponseForCert(der, cert, issuer)
+		}
+	}
+
+	if current != nil && !ocspResponseNeedsRefresh(current, now) {
+		return nil
+	}
+
+	resp, err := fetchCertificateOCSPResponse(cert, issuer)
+	if err != nil {
+		log.Printf("[WARN] error fetching OCSP response for certificate %s: %s", cert.SerialNumber, err)
+	}
+
+	switch {
+	case resp != nil:
+		d.Set("ocsp_response", base64.StdEncoding.EncodeToString(resp.Raw))
+		d.Set("ocsp_next_update", formatOCSPNextUpdate(resp))
+
+	case current != nil && (current.NextUpdate.IsZero() || now.Before(current.NextUpdate)):
+		// Keep the current response while it's still valid.
+		d.Set("ocsp_next_update", formatOCSPNextUpdate(current))
+
+	default:
+		// The certificate has no OCSP responders, or there is no valid response.
+		d.Set("ocsp_response", "")
+		d.Set("ocsp_next_update", "")
+	}
+
+	return nil
+}
+
+func formatOCSPNextUpdate(resp *ocsp.Response) string {
+	if resp.NextUpdate.IsZero() {
+		return ""
+	}
+
+	return resp.NextUpdate.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,114 @@
+package acme
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestResourceACMECertificateOCSPRefresh(t *testing.T) {
+	r := newTestRevocationResponder(t)
+	cert := r.issue(t, 100)
+	d := blankCertificateResource()
+	d.Set("must_staple", true)
+	d.Set("certificate_pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	d.Set("issuer_pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: r.ca.Raw})))
+
+	checkResponse := func(t *testing.T, expectedRequests int) {
+		t.Helper()
+		if r.ocspRequests != expectedRequests {
+			t.Fatalf("expected %d OCSP requests, got %d", expectedRequests, r.ocspRequests)
+		}
+
+		der, err := base64.StdEncoding.DecodeString(d.Get("ocsp_response").(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := ocsp.ParseResponseForCert(der, cert, r.ca)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := resp.NextUpdate.UTC().Format(time.RFC3339); d.Get("ocsp_next_update").(string) != expected {
+			t.Fatalf("expected ocsp_next_update to be %s, got %s", expected, d.Get("ocsp_next_update"))
+		}
+	}
+
+	now := time.Now()
+	if err := resourceACMECertificateOCSPRefresh(d, now); err != nil {
+		t.Fatal(err)
+	}
+
+	checkResponse(t, 1)
+
+	// The response is kept until it's halfway through its validity period.
+	if err := resourceACMECertificateOCSPRefresh(d, now.Add(20*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	checkResponse(t, 1)
+
+	if err := resourceACMECertificateOCSPRefresh(d, now.Add(40*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	checkResponse(t, 2)
+
+	// The response is kept while it's valid if the responder is unavailable.
+	r.disableOCSP = true
+	if err := resourceACMECertificateOCSPRefresh(d, now.Add(50*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	checkResponse(t, 2)
+
+	if err := resourceACMECertificateOCSPRefresh(d, now.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Get("ocsp_response").(string) != "" || d.Get("ocsp_next_update").(string) != "" {
+		t.Fatal("expected expired OCSP response to be cleared")
+	}
+
+	// A new certificate gets a new response.
+	r.disableOCSP = false
+	cert = r.issue(t, 101)
+	d.Set("certificate_pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	if err := resourceACMECertificateOCSPRefresh(d, now); err != nil {
+		t.Fatal(err)
+	}
+
+	checkResponse(t, 3)
+
+	d.Set("must_staple", false)
+	if err := resourceACMECertificateOCSPRefresh(d, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Get("ocsp_response").(string) != "" {
+		t.Fatal("expected OCSP response to be cleared when disabled")
+	}
+}
+
+func TestResourceACMECertificateOCSPRefresh_noResponder(t *testing.T) {
+	certs, err := parsePEMBundle([]byte(testPaddingBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := blankCertificateResource()
+	d.Set("fetch_ocsp_response", true)
+	d.Set("certificate_pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certs[0].Raw})))
+	d.Set("issuer_pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certs[1].Raw})))
+	if err := resourceACMECertificateOCSPRefresh(d, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Get("ocsp_response").(string) != "" || d.Get("ocsp_next_update").(string) != "" {
+		t.Fatal("expected no OCSP response")
+	}
+}
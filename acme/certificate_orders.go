@@ -0,0 +1,288 @@
+package acme
+
+import (
+	"crypto"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/resolver"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// orderAuthorization is an authorization of the order that the certificate
+// was issued from, for the authorizations attribute.
+type orderAuthorization struct {
+	Identifier    string
+	URL           string
+	Status        string
+	ChallengeType string
+	ValidatedAt   time.Time
+
+	// True if no challenge was solved for the authorization while obtaining
+	// the certificate.
+	Reused bool
+}
+
+// flattenOrderAuthorizations returns the authorizations attribute.
+func flattenOrderAuthorizations(authorizations []orderAuthorization) []any {
+	result := make([]any, len(authorizations))
+	for i, a := range authorizations {
+		validatedAt := ""
+		if !a.ValidatedAt.IsZero() {
+			validatedAt = a.ValidatedAt.UTC().Format(time.RFC3339)
+		}
+
+		result[i] = map[string]any{
+			"identifier":     a.Identifier,
+			"url":            a.URL,
+			"status":         a.Status,
+			"challenge_type": a.ChallengeType,
+			"validated_at":   validatedAt,
+			"reused":         a.Reused,
+		}
+	}
+
+	return result
+}
+
+// Authorizations fetches the authorizations of an order that the obtainer
+// has completed.
+func (o *orderObtainer) Authorizations(order acme.ExtendedOrder) ([]orderAuthorization, error) {
+	var result []orderAuthorization
+	for _, authzURL := range order.Authorizations {
+		authz, err := o.core.Authorizations.Get(authzURL)
+		if err != nil {
+			return nil, err
+		}
+
+		a := orderAuthorization{
+			Identifier: challenge.GetTargetedDomain(authz),
+			URL:        authzURL,
+			Status:     authz.Status,
+			Reused:     !o.solved[authzURL],
+		}
+		for _, c := range authz.Challenges {
+			if c.Status == acme.StatusValid {
+				a.ChallengeType = c.Type
+				a.ValidatedAt = c.Validated
+				break
+			}
+		}
+
+		result = append(result, a)
+	}
+
+	return result, nil
+}
+
+// expandOrderObtainer returns an orderObtainer that solves challenges with
+// the providers set on client.
+func expandOrderObtainer(d *schema.ResourceData, meta any, client *lego.Client, user *acmeUser) (*orderObtainer, error) {
+	config := expandACMEClient_config(d, meta, user)
+	core, err := api.New(config.HTTPClient, config.UserAgent, config.CADirURL, user.GetRegistration().URI, user.GetPrivateKey())
+	if err != nil {
+		return nil, err
+	}
+
+	// The authorizations of a resumed order that were solved on an earlier
+	// run are not re-used.
+	solved := make(map[string]bool)
+	v, _ := d.GetChange("pending_order_solved_authorizations")
+	for _, authzURL := range stringSlice(v.([]any)) {
+		solved[authzURL] = true
+	}
+
+	return &orderObtainer{
+		core:     core,
+		resolver: resolver.NewProber(client.Challenge),
+		options:  config.Certificate,
+		solved:   solved,
+	}, nil
+}
+
+// certificateOrderPendingError is returned when the certificate could not be
+// obtained after its order was created. The order has been saved to state, and
+// is resumed on the next apply.
+type certificateOrderPendingError struct {
+	orderURL string
+	err      error
+}
+
+func (e *certificateOrderPendingError) Error() string {
+	return fmt.Sprintf("the certificate could not be obtained, and order %s will be resumed on the next apply: %s", e.orderURL, e.err)
+}
+
+func (e *certificateOrderPendingError) Unwrap() error {
+	return e.err
+}
+
+// resourceACMECertificateOrderPending returns true if there is an order in
+// pending_order_url that is resumed on the next apply. The value in state is
+// used, as pending_order_url is unknown in the plan while it is resumed.
+func resourceACMECertificateOrderPending(d resourceDataOrDiff) bool {
+	v, _ := d.GetChange("pending_order_url")
+	return v.(string) != ""
+}
+
+// resourceACMECertificateCreatePending returns true if the certificate has
+// not been created yet, because the order for it failed to complete. The
+// order is resumed by creating the certificate again.
+func resourceACMECertificateCreatePending(d resourceDataOrDiff) bool {
+	certURL, _ := d.GetChange("certificate_url")
+	return certURL.(string) == "" && resourceACMECertificateOrderPending(d)
+}
+
+// resourceACMECertificateOrderKey returns the private key for an order when
+// the key is generated by the provider. The key is generated before the
+// order is created, rather than when it is finalized, so that it can be saved
+// with the order in pending_order_private_key_pem. A resumed order may have
+// already been finalized, so its saved key is used again.
+func resourceACMECertificateOrderKey(d *schema.ResourceData, keyType certcrypto.KeyType) (crypto.PrivateKey, []byte, error) {
+	if v, _ := d.GetChange("pending_order_private_key_pem"); v.(string) != "" {
+		key, err := privateKeyFromPEM([]byte(v.(string)))
+		return key, []byte(v.(string)), err
+	}
+
+	key, err := certcrypto.GeneratePrivateKey(keyType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, certcrypto.PEMEncode(key), nil
+}
+
+// resourceACMECertificateRenewOrder renews the primary certificate, resuming
+// the order in pending_order_url if there is one. See
+// resourceACMECertificateObtain.
+func resourceACMECertificateRenewOrder(
+	d *schema.ResourceData,
+	meta any,
+	client *lego.Client,
+	user *acmeUser,
+	cert certificate.Resource,
+	options localRenewOptions,
+) (*certificate.Resource, error) {
+	request, err := renewRequest(cert, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return resourceACMECertificateObtain(d, meta, client, user, request)
+}
+
+// resourceACMECertificateObtain obtains the primary certificate for request,
+// resuming the order in pending_order_url if there is one, and sets
+// order_url and authorizations. If the certificate can't be obtained after the
+// order has been created, the order is checkpointed to state so that the next
+// apply can resume it, instead of creating a new order, and a
+// *certificateOrderPendingError is returned.
+func resourceACMECertificateObtain(
+	d *schema.ResourceData,
+	meta any,
+	client *lego.Client,
+	user *acmeUser,
+	request obtainRequest,
+) (*certificate.Resource, error) {
+	obtainer, err := expandOrderObtainer(d, meta, client, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyPEM []byte
+	if request.CSR == nil && request.PrivateKey == nil {
+		request.PrivateKey, keyPEM, err = resourceACMECertificateOrderKey(d, obtainer.options.KeyType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	orderURL, _ := d.GetChange("pending_order_url")
+
+	var order acme.ExtendedOrder
+	cert, err := obtainer.Obtain(request, orderURL.(string), func(o acme.ExtendedOrder) {
+		order = o
+	})
+	if err != nil {
+		if order.Location != "" {
+			return nil, resourceACMECertificateCheckpointOrder(d, obtainer, order, keyPEM, err)
+		}
+
+		return nil, err
+	}
+
+	resourceACMECertificateSetOrder(d, obtainer, order)
+	return cert, nil
+}
+
+// resourceACMECertificateSetOrder sets order_url and authorizations for the
+// order that the certificate was issued from, and clears the pending order. A
+// failure to fetch the authorizations does not fail the issuance, and is
+// logged instead.
+func resourceACMECertificateSetOrder(d *schema.ResourceData, obtainer *orderObtainer, order acme.ExtendedOrder) {
+	d.Set("order_url", order.Location)
+
+	authorizations, err := obtainer.Authorizations(order)
+	if err != nil {
+		log.Printf("[WARN] unable to fetch the authorizations for order %s: %s", order.Location, err)
+	}
+
+	d.Set("authorizations", flattenOrderAuthorizations(authorizations))
+	d.Set("pending_order_url", "")
+	d.Set("pending_order_authorizations", nil)
+	d.Set("pending_order_private_key_pem", "")
+	d.Set("pending_order_solved_authorizations", nil)
+}
+
+// resourceACMECertificateCheckpointOrder saves an in-flight order to state
+// when the certificate can't be obtained, along with the private key that was
+// generated for it, if any, and the authorizations that challenges were
+// solved for, and returns a *certificateOrderPendingError for err.
+//
+// Partial mode is turned off so that the pending order is saved, but the
+// attributes that are unknown in the plan would then be dropped from state.
+// Their values in state are set again first, so that a failed renewal keeps
+// the current certificate.
+func resourceACMECertificateCheckpointOrder(
+	d *schema.ResourceData,
+	obtainer *orderObtainer,
+	order acme.ExtendedOrder,
+	keyPEM []byte,
+	err error,
+) error {
+	var solved []string
+	for authzURL := range obtainer.solved {
+		solved = append(solved, authzURL)
+	}
+	sort.Strings(solved)
+
+	if !d.IsNewResource() {
+		for k, s := range resourceACMECertificate().Schema {
+			if s.WriteOnly || strings.HasPrefix(k, "pending_order_") {
+				continue
+			}
+
+			old, _ := d.GetChange(k)
+			if err := d.Set(k, old); err != nil {
+				return fmt.Errorf("error keeping %s in state: %w", k, err)
+			}
+		}
+	}
+
+	d.Partial(false)
+	d.Set("pending_order_url", order.Location)
+	d.Set("pending_order_authorizations", order.Authorizations)
+	d.Set("pending_order_private_key_pem", string(keyPEM))
+	d.Set("pending_order_solved_authorizations", solved)
+	resourceACMECertificateNextRenewalRefresh(d, time.Now(), false)
+
+	return &certificateOrderPendingError{orderURL: order.Location, err: err}
+}
@@ -0,0 +1,260 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestOrderIdentifiersEqual(t *testing.T) {
+	identifiers := []acme.Identifier{
+		{Type: "dns", Value: "www.example.com"},
+		{Type: "dns", Value: "*.example.com"},
+		{Type: "ip", Value: "192.0.2.1"},
+	}
+
+	testCases := []struct {
+		name     string
+		domains  []string
+		expected bool
+	}{
+		{
+			name:     "same",
+			domains:  []string{"www.example.com", "*.example.com", "192.0.2.1"},
+			expected: true,
+		},
+		{
+			name:     "different order and case",
+			domains:  []string{"192.0.2.1", "*.EXAMPLE.com", "www.example.com."},
+			expected: true,
+		},
+		{
+			name:    "removed",
+			domains: []string{"www.example.com", "*.example.com"},
+		},
+		{
+			name:    "changed",
+			domains: []string{"www2.example.com", "*.example.com", "192.0.2.1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := orderIdentifiersEqual(identifiers, tc.domains); actual != tc.expected {
+				t.Fatalf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCertificateMatchesKey(t *testing.T) {
+	now := time.Now()
+	b := testGenerateCertificateBundle(t, now, now.Add(time.Hour), []string{"www.example.com"}, nil)
+	other := testGenerateCertificateBundle(t, now, now.Add(time.Hour), []string{"www.example.com"}, nil)
+
+	key, err := certcrypto.ParsePEMPrivateKey(b.KeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := obtainRequest{}
+	request.PrivateKey = key
+
+	if !certificateMatchesKey(b.CertPEM, request.publicKey()) {
+		t.Fatal("expected certificate to match its key")
+	}
+
+	if certificateMatchesKey(other.CertPEM, request.publicKey()) {
+		t.Fatal("expected certificate not to match a different key")
+	}
+
+	if certificateMatchesKey(b.CertPEM, obtainRequest{}.publicKey()) {
+		t.Fatal("expected certificate not to match a generated key")
+	}
+}
+
+func TestResourceACMECertificateCheckpointOrder(t *testing.T) {
+	obtainer := &orderObtainer{solved: map[string]bool{
+		"https://example.com/authz/2": true,
+		"https://example.com/authz/1": true,
+	}}
+	order := acme.ExtendedOrder{
+		Location: "https://example.com/order/1",
+		Order: acme.Order{
+			Authorizations: []string{"https://example.com/authz/1", "https://example.com/authz/2"},
+		},
+	}
+
+	// Fail a renewal, in which the certificate is unknown in the plan.
+	r := resourceACMECertificate()
+	r.UpdateWithoutTimeout = resourceACMECertificateDiagnostics(func(d *schema.ResourceData, _ any) error {
+		d.Partial(true)
+		return resourceACMECertificateCheckpointOrder(d, obtainer, order, []byte("key"), errors.New("timeout"))
+	})
+
+	state, diags := r.Apply(context.Background(), &terraform.InstanceState{
+		ID: "certificate",
+		Attributes: map[string]string{
+			"certificate_pem":    "old",
+			"certificate_url":    "https://example.com/cert/1",
+			"min_days_remaining": "30",
+		},
+	}, &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"certificate_pem":    {Old: "old", NewComputed: true},
+			"certificate_url":    {Old: "https://example.com/cert/1", NewComputed: true},
+			"min_days_remaining": {Old: "30", New: "40"},
+			"pending_order_url":  {NewComputed: true},
+		},
+	}, nil)
+
+	if len(diags) != 1 || diags[0].Severity != diag.Error {
+		t.Fatalf("expected a single error, got %#v", diags)
+	}
+
+	expected := map[string]string{
+		"certificate_pem":                       "old",
+		"certificate_url":                       "https://example.com/cert/1",
+		"min_days_remaining":                    "30",
+		"pending_order_url":                     "https://example.com/order/1",
+		"pending_order_authorizations.#":        "2",
+		"pending_order_authorizations.0":        "https://example.com/authz/1",
+		"pending_order_authorizations.1":        "https://example.com/authz/2",
+		"pending_order_private_key_pem":         "key",
+		"pending_order_solved_authorizations.#": "2",
+		"pending_order_solved_authorizations.0": "https://example.com/authz/1",
+		"pending_order_solved_authorizations.1": "https://example.com/authz/2",
+	}
+	for k, v := range expected {
+		if actual := state.Attributes[k]; actual != v {
+			t.Fatalf("expected %s to be %q, got %q", k, v, actual)
+		}
+	}
+
+	if !resourceACMECertificateOrderPending(r.Data(state)) || resourceACMECertificateCreatePending(r.Data(state)) {
+		t.Fatal("expected the renewal to be pending")
+	}
+}
+
+func TestResourceACMECertificateCreatePending(t *testing.T) {
+	d := resourceACMECertificate().Data(&terraform.InstanceState{
+		ID: "certificate",
+		Attributes: map[string]string{
+			"pending_order_url": "https://example.com/order/1",
+		},
+	})
+
+	if !resourceACMECertificateCreatePending(d) {
+		t.Fatal("expected certificate creation to be pending")
+	}
+
+	// There is nothing to read, and the order is resumed straight away.
+	if err := resourceACMECertificateRead(d, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := resourceACMECertificateNextRenewal(d, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Reason != renewalReasonPendingOrder {
+		t.Fatalf("expected reason %q, got %q", renewalReasonPendingOrder, r.Reason)
+	}
+
+	d = resourceACMECertificate().Data(&terraform.InstanceState{
+		ID: "certificate",
+		Attributes: map[string]string{
+			"certificate_url":   "https://example.com/cert/1",
+			"pending_order_url": "https://example.com/order/1",
+		},
+	})
+	if resourceACMECertificateCreatePending(d) {
+		t.Fatal("expected a renewal to be pending, not creation")
+	}
+}
+
+func TestResourceACMECertificateOrderKey(t *testing.T) {
+	d := resourceACMECertificate().Data(&terraform.InstanceState{ID: "certificate"})
+	key, keyPEM, err := resourceACMECertificateOrderKey(d, certcrypto.EC256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := key.(*ecdsa.PrivateKey); !ok {
+		t.Fatalf("expected an ECDSA key, got %T", key)
+	}
+
+	// The saved key is used when the order is resumed.
+	d = resourceACMECertificate().Data(&terraform.InstanceState{
+		ID: "certificate",
+		Attributes: map[string]string{
+			"pending_order_url":             "https://example.com/order/1",
+			"pending_order_private_key_pem": string(keyPEM),
+		},
+	})
+	resumed, resumedPEM, err := resourceACMECertificateOrderKey(d, certcrypto.RSA2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !key.(*ecdsa.PrivateKey).Equal(resumed) {
+		t.Fatal("expected the saved key to be used")
+	}
+
+	if !bytes.Equal(keyPEM, resumedPEM) {
+		t.Fatal("expected the saved key to be saved again")
+	}
+}
+
+func TestFlattenOrderAuthorizations(t *testing.T) {
+	validated := time.Date(2026, 10, 19, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	actual := flattenOrderAuthorizations([]orderAuthorization{
+		{
+			Identifier:    "www.example.com",
+			URL:           "https://example.com/authz/1",
+			Status:        "valid",
+			ChallengeType: "dns-01",
+			ValidatedAt:   validated,
+		},
+		{
+			Identifier: "*.example.com",
+			URL:        "https://example.com/authz/2",
+			Status:     "deactivated",
+			Reused:     true,
+		},
+	})
+
+	expected := []any{
+		map[string]any{
+			"identifier":     "www.example.com",
+			"url":            "https://example.com/authz/1",
+			"status":         "valid",
+			"challenge_type": "dns-01",
+			"validated_at":   "2026-10-19T10:00:00Z",
+			"reused":         false,
+		},
+		map[string]any{
+			"identifier":     "*.example.com",
+			"url":            "https://example.com/authz/2",
+			"status":         "deactivated",
+			"challenge_type": "",
+			"validated_at":   "",
+			"reused":         true,
+		},
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, actual)
+	}
+}
@@ -0,0 +1,266 @@
+package acme
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// renewalWindowWeekdays are the values of renewal_window.weekdays.
+var renewalWindowWeekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+func renewalWindowSchema() *schema.Schema {
+	var weekdays []string
+	for _, d := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		weekdays = append(weekdays, strings.ToLower(d.String()))
+	}
+
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"weekdays": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					MinItems: 1,
+					Elem: &schema.Schema{
+						Type:         schema.TypeString,
+						ValidateFunc: validation.StringInSlice(weekdays, false),
+					},
+				},
+				"hours": {
+					Type:     schema.TypeSet,
+					Optional: true,
+					MinItems: 1,
+					Elem: &schema.Schema{
+						Type:         schema.TypeInt,
+						ValidateFunc: validation.IntBetween(0, 23),
+					},
+				},
+				"timezone": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "UTC",
+					ValidateFunc: validateTimezone,
+				},
+				"jitter": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validateCertificateLifetime,
+				},
+				"safety_margin": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "24h",
+					ValidateFunc: validateCertificateLifetime,
+				},
+			},
+		},
+	}
+}
+
+// validateTimezone validates that a timezone is a valid IANA timezone name.
+func validateTimezone(v any, k string) (ws []string, errors []error) {
+	if _, err := time.LoadLocation(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%s: %w", k, err))
+	}
+	return
+}
+
+// renewalWindow is the times that a certificate may be renewed in, from the
+// renewal_window block.
+type renewalWindow struct {
+	// The allowed weekdays and hours. All are allowed if empty.
+	Weekdays map[time.Weekday]bool
+	Hours    map[int]bool
+
+	Location     *time.Location
+	Jitter       time.Duration
+	SafetyMargin time.Duration
+}
+
+// expandRenewalWindow returns the renewal window for the certificate, or nil
+// if renewal_window is not set.
+func expandRenewalWindow(d resourceDataOrDiff) (*renewalWindow, error) {
+	v := d.Get("renewal_window").([]any)
+	if len(v) == 0 || v[0] == nil {
+		return nil, nil
+	}
+
+	m := v[0].(map[string]any)
+	w := &renewalWindow{
+		Weekdays: make(map[time.Weekday]bool),
+		Hours:    make(map[int]bool),
+		Location: time.UTC,
+	}
+
+	if v, ok := m["weekdays"].(*schema.Set); ok {
+		for _, day := range v.List() {
+			w.Weekdays[renewalWindowWeekdays[day.(string)]] = true
+		}
+	}
+
+	if v, ok := m["hours"].(*schema.Set); ok {
+		for _, hour := range v.List() {
+			w.Hours[hour.(int)] = true
+		}
+	}
+
+	if v := m["timezone"].(string); v != "" {
+		loc, err := time.LoadLocation(v)
+		if err != nil {
+			return nil, fmt.Errorf("renewal_window.timezone: %w", err)
+		}
+
+		w.Location = loc
+	}
+
+	if v := m["jitter"].(string); v != "" {
+		c, err := parseCertificateDuration(v, false)
+		if err != nil {
+			return nil, fmt.Errorf("renewal_window.jitter: %w", err)
+		}
+
+		w.Jitter = c.Duration
+	}
+
+	if v := m["safety_margin"].(string); v != "" {
+		c, err := parseCertificateDuration(v, false)
+		if err != nil {
+			return nil, fmt.Errorf("renewal_window.safety_margin: %w", err)
+		}
+
+		w.SafetyMargin = c.Duration
+	}
+
+	return w, nil
+}
+
+// Offset returns the jitter for the resource with the given ID, a whole
+// number of seconds between 0 and the configured jitter. The offset is derived
+// from the ID so that it is stable across plans.
+func (w *renewalWindow) Offset(id string) time.Duration {
+	seconds := uint64(w.Jitter / time.Second)
+	if seconds == 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return time.Duration(h.Sum64()%seconds) * time.Second
+}
+
+// Allowed returns true if t falls within the allowed weekdays and hours.
+func (w *renewalWindow) Allowed(t time.Time) bool {
+	t = t.In(w.Location)
+	if len(w.Weekdays) > 0 && !w.Weekdays[t.Weekday()] {
+		return false
+	}
+
+	if len(w.Hours) > 0 && !w.Hours[t.Hour()] {
+		return false
+	}
+
+	return true
+}
+
+// Next returns the earliest time from now that a renewal is allowed, with the
+// window shifted later by offset.
+func (w *renewalWindow) Next(now time.Time, offset time.Duration) time.Time {
+	shifted := now.Add(-offset)
+	if w.Allowed(shifted) {
+		return now
+	}
+
+	// Step through the start of each hour over the next week. This is done in
+	// absolute time, so that the hours are counted correctly across daylight
+	// saving transitions.
+	local := shifted.In(w.Location)
+	start := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, w.Location)
+	for i := 1; i <= 8*24; i++ {
+		t := start.Add(time.Duration(i) * time.Hour)
+		if w.Allowed(t) {
+			return t.Add(offset)
+		}
+	}
+
+	// Not reachable with a non-empty set of weekdays and hours.
+	return time.Time{}
+}
+
+// resourceACMECertificateRenewalWindowCheck checks whether a renewal that is
+// due should be deferred until the next renewal window, and returns the time
+// it is deferred until, or the zero time if it is not. The returned status
+// explains why the renewal was deferred or scheduled, and is empty if
+// renewal_window is not set. A renewal is never deferred past the safety
+// margin before expiry, if the certificate has been revoked, or if an order is
+// pending.
+func resourceACMECertificateRenewalWindowCheck(d resourceDataOrDiff, now time.Time) (time.Time, string, error) {
+	w, err := expandRenewalWindow(d)
+	if err != nil || w == nil {
+		return time.Time{}, "", err
+	}
+
+	if resourceACMECertificateRevoked(d) {
+		return time.Time{}, "scheduled: the certificate has been revoked", nil
+	}
+
+	if resourceACMECertificateOrderPending(d) {
+		return time.Time{}, "scheduled: resuming the pending order", nil
+	}
+
+	next := w.Next(now, w.Offset(d.Id()))
+	if next.Equal(now) {
+		return time.Time{}, "scheduled: inside the renewal window", nil
+	}
+
+	remaining, err := certSecondsRemaining(expandCertificateResource(d), now)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	expiry := now.Add(time.Duration(remaining) * time.Second)
+	if next.IsZero() || next.After(expiry.Add(-w.SafetyMargin)) {
+		return time.Time{}, fmt.Sprintf(
+			"scheduled: the next renewal window at %s is within the safety margin (%s) of expiry at %s",
+			next.UTC().Format(time.RFC3339),
+			w.SafetyMargin,
+			expiry.UTC().Format(time.RFC3339),
+		), nil
+	}
+
+	return next, fmt.Sprintf("deferred until %s: outside the renewal window", next.UTC().Format(time.RFC3339)), nil
+}
+
+// resourceACMECertificateRefresh reads the certificate during refresh. This
+// also clears renewal_window_status once a renewal is no longer due, and sets
+// renewal_reason for the next renewal, which can't be done in the reads at the
+// end of create and update, as both are part of the plan.
+func resourceACMECertificateRefresh(d *schema.ResourceData, meta any) error {
+	if err := resourceACMECertificateRead(d, meta); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if due, err := resourceACMECertificateRenewalDue(d, now); err == nil && !due {
+		d.Set("renewal_window_status", "")
+	}
+
+	resourceACMECertificateNextRenewalRefresh(d, now, true)
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package acme
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestRenewalWindowNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tuesday to Thursday, 02:00 to 04:59 in Berlin (UTC+2 in summer).
+	w := &renewalWindow{
+		Weekdays: map[time.Weekday]bool{time.Tuesday: true, time.Wednesday: true, time.Thursday: true},
+		Hours:    map[int]bool{2: true, 3: true, 4: true},
+		Location: berlin,
+	}
+
+	testCases := []struct {
+		name     string
+		now      time.Time
+		offset   time.Duration
+		expected time.Time
+	}{
+		{
+			name:     "inside window",
+			now:      time.Date(2026, 6, 2, 1, 30, 0, 0, time.UTC),
+			expected: time.Date(2026, 6, 2, 1, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "later the same day",
+			now:      time.Date(2026, 6, 1, 23, 30, 0, 0, time.UTC),
+			expected: time.Date(2026, 6, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "friday night",
+			now:      time.Date(2026, 6, 5, 20, 0, 0, 0, time.UTC),
+			expected: time.Date(2026, 6, 9, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "offset",
+			now:      time.Date(2026, 6, 2, 0, 10, 0, 0, time.UTC),
+			offset:   time.Minute * 17,
+			expected: time.Date(2026, 6, 2, 0, 17, 0, 0, time.UTC),
+		},
+		{
+			name:     "inside offset window",
+			now:      time.Date(2026, 6, 2, 3, 10, 0, 0, time.UTC),
+			offset:   time.Minute * 17,
+			expected: time.Date(2026, 6, 2, 3, 10, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := w.Next(tc.now, tc.offset); !actual.Equal(tc.expected) {
+				t.Fatalf("expected %s, got %s", tc.expected, actual.UTC())
+			}
+		})
+	}
+}
+
+func TestRenewalWindowOffset(t *testing.T) {
+	w := &renewalWindow{Jitter: time.Hour}
+	a, b := w.Offset("a"), w.Offset("b")
+	if a != w.Offset("a") {
+		t.Fatal("expected offset to be stable")
+	}
+
+	if a == b {
+		t.Fatalf("expected offsets to differ, got %s", a)
+	}
+
+	for _, offset := range []time.Duration{a, b} {
+		if offset < 0 || offset >= time.Hour || offset%time.Second != 0 {
+			t.Fatalf("unexpected offset: %s", offset)
+		}
+	}
+
+	if offset := (&renewalWindow{}).Offset("a"); offset != 0 {
+		t.Fatalf("expected no offset without jitter, got %s", offset)
+	}
+}
+
+func TestResourceACMECertificateRenewalSchedule(t *testing.T) {
+	// A Friday evening.
+	now := time.Date(2026, 6, 5, 20, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		remaining      time.Duration
+		revoked        bool
+		expectedRenew  bool
+		expectedStatus string
+	}{
+		{
+			name:      "not due",
+			remaining: 60 * 24 * time.Hour,
+		},
+		{
+			name:           "deferred",
+			remaining:      20 * 24 * time.Hour,
+			expectedStatus: "deferred until 2026-06-09T02:00:00Z: outside the renewal window",
+		},
+		{
+			name:           "safety margin",
+			remaining:      4 * 24 * time.Hour,
+			expectedRenew:  true,
+			expectedStatus: "scheduled: the next renewal window at 2026-06-09T02:00:00Z is within the safety margin (120h0m0s)",
+		},
+		{
+			name:           "revoked",
+			remaining:      60 * 24 * time.Hour,
+			revoked:        true,
+			expectedRenew:  true,
+			expectedStatus: "scheduled: the certificate has been revoked",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := testGenerateCertificateBundle(t, now.Add(-time.Hour), now.Add(tc.remaining), []string{"www.example.com"}, nil)
+			attrs := map[string]string{
+				"certificate_pem":                string(b.CertPEM),
+				"issuer_pem":                     string(b.IssuerPEM),
+				"min_days_remaining":             "30",
+				"renewal_window.#":               "1",
+				"renewal_window.0.weekdays.#":    "1",
+				"renewal_window.0.weekdays.0":    "tuesday",
+				"renewal_window.0.hours.#":       "1",
+				"renewal_window.0.hours.0":       "2",
+				"renewal_window.0.timezone":      "UTC",
+				"renewal_window.0.safety_margin": "120h",
+			}
+			if tc.revoked {
+				attrs["revocation_status"] = revocationStatusRevoked
+			}
+
+			d := resourceACMECertificate().Data(&terraform.InstanceState{ID: "certificate", Attributes: attrs})
+			renew, status, err := resourceACMECertificateRenewalSchedule(d, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if renew != tc.expectedRenew {
+				t.Fatalf("expected renew to be %t, got %t", tc.expectedRenew, renew)
+			}
+
+			if !strings.HasPrefix(status, tc.expectedStatus) || (tc.expectedStatus == "" && status != "") {
+				t.Fatalf("expected status %q, got %q", tc.expectedStatus, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/crypto/ocsp"
+)
+
+// The values of revocation_status.
+const (
+	revocationStatusGood    = "good"
+	revocationStatusRevoked = "revoked"
+	revocationStatusUnknown = "unknown"
+)
+
+// revocationCheckTimeout is the timeout for requests made to CRL distribution
+// points and OCSP responders.
+const revocationCheckTimeout = time.Second * 30
+
+// revocationCheckMaxCRLSize is the maximum size of a CRL that will be read
+// from a CRL distribution point.
+const revocationCheckMaxCRLSize = 64 * 1024 * 1024
+
+// revocationCheckMaxOCSPSize is the maximum size of an OCSP response that
+// will be read from an OCSP responder.
+const revocationCheckMaxOCSPSize = 1024 * 1024
+
+// revocationReasons are the revocation reasons that can be reported by a
+// CRL or OCSP responder, in the same form as revoke_certificate_reason.
+var revocationReasons = []RevocationReason{
+	RevocationReasonUnspecified,
+	RevocationReasonKeyCompromise,
+	RevocationReasonCACompromise,
+	RevocationReasonAffiliationChanged,
+	RevocationReasonSuperseded,
+	RevocationReasonCessationOfOperation,
+	RevocationReasonCertificateHold,
+	RevocationReasonRemoveFromCRL,
+	RevocationReasonPrivilegeWithdrawn,
+	RevocationReasonAACompromise,
+}
+
+// certificateRevocation is the revocation status of a certificate.
+type certificateRevocation struct {
+	Status    string
+	RevokedAt time.Time
+	Reason    RevocationReason
+}
+
+// revocationReasonFromCode returns the revocation reason for a CRL reason
+// code (RFC 5280 section 5.3.1).
+func revocationReasonFromCode(code int) RevocationReason {
+	for _, reason := range revocationReasons {
+		if v, _ := GetRevocationReason(reason); int(v) == code {
+			return reason
+		}
+	}
+
+	return RevocationReasonUnspecified
+}
+
+// checkCertificateRevocation checks the revocation status of cert, issued by
+// issuer. The CRLs in the certificate's CRL distribution points are checked
+// first, falling back to the certificate's OCSP responders if none of the
+// CRLs could be checked, or all of them were stale. If the certificate has neither, the status is
+// unknown. An error is returned along with an unknown status if none of the
+// CRLs or OCSP responders could be checked.
+func checkCertificateRevocation(cert, issuer *x509.Certificate) (certificateRevocation, error) {
+	client := &http.Client{Timeout: revocationCheckTimeout}
+	var errs []error
+	for _, u := range cert.CRLDistributionPoints {
+		result, err := checkCertificateRevocationCRL(client, u, cert, issuer)
+		if err == nil {
+			return result, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	for _, u := range cert.OCSPServer {
+		result, err := checkCertificateRevocationOCSP(client, u, cert, issuer)
+		if err == nil {
+			return result, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	return certificateRevocation{Status: revocationStatusUnknown}, errors.Join(errs...)
+}
+
+// checkCertificateRevocationCRL checks the revocation status of cert in the
+// CRL at u.
+func checkCertificateRevocationCRL(client *http.Client, u string, cert, issuer *x509.Certificate) (certificateRevocation, error) {
+	if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return certificateRevocation{}, fmt.Errorf("CRL %s: unsupported URL", u)
+	}
+
+	body, err := doRevocationCheckRequest(client, http.MethodGet, u, nil, "", revocationCheckMaxCRLSize)
+	if err != nil {
+		return certificateRevocation{}, fmt.Errorf("CRL %s: %w", u, err)
+	}
+
+	crl, err := x509.ParseRevocationList(body)
+	if err != nil {
+		return certificateRevocation{}, fmt.Errorf("CRL %s: error parsing CRL: %w", u, err)
+	}
+
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return certificateRevocation{}, fmt.Errorf("CRL %s: error verifying CRL signature: %w", u, err)
+	}
+
+	// A stale CRL may be missing recent revocations, so the OCSP responders
+	// are checked instead.
+	if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+		return certificateRevocation{}, fmt.Errorf("CRL %s: CRL is stale, next update was due at %s", u, crl.NextUpdate.UTC().Format(time.RFC3339))
+	}
+
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			return certificateRevocation{
+				Status:    revocationStatusRevoked,
+				RevokedAt: entry.RevocationTime,
+				Reason:    revocationReasonFromCode(entry.ReasonCode),
+			}, nil
+		}
+	}
+
+	return certificateRevocation{Status: revocationStatusGood}, nil
+}
+
+// checkCertificateRevocationOCSP checks the revocation status of cert with
+// the OCSP responder at u.
+func checkCertificateRevocationOCSP(client *http.Client, u string, cert, issuer *x509.Certificate) (certificateRevocation, error) {
+	resp, err := fetchOCSPResponse(client, u, cert, issuer)
+	if err != nil {
+		return certificateRevocation{}, err
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		return certificateRevocation{Status: revocationStatusGood}, nil
+
+	case ocsp.Revoked:
+		return certificateRevocation{
+			Status:    revocationStatusRevoked,
+			RevokedAt: resp.RevokedAt,
+			Reason:    revocationReasonFromCode(resp.RevocationReason),
+		}, nil
+	}
+
+	return certificateRevocation{Status: revocationStatusUnknown}, nil
+}
+
+// fetchOCSPResponse requests the status of cert from the OCSP responder at u,
+// and returns the verified response.
+func fetchOCSPResponse(client *http.Client, u string, cert, issuer *x509.Certificate) (*ocsp.Response, error) {
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("OCSP %s: error creating request: %w", u, err)
+	}
+
+	body, err := doRevocationCheckRequest(client, http.MethodPost, u, req, "application/ocsp-request", revocationCheckMaxOCSPSize)
+	if err != nil {
+		return nil, fmt.Errorf("OCSP %s: %w", u, err)
+	}
+
+	resp, err := ocsp.ParseResponseForCert(body, cert, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("OCSP %s: error parsing response: %w", u, err)
+	}
+
+	return resp, nil
+}
+
+func doRevocationCheckRequest(client *http.Client, method, u string, body []byte, contentType string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result, err := io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+
+	return result, nil
+}
+
+// resourceACMECertificateRevocationRefresh checks the revocation status of
+// the certificate in state, if check_revocation is enabled. A failed check is
+// logged rather than returned, so that an unreachable CRL distribution point
+// or OCSP responder doesn't break refresh. Revocation is permanent, so a
+// certificate that has been seen as revoked stays revoked if a later check
+// fails.
+func resourceACMECertificateRevocationRefresh(d *schema.ResourceData) error {
+	if !d.Get("check_revocation").(bool) {
+		d.Set("revocation_status", "")
+		d.Set("revoked_at", "")
+		d.Set("revocation_reason", "")
+		return nil
+	}
+
+	certs, err := parsePEMBundle([]byte(d.Get("certificate_pem").(string)))
+	if err != nil {
+		return err
+	}
+
+	issuers, err := parsePEMBundle([]byte(d.Get("issuer_pem").(string)))
+	if err != nil {
+		return fmt.Errorf("error reading issuer certificate for revocation check: %w", err)
+	}
+
+	result, err := checkCertificateRevocation(certs[0], issuers[0])
+	if err != nil {
+		log.Printf("[WARN] error checking revocation status of certificate %s: %s", certs[0].SerialNumber, err)
+		if d.Get("revocation_status").(string) == revocationStatusRevoked {
+			return nil
+		}
+	}
+
+	d.Set("revocation_status", result.Status)
+	d.Set("revocation_reason", string(result.Reason))
+	if result.RevokedAt.IsZero() {
+		d.Set("revoked_at", "")
+	} else {
+		d.Set("revoked_at", result.RevokedAt.UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// resourceACMECertificateRevoked returns true if the certificate in state was
+// found to be revoked the last time it was read.
+func resourceACMECertificateRevoked(d resourceDataOrDiff) bool {
+	return d.Get("revocation_status").(string) == revocationStatusRevoked
+}
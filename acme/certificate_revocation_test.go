@@ -0,0 +1,331 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testRevocationResponder is a CA with a CRL distribution point and an OCSP
+// responder, for testing revocation checks.
+type testRevocationResponder struct {
+	server *httptest.Server
+	ca     *x509.Certificate
+	caKey  crypto.Signer
+
+	// The revoked certificates, keyed by serial number, and their reason codes.
+	revoked map[int64]int
+
+	// Disable the CRL or OCSP responder, returning an error status instead.
+	disableCRL  bool
+	disableOCSP bool
+
+	// Serve a CRL that is past its next update, without any entries.
+	staleCRL bool
+
+	// The number of requests made to the OCSP responder.
+	ocspRequests int
+}
+
+func newTestRevocationResponder(t *testing.T) *testRevocationResponder {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &testRevocationResponder{ca: ca, caKey: caKey, revoked: make(map[int64]int)}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *testRevocationResponder) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/crl":
+		if r.disableCRL {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var entries []x509.RevocationListEntry
+		for serial, reason := range r.revoked {
+			entries = append(entries, x509.RevocationListEntry{
+				SerialNumber:   big.NewInt(serial),
+				RevocationTime: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				ReasonCode:     reason,
+			})
+		}
+
+		thisUpdate := time.Now()
+		if r.staleCRL {
+			thisUpdate = thisUpdate.Add(-2 * time.Hour)
+			entries = nil
+		}
+
+		crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:                    big.NewInt(1),
+			ThisUpdate:                thisUpdate,
+			NextUpdate:                thisUpdate.Add(time.Hour),
+			RevokedCertificateEntries: entries,
+		}, r.ca, r.caKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write(crl)
+
+	case "/ocsp":
+		if r.disableOCSP {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		r.ocspRequests++
+		body, _ := io.ReadAll(req.Body)
+		ocspReq, err := ocsp.ParseRequest(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: ocspReq.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}
+		if reason, ok := r.revoked[ocspReq.SerialNumber.Int64()]; ok {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			template.RevocationReason = reason
+		}
+
+		resp, err := ocsp.CreateResponse(r.ca, r.ca, template, r.caKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// issue issues a certificate with the given serial number, pointing to the
+// responder's CRL distribution point and OCSP responder.
+func (r *testRevocationResponder) issue(t *testing.T, serial int64) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "www.example.com"},
+		DNSNames:              []string{"www.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		CRLDistributionPoints: []string{r.server.URL + "/crl"},
+		OCSPServer:            []string{r.server.URL + "/ocsp"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, r.ca, &key.PublicKey, r.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func TestCheckCertificateRevocation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		revoked     bool
+		disableCRL  bool
+		disableOCSP bool
+		staleCRL    bool
+		expected    certificateRevocation
+		expectError bool
+	}{
+		{
+			name:     "good",
+			expected: certificateRevocation{Status: revocationStatusGood},
+		},
+		{
+			name:    "revoked (CRL)",
+			revoked: true,
+			expected: certificateRevocation{
+				Status:    revocationStatusRevoked,
+				RevokedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				Reason:    RevocationReasonKeyCompromise,
+			},
+		},
+		{
+			name:       "revoked (OCSP fallback)",
+			revoked:    true,
+			disableCRL: true,
+			expected: certificateRevocation{
+				Status:    revocationStatusRevoked,
+				RevokedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				Reason:    RevocationReasonKeyCompromise,
+			},
+		},
+		{
+			name:     "revoked (stale CRL)",
+			revoked:  true,
+			staleCRL: true,
+			expected: certificateRevocation{
+				Status:    revocationStatusRevoked,
+				RevokedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				Reason:    RevocationReasonKeyCompromise,
+			},
+		},
+		{
+			name:        "unavailable",
+			revoked:     true,
+			disableCRL:  true,
+			disableOCSP: true,
+			expected:    certificateRevocation{Status: revocationStatusUnknown},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestRevocationResponder(t)
+			r.disableCRL = tc.disableCRL
+			r.disableOCSP = tc.disableOCSP
+			r.staleCRL = tc.staleCRL
+			cert := r.issue(t, 100)
+			r.issue(t, 101)
+			r.revoked[101] = int(acme.CRLReasonSuperseded)
+			if tc.revoked {
+				r.revoked[100] = int(acme.CRLReasonKeyCompromise)
+			}
+
+			actual, err := checkCertificateRevocation(cert, r.ca)
+			if tc.expectError != (err != nil) {
+				t.Fatalf("expected error: %t, got: %v", tc.expectError, err)
+			}
+
+			if !actual.RevokedAt.Equal(tc.expected.RevokedAt) {
+				t.Fatalf("expected revoked at %s, got %s", tc.expected.RevokedAt, actual.RevokedAt)
+			}
+
+			actual.RevokedAt = tc.expected.RevokedAt
+			if actual != tc.expected {
+				t.Fatalf("expected %#v, got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCheckCertificateRevocation_noURLs(t *testing.T) {
+	certs, err := parsePEMBundle([]byte(testPaddingBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := checkCertificateRevocation(certs[0], certs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Status != revocationStatusUnknown {
+		t.Fatalf("expected unknown status, got %q", actual.Status)
+	}
+}
+
+func TestResourceACMECertificateRevocationRefresh(t *testing.T) {
+	r := newTestRevocationResponder(t)
+	cert := r.issue(t, 100)
+	d := blankCertificateResource()
+	d.Set("check_revocation", true)
+	d.Set("certificate_pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+	d.Set("issuer_pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: r.ca.Raw})))
+
+	if err := resourceACMECertificateRevocationRefresh(d); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := d.Get("revocation_status").(string); actual != revocationStatusGood {
+		t.Fatalf("expected good status, got %q", actual)
+	}
+
+	if resourceACMECertificateRevoked(d) {
+		t.Fatal("expected certificate not to be revoked")
+	}
+
+	r.revoked[100] = int(acme.CRLReasonKeyCompromise)
+	if err := resourceACMECertificateRevocationRefresh(d); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"revocation_status": revocationStatusRevoked,
+		"revoked_at":        "2026-01-02T03:04:05Z",
+		"revocation_reason": "key-compromise",
+	}
+	for k, v := range expected {
+		if actual := d.Get(k).(string); actual != v {
+			t.Fatalf("expected %s to be %q, got %q", k, v, actual)
+		}
+	}
+
+	if shouldRenew, err := resourceACMECertificateShouldRenew(d, time.Now()); err != nil || !shouldRenew {
+		t.Fatalf("expected revoked certificate to be renewed, got %t (error: %v)", shouldRenew, err)
+	}
+
+	// A revoked certificate stays revoked if it can't be checked.
+	r.disableCRL = true
+	r.disableOCSP = true
+	if err := resourceACMECertificateRevocationRefresh(d); err != nil {
+		t.Fatal(err)
+	}
+
+	if !resourceACMECertificateRevoked(d) {
+		t.Fatal("expected certificate to stay revoked")
+	}
+}
@@ -0,0 +1,532 @@
+package acme
+
+//go:generate go run ../build-support/generate-ct-log-list ct_log_list.json
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// embeddedCTLogList is the CT log list used when no other log list is set in
+// require_scts, in the v3 log list format published by Google. It's
+// regenerated with go generate, and on release.
+//
+//go:embed ct_log_list.json
+var embeddedCTLogList []byte
+
+// ctLogListTimeout is the timeout for fetching a CT log list.
+const ctLogListTimeout = time.Second * 30
+
+// ctLogListMaxBodySize is the maximum size of a CT log list that will be read
+// from a log list URL.
+const ctLogListMaxBodySize = 8 * 1024 * 1024
+
+// oidSCTList is the OID of the embedded SCT list extension (RFC 6962 section
+// 3.3).
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// TLS HashAlgorithm and SignatureAlgorithm values used in SCT signatures (RFC
+// 5246 section 7.4.1.4.1).
+const (
+	sctHashAlgorithmSHA256     = 4
+	sctSignatureAlgorithmRSA   = 1
+	sctSignatureAlgorithmECDSA = 3
+)
+
+// signedCertificateTimestamp is an SCT from the embedded SCT list of a
+// certificate (RFC 6962 section 3.2).
+type signedCertificateTimestamp struct {
+	Version            uint8
+	LogID              []byte
+	Timestamp          uint64
+	Extensions         []byte
+	HashAlgorithm      uint8
+	SignatureAlgorithm uint8
+	Signature          []byte
+}
+
+// ctLog is a CT log from a log list.
+type ctLog struct {
+	Operator    string
+	Description string
+	Key         crypto.PublicKey
+}
+
+// ctLogList is a list of CT logs, keyed by base64-encoded log ID.
+type ctLogList map[string]ctLog
+
+// certificateSCTsSchema returns the schema for the computed certificate_scts
+// attribute.
+func certificateSCTsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Computed: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"version": {
+					Type:     schema.TypeInt,
+					Computed: true,
+				},
+				"log_id": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"timestamp": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"hash_algorithm": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"signature_algorithm": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+			},
+		},
+	}
+}
+
+// requireSCTsSchema returns the schema for the require_scts block.
+func requireSCTsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"min_scts": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      2,
+					ValidateFunc: validation.IntAtLeast(1),
+				},
+				"min_operators": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      2,
+					ValidateFunc: validation.IntAtLeast(1),
+				},
+				"log_list_url": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+				},
+				"log_list_json": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+			},
+		},
+	}
+}
+
+// parseCertificateSCTs returns the SCTs in the embedded SCT list extension of
+// a certificate. Certificates without the extension have no SCTs.
+func parseCertificateSCTs(cert *x509.Certificate) ([]signedCertificateTimestamp, error) {
+	var value []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			value = ext.Value
+			break
+		}
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+
+	// The extension value is an OCTET STRING containing the TLS-encoded
+	// SignedCertificateTimestampList.
+	var list []byte
+	if rest, err := asn1.Unmarshal(value, &list); err != nil || len(rest) > 0 {
+		return nil, errors.New("error parsing SCT list: invalid extension value")
+	}
+
+	var scts []signedCertificateTimestamp
+	var entries cryptobyte.String
+	s := cryptobyte.String(list)
+	if !s.ReadUint16LengthPrefixed(&entries) || !s.Empty() {
+		return nil, errors.New("error parsing SCT list: invalid list length")
+	}
+
+	for !entries.Empty() {
+		var entry, extensions, signature cryptobyte.String
+		var logID []byte
+		var sct signedCertificateTimestamp
+		if !entries.ReadUint16LengthPrefixed(&entry) ||
+			!entry.ReadUint8(&sct.Version) ||
+			!entry.ReadBytes(&logID, sha256.Size) ||
+			!entry.ReadUint64(&sct.Timestamp) ||
+			!entry.ReadUint16LengthPrefixed(&extensions) ||
+			!entry.ReadUint8(&sct.HashAlgorithm) ||
+			!entry.ReadUint8(&sct.SignatureAlgorithm) ||
+			!entry.ReadUint16LengthPrefixed(&signature) ||
+			!entry.Empty() {
+			return nil, errors.New("error parsing SCT list: invalid SCT")
+		}
+
+		sct.LogID = logID
+		sct.Extensions = extensions
+		sct.Signature = signature
+		scts = append(scts, sct)
+	}
+
+	return scts, nil
+}
+
+// flattenCertificateSCTs returns the certificate_scts attribute for a list of
+// SCTs.
+func flattenCertificateSCTs(scts []signedCertificateTimestamp) []any {
+	result := make([]any, len(scts))
+	for i, sct := range scts {
+		result[i] = map[string]any{
+			"version":             int(sct.Version) + 1,
+			"log_id":              base64.StdEncoding.EncodeToString(sct.LogID),
+			"timestamp":           time.UnixMilli(int64(sct.Timestamp)).UTC().Format(time.RFC3339Nano),
+			"hash_algorithm":      sctHashAlgorithmName(sct.HashAlgorithm),
+			"signature_algorithm": sctSignatureAlgorithmName(sct.SignatureAlgorithm),
+		}
+	}
+
+	return result
+}
+
+func sctHashAlgorithmName(alg uint8) string {
+	if alg == sctHashAlgorithmSHA256 {
+		return "SHA256"
+	}
+
+	return fmt.Sprintf("unknown (%d)", alg)
+}
+
+func sctSignatureAlgorithmName(alg uint8) string {
+	switch alg {
+	case sctSignatureAlgorithmRSA:
+		return "RSA"
+	case sctSignatureAlgorithmECDSA:
+		return "ECDSA"
+	}
+
+	return fmt.Sprintf("unknown (%d)", alg)
+}
+
+// verifySCT verifies the signature of an SCT embedded in cert, issued by
+// issuer, against the public key of the log that issued the SCT.
+func verifySCT(sct signedCertificateTimestamp, cert, issuer *x509.Certificate, key crypto.PublicKey) error {
+	if sct.Version != 0 {
+		return fmt.Errorf("unsupported SCT version %d", int(sct.Version)+1)
+	}
+
+	if sct.HashAlgorithm != sctHashAlgorithmSHA256 {
+		return fmt.Errorf("unsupported SCT hash algorithm %s", sctHashAlgorithmName(sct.HashAlgorithm))
+	}
+
+	tbs, err := precertificateTBS(cert)
+	if err != nil {
+		return err
+	}
+
+	// The signed data for an embedded SCT is the digitally-signed struct for a
+	// precert_entry (RFC 6962 section 3.2).
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	var b cryptobyte.Builder
+	b.AddUint8(sct.Version)
+	b.AddUint8(0) // signature_type: certificate_timestamp
+	b.AddUint64(sct.Timestamp)
+	b.AddUint16(1) // entry_type: precert_entry
+	b.AddBytes(issuerKeyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sct.Extensions)
+	})
+	signed, err := b.Bytes()
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(signed)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if sct.SignatureAlgorithm != sctSignatureAlgorithmECDSA || !ecdsa.VerifyASN1(k, digest[:], sct.Signature) {
+			return errors.New("invalid SCT signature")
+		}
+	case *rsa.PublicKey:
+		if sct.SignatureAlgorithm != sctSignatureAlgorithmRSA {
+			return errors.New("invalid SCT signature")
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sct.Signature); err != nil {
+			return errors.New("invalid SCT signature")
+		}
+	default:
+		return fmt.Errorf("unsupported CT log key type %T", key)
+	}
+
+	return nil
+}
+
+// precertificateTBS returns the TBSCertificate of cert with the embedded SCT
+// list extension removed, which is the TBSCertificate of the precertificate
+// that the CT logs signed.
+func precertificateTBS(cert *x509.Certificate) ([]byte, error) {
+	input := cryptobyte.String(cert.RawTBSCertificate)
+	var tbs cryptobyte.String
+	if !input.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("error parsing TBSCertificate")
+	}
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !tbs.Empty() {
+			var element cryptobyte.String
+			var tag cryptobyte_asn1.Tag
+			if !tbs.ReadAnyASN1Element(&element, &tag) {
+				b.SetError(errors.New("error parsing TBSCertificate"))
+				return
+			}
+
+			if tag != cryptobyte_asn1.Tag(3).Constructed().ContextSpecific() {
+				b.AddBytes(element)
+				continue
+			}
+
+			var wrapper, extensions cryptobyte.String
+			if !element.ReadASN1(&wrapper, tag) || !wrapper.ReadASN1(&extensions, cryptobyte_asn1.SEQUENCE) {
+				b.SetError(errors.New("error parsing TBSCertificate extensions"))
+				return
+			}
+
+			var kept [][]byte
+			for !extensions.Empty() {
+				var ext, fields cryptobyte.String
+				var oid asn1.ObjectIdentifier
+				if !extensions.ReadASN1Element(&ext, cryptobyte_asn1.SEQUENCE) {
+					b.SetError(errors.New("error parsing TBSCertificate extensions"))
+					return
+				}
+
+				if inner := ext; !inner.ReadASN1(&fields, cryptobyte_asn1.SEQUENCE) || !fields.ReadASN1ObjectIdentifier(&oid) {
+					b.SetError(errors.New("error parsing TBSCertificate extensions"))
+					return
+				}
+
+				if !oid.Equal(oidSCTList) {
+					kept = append(kept, ext)
+				}
+			}
+
+			if len(kept) == 0 {
+				continue
+			}
+
+			b.AddASN1(tag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for _, ext := range kept {
+						b.AddBytes(ext)
+					}
+				})
+			})
+		}
+	})
+
+	return b.Bytes()
+}
+
+// parseCTLogList parses a CT log list in the v3 log list format
+// (https://www.gstatic.com/ct/log_list/v3/log_list_schema.json). Both RFC 6962
+// and static CT API (tiled) logs are included.
+func parseCTLogList(data []byte) (ctLogList, error) {
+	type jsonLog struct {
+		Description string `json:"description"`
+		Key         string `json:"key"`
+	}
+	var list struct {
+		Operators []struct {
+			Name      string    `json:"name"`
+			Logs      []jsonLog `json:"logs"`
+			TiledLogs []jsonLog `json:"tiled_logs"`
+		} `json:"operators"`
+	}
+
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("error parsing CT log list: %w", err)
+	}
+
+	result := make(ctLogList)
+	for _, operator := range list.Operators {
+		for _, entry := range append(operator.Logs, operator.TiledLogs...) {
+			der, err := base64.StdEncoding.DecodeString(entry.Key)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding key of CT log %q: %w", entry.Description, err)
+			}
+
+			key, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing key of CT log %q: %w", entry.Description, err)
+			}
+
+			// The log ID is always the SHA-256 hash of the log's key, so it's
+			// computed here rather than trusting the log_id field.
+			id := sha256.Sum256(der)
+			result[base64.StdEncoding.EncodeToString(id[:])] = ctLog{
+				Operator:    operator.Name,
+				Description: entry.Description,
+				Key:         key,
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// fetchCTLogList fetches and parses the CT log list at url.
+func fetchCTLogList(url string) (ctLogList, error) {
+	client := &http.Client{Timeout: ctLogListTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching CT log list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, ctLogListMaxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading CT log list: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("error fetching CT log list: unexpected response status: %s", resp.Status)
+	}
+
+	return parseCTLogList(body)
+}
+
+// checkCertificateSCTs checks that the SCTs embedded in cert satisfy the
+// policy in a require_scts block: at least minSCTs SCTs with valid signatures
+// from logs in the log list, from at least minOperators distinct log
+// operators.
+func checkCertificateSCTs(cert, issuer *x509.Certificate, logs ctLogList, minSCTs, minOperators int) error {
+	scts, err := parseCertificateSCTs(cert)
+	if err != nil {
+		return err
+	}
+
+	var valid int
+	var problems []string
+	operators := make(map[string]bool)
+	for _, sct := range scts {
+		logID := base64.StdEncoding.EncodeToString(sct.LogID)
+		ctl, ok := logs[logID]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("SCT from log %s: log is not in the CT log list", logID))
+			continue
+		}
+
+		if err := verifySCT(sct, cert, issuer, ctl.Key); err != nil {
+			problems = append(problems, fmt.Sprintf("SCT from log %q: %s", ctl.Description, err))
+			continue
+		}
+
+		valid++
+		operators[ctl.Operator] = true
+	}
+
+	if valid >= minSCTs && len(operators) >= minOperators {
+		return nil
+	}
+
+	names := make([]string, 0, len(operators))
+	for name := range operators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(
+		&msg,
+		"certificate does not meet the SCT policy: found %d valid SCTs from %d log operators (%s), require at least %d SCTs from %d operators",
+		valid,
+		len(operators),
+		strings.Join(names, ", "),
+		minSCTs,
+		minOperators,
+	)
+	for _, problem := range problems {
+		fmt.Fprintf(&msg, "\n%s", problem)
+	}
+
+	return errors.New(msg.String())
+}
+
+// resourceACMECertificateCheckSCTs checks the SCTs of the certificate in
+// state against the require_scts block, if it is set.
+func resourceACMECertificateCheckSCTs(d *schema.ResourceData) error {
+	v, ok := d.GetOk("require_scts")
+	if !ok {
+		return nil
+	}
+
+	opts := v.([]any)[0].(map[string]any)
+	certs, err := parsePEMBundle([]byte(d.Get("certificate_pem").(string)))
+	if err != nil {
+		return err
+	}
+
+	issuers, err := parsePEMBundle([]byte(d.Get("issuer_pem").(string)))
+	if err != nil {
+		return fmt.Errorf("error reading issuer certificate for SCT verification: %w", err)
+	}
+
+	logs, err := expandCTLogList(opts)
+	if err != nil {
+		return err
+	}
+
+	return checkCertificateSCTs(certs[0], issuers[0], logs, opts["min_scts"].(int), opts["min_operators"].(int))
+}
+
+// expandCTLogList returns the CT log list for a require_scts block: the list
+// in log_list_json, the list fetched from log_list_url, or otherwise the
+// embedded list.
+func expandCTLogList(opts map[string]any) (ctLogList, error) {
+	if v := opts["log_list_json"].(string); v != "" {
+		return parseCTLogList([]byte(v))
+	}
+
+	if v := opts["log_list_url"].(string); v != "" {
+		return fetchCTLogList(v)
+	}
+
+	logs, err := parseCTLogList(embeddedCTLogList)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the embedded CT log list: %w", err)
+	}
+
+	if len(logs) == 0 {
+		return nil, errors.New("the CT log list embedded in this build of the provider is empty; set log_list_url or log_list_json, or regenerate it with `make ct-log-list-generate`")
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,365 @@
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// testCTLog is a CT log used to sign SCTs in tests.
+type testCTLog struct {
+	operator string
+	key      crypto.Signer
+}
+
+func (l testCTLog) id(t *testing.T) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(l.key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := sha256.Sum256(der)
+	return id[:]
+}
+
+// testCTLogListJSON returns a v3 log list containing logs.
+func testCTLogListJSON(t *testing.T, logs ...testCTLog) []byte {
+	t.Helper()
+	type jsonLog struct {
+		Description string `json:"description"`
+		LogID       string `json:"log_id"`
+		Key         string `json:"key"`
+	}
+	type jsonOperator struct {
+		Name string    `json:"name"`
+		Logs []jsonLog `json:"logs"`
+	}
+
+	var operators []*jsonOperator
+	byName := make(map[string]*jsonOperator)
+	for i, ctl := range logs {
+		der, err := x509.MarshalPKIXPublicKey(ctl.key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		operator, ok := byName[ctl.operator]
+		if !ok {
+			operator = &jsonOperator{Name: ctl.operator}
+			byName[ctl.operator] = operator
+			operators = append(operators, operator)
+		}
+
+		operator.Logs = append(operator.Logs, jsonLog{
+			Description: ctl.operator + " ctl " + string(rune('A'+i)),
+			LogID:       base64.StdEncoding.EncodeToString(ctl.id(t)),
+			Key:         base64.StdEncoding.EncodeToString(der),
+		})
+	}
+
+	data, err := json.Marshal(map[string]any{"version": "1.0", "operators": operators})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// testGenerateSCTCertificate issues a certificate with SCTs embedded from
+// each of logs, as a CA would after submitting a precertificate to the logs.
+// The SCT from the log at index corrupt, if any, has an invalid signature.
+func testGenerateSCTCertificate(t *testing.T, corrupt int, logs ...testCTLog) (*x509.Certificate, *x509.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		DNSNames:     []string{"www.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	precertDER, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	precert, err := x509.ParseCertificate(precertDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuerKeyHash := sha256.Sum256(ca.RawSubjectPublicKeyInfo)
+	var list cryptobyte.Builder
+	list.AddUint16LengthPrefixed(func(list *cryptobyte.Builder) {
+		for i, ctl := range logs {
+			timestamp := uint64(time.Now().UnixMilli())
+			var signed cryptobyte.Builder
+			signed.AddUint8(0)
+			signed.AddUint8(0)
+			signed.AddUint64(timestamp)
+			signed.AddUint16(1)
+			signed.AddBytes(issuerKeyHash[:])
+			signed.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(precert.RawTBSCertificate)
+			})
+			signed.AddUint16(0)
+			digest := sha256.Sum256(signed.BytesOrPanic())
+			if i == corrupt {
+				digest[0] ^= 0xff
+			}
+
+			sig, err := ctl.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sigAlg := uint8(sctSignatureAlgorithmECDSA)
+			if _, ok := ctl.key.(*rsa.PrivateKey); ok {
+				sigAlg = sctSignatureAlgorithmRSA
+			}
+
+			list.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint8(0)
+				b.AddBytes(ctl.id(t))
+				b.AddUint64(timestamp)
+				b.AddUint16(0)
+				b.AddUint8(sctHashAlgorithmSHA256)
+				b.AddUint8(sigAlg)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(sig)
+				})
+			})
+		}
+	})
+
+	value, err := asn1.Marshal(list.BytesOrPanic())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: value}}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual, err := precertificateTBS(cert); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(actual, precert.RawTBSCertificate) {
+		t.Fatal("precertificate TBS does not match the TBS of the certificate without SCTs")
+	}
+
+	return cert, ca
+}
+
+func testGenerateCTLogs(t *testing.T, operators ...string) []testCTLog {
+	t.Helper()
+	var logs []testCTLog
+	for i, operator := range operators {
+		var key crypto.Signer
+		var err error
+		if i%2 == 0 {
+			key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		} else {
+			key, err = rsa.GenerateKey(rand.Reader, 2048)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logs = append(logs, testCTLog{operator: operator, key: key})
+	}
+
+	return logs
+}
+
+func TestParseCertificateSCTs(t *testing.T) {
+	logs := testGenerateCTLogs(t, "Operator A", "Operator B")
+	cert, _ := testGenerateSCTCertificate(t, -1, logs...)
+	scts, err := parseCertificateSCTs(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scts) != 2 {
+		t.Fatalf("expected 2 SCTs, got %d", len(scts))
+	}
+
+	flattened := flattenCertificateSCTs(scts)
+	for i, expectedAlg := range []string{"ECDSA", "RSA"} {
+		sct := flattened[i].(map[string]any)
+		if sct["log_id"] != base64.StdEncoding.EncodeToString(logs[i].id(t)) {
+			t.Errorf("SCT %d: unexpected log ID %s", i, sct["log_id"])
+		}
+
+		if sct["version"] != 1 || sct["hash_algorithm"] != "SHA256" || sct["signature_algorithm"] != expectedAlg {
+			t.Errorf("SCT %d: unexpected SCT %#v", i, sct)
+		}
+
+		if _, err := time.Parse(time.RFC3339Nano, sct["timestamp"].(string)); err != nil {
+			t.Errorf("SCT %d: %s", i, err)
+		}
+	}
+}
+
+func TestParseCertificateSCTs_none(t *testing.T) {
+	certs, err := parsePEMBundle([]byte(testPaddingBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scts, err := parseCertificateSCTs(certs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scts) != 0 {
+		t.Fatalf("expected no SCTs, got %d", len(scts))
+	}
+}
+
+func TestCheckCertificateSCTs(t *testing.T) {
+	logs := testGenerateCTLogs(t, "Operator A", "Operator B", "Operator A")
+	unknownLog := testGenerateCTLogs(t, "Operator C")[0]
+	logList, err := parseCTLogList(testCTLogListJSON(t, logs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logList) != 3 {
+		t.Fatalf("expected 3 logs in log list, got %d", len(logList))
+	}
+
+	testCases := []struct {
+		name          string
+		logs          []testCTLog
+		corrupt       int
+		minSCTs       int
+		minOperators  int
+		expectedError string
+	}{
+		{
+			name:         "two operators",
+			logs:         []testCTLog{logs[0], logs[1]},
+			corrupt:      -1,
+			minSCTs:      2,
+			minOperators: 2,
+		},
+		{
+			name:          "one operator",
+			logs:          []testCTLog{logs[0], logs[2]},
+			corrupt:       -1,
+			minSCTs:       2,
+			minOperators:  2,
+			expectedError: "found 2 valid SCTs from 1 log operators (Operator A)",
+		},
+		{
+			name:          "invalid signature",
+			logs:          []testCTLog{logs[0], logs[1]},
+			corrupt:       1,
+			minSCTs:       2,
+			minOperators:  2,
+			expectedError: "invalid SCT signature",
+		},
+		{
+			name:          "unknown log",
+			logs:          []testCTLog{logs[0], unknownLog},
+			corrupt:       -1,
+			minSCTs:       2,
+			minOperators:  1,
+			expectedError: "log is not in the CT log list",
+		},
+		{
+			name:         "relaxed policy",
+			logs:         []testCTLog{logs[0], unknownLog},
+			corrupt:      -1,
+			minSCTs:      1,
+			minOperators: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cert, issuer := testGenerateSCTCertificate(t, tc.corrupt, tc.logs...)
+			err := checkCertificateSCTs(cert, issuer, logList, tc.minSCTs, tc.minOperators)
+			switch {
+			case tc.expectedError == "" && err != nil:
+				t.Fatalf("expected no error, got %s", err)
+			case tc.expectedError != "" && err == nil:
+				t.Fatalf("expected error containing %q, got none", tc.expectedError)
+			case tc.expectedError != "" && !strings.Contains(err.Error(), tc.expectedError):
+				t.Fatalf("expected error containing %q, got %s", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestExpandCTLogList(t *testing.T) {
+	// The embedded log list is always valid, but is only populated by go
+	// generate.
+	if _, err := parseCTLogList(embeddedCTLogList); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := testGenerateCTLogs(t, "Operator A")
+	actual, err := expandCTLogList(map[string]any{
+		"log_list_json": string(testCTLogListJSON(t, logs...)),
+		"log_list_url":  "https://127.0.0.1:1/log_list.json",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(actual) != 1 {
+		t.Fatalf("expected the log list in log_list_json to be used, got %d logs", len(actual))
+	}
+}
@@ -0,0 +1,352 @@
+package acme
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/go-jose/go-jose/v4"
+	"golang.org/x/net/idna"
+)
+
+const (
+	clientStorageFormatLego    = "lego"
+	clientStorageFormatCertbot = "certbot"
+)
+
+// clientStorageData represents the account and certificate data read out of
+// the on-disk storage of another ACME client.
+type clientStorageData struct {
+	// The PEM-encoded account private key.
+	AccountKeyPEM []byte
+
+	// The account registration, if it could be found.
+	Registration *registration.Resource
+
+	// The email address associated with the account, if any.
+	EmailAddress string
+
+	// The certificate resource. Certificate contains the full bundle (issued
+	// certificate first, followed by the issuer chain).
+	Certificate *certificate.Resource
+}
+
+// readLegoClientStorage reads an account and certificate out of a lego CLI
+// storage directory (usually ".lego").
+//
+// The account is looked up under the directory for the supplied server URL. If
+// email is empty and only one account exists for the server, that account is
+// used.
+func readLegoClientStorage(root, serverURL, name, email string) (*clientStorageData, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing server URL: %w", err)
+	}
+
+	// Account storage. This mirrors the layout used in lego's AccountsStorage.
+	serverPath := strings.NewReplacer(":", "_", "/", string(os.PathSeparator)).Replace(u.Host)
+	accountsPath := filepath.Join(root, "accounts", serverPath)
+	if email == "" {
+		entries, err := os.ReadDir(accountsPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading lego accounts directory: %w", err)
+		}
+
+		var found []string
+		for _, e := range entries {
+			if e.IsDir() {
+				found = append(found, e.Name())
+			}
+		}
+
+		switch len(found) {
+		case 0:
+			return nil, fmt.Errorf("no lego accounts found in %s", accountsPath)
+		case 1:
+			email = found[0]
+		default:
+			return nil, fmt.Errorf(
+				"multiple lego accounts found in %s (%s); set email_address to select one",
+				accountsPath,
+				strings.Join(found, ", "),
+			)
+		}
+	}
+
+	userPath := filepath.Join(accountsPath, email)
+	keyPEM, err := os.ReadFile(filepath.Join(userPath, "keys", email+".key"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading lego account key: %w", err)
+	}
+
+	result := &clientStorageData{
+		AccountKeyPEM: keyPEM,
+	}
+
+	accountBytes, err := os.ReadFile(filepath.Join(userPath, "account.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading lego account file: %w", err)
+	}
+
+	var account struct {
+		Email        string                 `json:"email"`
+		Registration *registration.Resource `json:"registration"`
+	}
+	if err := json.Unmarshal(accountBytes, &account); err != nil {
+		return nil, fmt.Errorf("error parsing lego account file: %w", err)
+	}
+
+	result.EmailAddress = account.Email
+	result.Registration = account.Registration
+
+	// Certificate storage. Names are sanitized in the same fashion as lego
+	// (wildcards and ports replaced, IDNs converted to punycode).
+	baseName, err := idna.ToASCII(strings.NewReplacer(":", "-", "*", "_").Replace(name))
+	if err != nil {
+		return nil, fmt.Errorf("error sanitizing certificate name %q: %w", name, err)
+	}
+
+	basePath := filepath.Join(root, "certificates", baseName)
+	certBytes, err := os.ReadFile(basePath + ".crt")
+	if err != nil {
+		return nil, fmt.Errorf("error reading lego certificate: %w", err)
+	}
+
+	// Certificates saved with --no-bundle only contain the issued certificate,
+	// add the issuer in this case.
+	if certs, err := parsePEMBundle(certBytes); err == nil && len(certs) == 1 {
+		issuerBytes, err := os.ReadFile(basePath + ".issuer.crt")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error reading lego issuer certificate: %w", err)
+		}
+
+		certBytes = append(certBytes, issuerBytes...)
+	}
+
+	cert := &certificate.Resource{}
+	if resBytes, err := os.ReadFile(basePath + ".json"); err == nil {
+		if err := json.Unmarshal(resBytes, cert); err != nil {
+			return nil, fmt.Errorf("error parsing lego certificate resource: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading lego certificate resource: %w", err)
+	}
+
+	cert.Certificate = certBytes
+
+	// The private key will not be present when the certificate was obtained
+	// using a CSR.
+	if keyBytes, err := os.ReadFile(basePath + ".key"); err == nil {
+		cert.PrivateKey = keyBytes
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error reading lego certificate private key: %w", err)
+	}
+
+	result.Certificate = cert
+	return result, nil
+}
+
+// readCertbotClientStorage reads an account and certificate out of a certbot
+// configuration directory (usually "/etc/letsencrypt").
+//
+// The certificate lineage is located using its renewal configuration file,
+// which also contains the server and account ID used to look up the account.
+func readCertbotClientStorage(root, name string) (*clientStorageData, error) {
+	renewalPath := filepath.Join(root, "renewal", name+".conf")
+	conf, err := readCertbotRenewalConf(renewalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Certificate files. These are usually absolute paths in the renewal
+	// configuration, but fall back to the default live directory layout if
+	// they are missing.
+	livePath := filepath.Join(root, "live", name)
+	certPath := conf.getDefault("", "cert", filepath.Join(livePath, "cert.pem"))
+	chainPath := conf.getDefault("", "chain", filepath.Join(livePath, "chain.pem"))
+	keyPath := conf.getDefault("", "privkey", filepath.Join(livePath, "privkey.pem"))
+
+	certBytes, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certbot certificate: %w", err)
+	}
+
+	chainBytes, err := os.ReadFile(chainPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certbot chain: %w", err)
+	}
+
+	keyBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certbot certificate private key: %w", err)
+	}
+
+	certs, err := parsePEMBundle(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing certbot certificate: %w", err)
+	}
+
+	domain, err := certcrypto.GetCertificateMainDomain(certs[0])
+	if err != nil {
+		return nil, fmt.Errorf("error reading domain from certbot certificate: %w", err)
+	}
+
+	bundle := append(bytes.TrimSpace(certBytes), '\n')
+	bundle = append(bundle, chainBytes...)
+	result := &clientStorageData{
+		Certificate: &certificate.Resource{
+			Domain:      domain,
+			Certificate: bundle,
+			PrivateKey:  keyBytes,
+		},
+	}
+
+	// Account. Certbot stores accounts under a path derived from the server
+	// URL's host and path, followed by the account ID.
+	server, ok := conf.get("renewalparams", "server")
+	if !ok {
+		return nil, fmt.Errorf("%s: server not found in renewalparams", renewalPath)
+	}
+
+	accountID, ok := conf.get("renewalparams", "account")
+	if !ok {
+		return nil, fmt.Errorf("%s: account not found in renewalparams", renewalPath)
+	}
+
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, fmt.Errorf("%s: error parsing server URL: %w", renewalPath, err)
+	}
+
+	accountPath := filepath.Join(root, "accounts", u.Host, filepath.FromSlash(u.Path), accountID)
+	jwkBytes, err := os.ReadFile(filepath.Join(accountPath, "private_key.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading certbot account key: %w", err)
+	}
+
+	var jwk jose.JSONWebKey
+	if err := json.Unmarshal(jwkBytes, &jwk); err != nil {
+		return nil, fmt.Errorf("error parsing certbot account key: %w", err)
+	}
+
+	if jwk.IsPublic() {
+		return nil, errors.New("certbot account key does not contain a private key")
+	}
+
+	result.AccountKeyPEM = certcrypto.PEMEncode(jwk.Key)
+
+	regrBytes, err := os.ReadFile(filepath.Join(accountPath, "regr.json"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading certbot account registration: %w", err)
+	}
+
+	reg := &registration.Resource{}
+	if err := json.Unmarshal(regrBytes, reg); err != nil {
+		return nil, fmt.Errorf("error parsing certbot account registration: %w", err)
+	}
+
+	result.Registration = reg
+	for _, c := range reg.Body.Contact {
+		if email, ok := strings.CutPrefix(c, "mailto:"); ok {
+			result.EmailAddress = email
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// certbotRenewalConf is a minimal representation of a certbot renewal
+// configuration file. Keys are indexed by section, with top-level keys under
+// the empty section.
+type certbotRenewalConf map[string]map[string]string
+
+func (c certbotRenewalConf) get(section, key string) (string, bool) {
+	v, ok := c[section][key]
+	return v, ok && v != ""
+}
+
+func (c certbotRenewalConf) getDefault(section, key, def string) string {
+	if v, ok := c.get(section, key); ok {
+		return v
+	}
+
+	return def
+}
+
+// readCertbotRenewalConf parses the certbot renewal configuration at path.
+//
+// Only the subset of the configobj format used by certbot is supported:
+// comments, "[section]" headers, and "key = value" pairs.
+func readCertbotRenewalConf(path string) (certbotRenewalConf, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certbot renewal configuration: %w", err)
+	}
+	defer f.Close()
+
+	conf := certbotRenewalConf{"": {}}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.Trim(line, "[]")
+			if _, ok := conf[section]; !ok {
+				conf[section] = map[string]string{}
+			}
+
+		default:
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s: malformed line: %q", path, line)
+			}
+
+			conf[section][strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading certbot renewal configuration: %w", err)
+	}
+
+	return conf, nil
+}
+
+// keyTypeForPrivateKey returns the key_type value of acme_certificate that
+// corresponds to the supplied private key.
+func keyTypeForPrivateKey(key crypto.PrivateKey) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return strconv.Itoa(k.N.BitLen()), nil
+
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().Name {
+		case "P-256":
+			return keyECDSACurveP256, nil
+		case "P-384":
+			return keyECDSACurveP384, nil
+		}
+
+		return "", fmt.Errorf("unsupported ECDSA curve %q", k.Curve.Params().Name)
+	}
+
+	return "", fmt.Errorf("unsupported private key type %T", key)
+}
@@ -0,0 +1,246 @@
+package acme
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const testClientStorageServerURL = "https://localhost:14000/dir"
+
+const testClientStorageRegURL = "https://localhost:14000/my-account/1"
+
+func testWriteFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testLegoClientStorage(t *testing.T, b *testCertificateBundle) string {
+	t.Helper()
+	root := t.TempDir()
+	userPath := filepath.Join(root, "accounts", "localhost_14000", "nobody@example.com")
+	testWriteFile(t, filepath.Join(userPath, "keys", "nobody@example.com.key"), []byte(testPrivateKeyPKCS1Text))
+	testWriteFile(t, filepath.Join(userPath, "account.json"), []byte(`{
+	"email": "nobody@example.com",
+	"registration": {
+		"body": {"status": "valid", "contact": ["mailto:nobody@example.com"]},
+		"uri": "`+testClientStorageRegURL+`"
+	}
+}`))
+
+	certPath := filepath.Join(root, "certificates", "_.example.com")
+	testWriteFile(t, certPath+".crt", b.Bundle())
+	testWriteFile(t, certPath+".issuer.crt", b.IssuerPEM)
+	testWriteFile(t, certPath+".key", b.KeyPEM)
+	testWriteFile(t, certPath+".json", []byte(`{
+	"domain": "*.example.com",
+	"certUrl": "https://localhost:14000/certZ/abc",
+	"certStableUrl": "https://localhost:14000/certZ/abc"
+}`))
+
+	return root
+}
+
+func testCertbotClientStorage(t *testing.T, b *testCertificateBundle) string {
+	t.Helper()
+	root := t.TempDir()
+	livePath := filepath.Join(root, "live", "example.com")
+	testWriteFile(t, filepath.Join(livePath, "cert.pem"), b.CertPEM)
+	testWriteFile(t, filepath.Join(livePath, "chain.pem"), b.IssuerPEM)
+	testWriteFile(t, filepath.Join(livePath, "privkey.pem"), b.KeyPEM)
+	testWriteFile(t, filepath.Join(root, "renewal", "example.com.conf"), []byte(`# renew_before_expiry = 30 days
+version = 2.11.0
+archive_dir = `+filepath.Join(root, "archive", "example.com")+`
+cert = `+filepath.Join(livePath, "cert.pem")+`
+privkey = `+filepath.Join(livePath, "privkey.pem")+`
+chain = `+filepath.Join(livePath, "chain.pem")+`
+fullchain = `+filepath.Join(livePath, "fullchain.pem")+`
+
+# Options used in the renewal process
+[renewalparams]
+account = 0123456789abcdef
+authenticator = standalone
+server = `+testClientStorageServerURL+`
+key_type = ecdsa
+`))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwk, err := json.Marshal(jose.JSONWebKey{Key: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accountPath := filepath.Join(root, "accounts", "localhost:14000", "dir", "0123456789abcdef")
+	testWriteFile(t, filepath.Join(accountPath, "private_key.json"), jwk)
+	testWriteFile(t, filepath.Join(accountPath, "regr.json"), []byte(`{
+	"body": {"contact": ["mailto:nobody@example.com"]},
+	"uri": "`+testClientStorageRegURL+`"
+}`))
+
+	return root
+}
+
+func TestReadLegoClientStorage(t *testing.T) {
+	b := testGenerateCertificateBundle(
+		t, time.Now(), time.Now().Add(time.Hour*24*90), []string{"*.example.com", "example.com"}, nil)
+	root := testLegoClientStorage(t, b)
+
+	for _, email := range []string{"", "nobody@example.com"} {
+		data, err := readLegoClientStorage(root, testClientStorageServerURL, "*.example.com", email)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if data.EmailAddress != "nobody@example.com" {
+			t.Fatalf("expected email address to be nobody@example.com, got %q", data.EmailAddress)
+		}
+
+		if data.Registration.URI != testClientStorageRegURL {
+			t.Fatalf("expected registration URL to be %q, got %q", testClientStorageRegURL, data.Registration.URI)
+		}
+
+		if string(data.AccountKeyPEM) != testPrivateKeyPKCS1Text {
+			t.Fatalf("unexpected account key: %s", data.AccountKeyPEM)
+		}
+
+		if data.Certificate.CertURL != "https://localhost:14000/certZ/abc" {
+			t.Fatalf("unexpected certificate URL: %q", data.Certificate.CertURL)
+		}
+
+		if string(data.Certificate.Certificate) != string(b.Bundle()) {
+			t.Fatalf("unexpected certificate bundle: %s", data.Certificate.Certificate)
+		}
+	}
+}
+
+func TestReadLegoClientStorage_multipleAccounts(t *testing.T) {
+	b := testGenerateCertificateBundle(
+		t, time.Now(), time.Now().Add(time.Hour*24*90), []string{"*.example.com"}, nil)
+	root := testLegoClientStorage(t, b)
+	testWriteFile(
+		t,
+		filepath.Join(root, "accounts", "localhost_14000", "other@example.com", "account.json"),
+		[]byte("{}"),
+	)
+
+	_, err := readLegoClientStorage(root, testClientStorageServerURL, "*.example.com", "")
+	if err == nil || !strings.Contains(err.Error(), "multiple lego accounts found") {
+		t.Fatalf("expected multiple accounts error, got %v", err)
+	}
+}
+
+func TestReadCertbotClientStorage(t *testing.T) {
+	b := testGenerateCertificateBundle(
+		t, time.Now(), time.Now().Add(time.Hour*24*90), []string{"example.com", "www.example.com"}, nil)
+	root := testCertbotClientStorage(t, b)
+
+	data, err := readCertbotClientStorage(root, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.EmailAddress != "nobody@example.com" {
+		t.Fatalf("expected email address to be nobody@example.com, got %q", data.EmailAddress)
+	}
+
+	if data.Registration.URI != testClientStorageRegURL {
+		t.Fatalf("expected registration URL to be %q, got %q", testClientStorageRegURL, data.Registration.URI)
+	}
+
+	if _, err := privateKeyFromPEM(data.AccountKeyPEM); err != nil {
+		t.Fatalf("error parsing converted account key: %s", err)
+	}
+
+	certs, err := parsePEMBundle(data.Certificate.Certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(certs) != 2 {
+		t.Fatalf("expected 2 certificates in bundle, got %d", len(certs))
+	}
+
+	if data.Certificate.Domain != "example.com" {
+		t.Fatalf("expected domain to be example.com, got %q", data.Certificate.Domain)
+	}
+}
+
+func TestDataSourceACMEClientStorageRead(t *testing.T) {
+	b := testGenerateCertificateBundle(
+		t, time.Now(), time.Now().Add(time.Hour*24*90), []string{"example.com", "www.example.com"}, nil)
+
+	testCases := []struct {
+		format string
+		root   string
+		name   string
+	}{
+		{
+			format: clientStorageFormatLego,
+			root:   testLegoClientStorage(t, b),
+			name:   "*.example.com",
+		},
+		{
+			format: clientStorageFormatCertbot,
+			root:   testCertbotClientStorage(t, b),
+			name:   "example.com",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			d := dataSourceACMEClientStorage().TestResourceData()
+			d.Set("storage_format", tc.format)
+			d.Set("storage_path", tc.root)
+			d.Set("certificate_name", tc.name)
+			if err := dataSourceACMEClientStorageRead(d, &Config{ServerURL: testClientStorageServerURL}); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := d.Get("registration_url").(string); got != testClientStorageRegURL {
+				t.Fatalf("expected registration_url to be %q, got %q", testClientStorageRegURL, got)
+			}
+
+			if got := d.Get("common_name").(string); got != "example.com" {
+				t.Fatalf("expected common_name to be example.com, got %q", got)
+			}
+
+			sans := stringSlice(d.Get("subject_alternative_names").(*schema.Set).List())
+			if len(sans) != 1 || sans[0] != "www.example.com" {
+				t.Fatalf("expected subject_alternative_names to be [www.example.com], got %#v", sans)
+			}
+
+			if got := d.Get("key_type").(string); got != keyECDSACurveP256 {
+				t.Fatalf("expected key_type to be %q, got %q", keyECDSACurveP256, got)
+			}
+
+			if got := d.Get("certificate_pem").(string); got != string(b.CertPEM) {
+				t.Fatalf("unexpected certificate_pem: %s", got)
+			}
+
+			if got := d.Get("issuer_pem").(string); got != string(b.IssuerPEM) {
+				t.Fatalf("unexpected issuer_pem: %s", got)
+			}
+
+			if got := d.Get("private_key_pem").(string); got != string(b.KeyPEM) {
+				t.Fatalf("unexpected private_key_pem: %s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package acme
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func dataSourceACMEClientStorage() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceACMEClientStorageRead,
+		Schema: map[string]*schema.Schema{
+			"storage_format": {
+				Type:        schema.TypeString,
+				Description: "The layout of the storage directory. Can be one of lego or certbot.",
+				Required:    true,
+				ValidateFunc: validation.StringInSlice(
+					[]string{clientStorageFormatLego, clientStorageFormatCertbot},
+					false,
+				),
+			},
+			"storage_path": {
+				Type:        schema.TypeString,
+				Description: "The path to the storage directory (example: .lego or /etc/letsencrypt).",
+				Required:    true,
+			},
+			"certificate_name": {
+				Type:        schema.TypeString,
+				Description: "The name of the certificate: the main domain for lego, or the lineage name for certbot.",
+				Required:    true,
+			},
+			"email_address": {
+				Type:        schema.TypeString,
+				Description: "The email address of the account to read (lego only). Found automatically if unset.",
+				Optional:    true,
+				Computed:    true,
+			},
+			"account_key_pem": {
+				Type:        schema.TypeString,
+				Description: "The private key of the account, in PEM format.",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"registration_url": {
+				Type:        schema.TypeString,
+				Description: "The URL of the account, as recorded by the client.",
+				Computed:    true,
+			},
+			"certificate_url": {
+				Type:        schema.TypeString,
+				Description: "The URL of the certificate, if recorded by the client.",
+				Computed:    true,
+			},
+			"certificate_domain": {
+				Type:        schema.TypeString,
+				Description: "The main domain of the certificate.",
+				Computed:    true,
+			},
+			"common_name": {
+				Type:        schema.TypeString,
+				Description: "The common name of the certificate.",
+				Computed:    true,
+			},
+			"subject_alternative_names": {
+				Type:        schema.TypeSet,
+				Description: "The DNS names of the certificate, excluding the common name.",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Set:         schema.HashString,
+			},
+			"key_type": {
+				Type:        schema.TypeString,
+				Description: "The key type of the certificate private key, in the format expected by acme_certificate.",
+				Computed:    true,
+			},
+			"private_key_pem": {
+				Type:        schema.TypeString,
+				Description: "The private key of the certificate, in PEM format.",
+				Computed:    true,
+				Sensitive:   true,
+			},
+			"certificate_pem": {
+				Type:        schema.TypeString,
+				Description: "The certificate, in PEM format.",
+				Computed:    true,
+			},
+			"issuer_pem": {
+				Type:        schema.TypeString,
+				Description: "The intermediate certificates of the issuer, in PEM format.",
+				Computed:    true,
+			},
+			"certificate_not_before": {
+				Type:        schema.TypeString,
+				Description: "The start of the validity period of the certificate, in RFC3339 format.",
+				Computed:    true,
+			},
+			"certificate_not_after": {
+				Type:        schema.TypeString,
+				Description: "The expiry date of the certificate, in RFC3339 format.",
+				Computed:    true,
+			},
+			"certificate_serial": {
+				Type:        schema.TypeString,
+				Description: "The serial number of the certificate.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceACMEClientStorageRead(d *schema.ResourceData, meta any) error {
+	root := d.Get("storage_path").(string)
+	name := d.Get("certificate_name").(string)
+
+	var data *clientStorageData
+	var err error
+	switch d.Get("storage_format").(string) {
+	case clientStorageFormatLego:
+		data, err = readLegoClientStorage(root, meta.(*Config).ServerURL, name, d.Get("email_address").(string))
+	case clientStorageFormatCertbot:
+		data, err = readCertbotClientStorage(root, name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Validate the account key before exporting it.
+	if _, err := privateKeyFromPEM(data.AccountKeyPEM); err != nil {
+		return fmt.Errorf("error parsing account key: %w", err)
+	}
+
+	d.Set("account_key_pem", string(data.AccountKeyPEM))
+	d.Set("email_address", data.EmailAddress)
+	if data.Registration != nil {
+		d.Set("registration_url", data.Registration.URI)
+	} else {
+		d.Set("registration_url", "")
+	}
+
+	cert := data.Certificate
+	issued, issuedNotBefore, issuedNotAfter, issuedSerial, issuer, err := splitPEMBundle(cert.Certificate)
+	if err != nil {
+		return err
+	}
+
+	x509Certs, err := parsePEMBundle(issued)
+	if err != nil {
+		return err
+	}
+
+	cn := x509Certs[0].Subject.CommonName
+	var sans []string
+	for _, v := range certcrypto.ExtractDomains(x509Certs[0]) {
+		if v != cn {
+			sans = append(sans, v)
+		}
+	}
+
+	domain := cert.Domain
+	if domain == "" {
+		domain, err = certcrypto.GetCertificateMainDomain(x509Certs[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	d.Set("certificate_url", cert.CertURL)
+	d.Set("certificate_domain", domain)
+	d.Set("common_name", cn)
+	d.Set("subject_alternative_names", sans)
+	d.Set("certificate_pem", string(issued))
+	d.Set("issuer_pem", string(issuer))
+	d.Set("certificate_not_before", issuedNotBefore)
+	d.Set("certificate_not_after", issuedNotAfter)
+	d.Set("certificate_serial", issuedSerial)
+
+	if len(cert.PrivateKey) > 0 {
+		pk, err := privateKeyFromPEM(cert.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("error parsing certificate private key: %w", err)
+		}
+
+		keyType, err := keyTypeForPrivateKey(pk)
+		if err != nil {
+			return err
+		}
+
+		d.Set("private_key_pem", string(cert.PrivateKey))
+		d.Set("key_type", keyType)
+	} else {
+		d.Set("private_key_pem", "")
+		d.Set("key_type", "")
+	}
+
+	d.SetId(filepath.Join(root, name))
+	return nil
+}
@@ -0,0 +1,83 @@
+package acme
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceACMERegistration() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceACMERegistrationRead,
+		Schema: map[string]*schema.Schema{
+			"account_key_pem": {
+				Type:         schema.TypeString,
+				Description:  "The private key of the account to look up, in PEM format.",
+				Optional:     true,
+				Sensitive:    true,
+				ExactlyOneOf: []string{"account_key_pem", "account_key_pkcs11_uri"},
+			},
+			"account_key_pkcs11_uri": {
+				Type:         schema.TypeString,
+				Description:  "A PKCS#11 URI referencing the private key of the account to look up, in a hardware security module or other PKCS#11 token.",
+				Optional:     true,
+				Sensitive:    true,
+				ExactlyOneOf: []string{"account_key_pem", "account_key_pkcs11_uri"},
+				ValidateFunc: validatePKCS11URI,
+			},
+			"registration_url": {
+				Type:        schema.TypeString,
+				Description: "The full URL of the account.",
+				Computed:    true,
+			},
+			"status": {
+				Type:        schema.TypeString,
+				Description: "The status of the account, as reported by the CA.",
+				Computed:    true,
+			},
+			"contacts": {
+				Type:        schema.TypeList,
+				Description: "The contact URLs of the account, as reported by the CA.",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"email_address": {
+				Type:        schema.TypeString,
+				Description: "The first email address found in the account contacts, if any.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceACMERegistrationRead(d *schema.ResourceData, meta any) error {
+	_, user, err := expandACMEClient(d, meta, true)
+	if err != nil {
+		if regGone(err) {
+			return fmt.Errorf(
+				"no active account found for the supplied account key on %s: %w",
+				meta.(*Config).ServerURL,
+				err,
+			)
+		}
+
+		return err
+	}
+
+	reg := user.Registration
+	var email string
+	for _, c := range reg.Body.Contact {
+		if v, ok := strings.CutPrefix(c, "mailto:"); ok {
+			email = v
+			break
+		}
+	}
+
+	d.SetId(reg.URI)
+	d.Set("registration_url", reg.URI)
+	d.Set("status", reg.Body.Status)
+	d.Set("contacts", reg.Body.Contact)
+	d.Set("email_address", email)
+	return nil
+}
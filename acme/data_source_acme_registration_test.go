@@ -0,0 +1,81 @@
+package acme
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccACMERegistrationDataSource_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMERegistrationDataSourceConfig(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair(
+						"data.acme_registration.reg", "id",
+						"acme_registration.reg", "registration_url",
+					),
+					resource.TestCheckResourceAttrPair(
+						"data.acme_registration.reg", "registration_url",
+						"acme_registration.reg", "registration_url",
+					),
+					resource.TestCheckResourceAttr("data.acme_registration.reg", "status", "valid"),
+					resource.TestCheckResourceAttr("data.acme_registration.reg", "contacts.#", "1"),
+					resource.TestCheckResourceAttr("data.acme_registration.reg", "contacts.0", "mailto:nobody@example.test"),
+					resource.TestCheckResourceAttr("data.acme_registration.reg", "email_address", "nobody@example.test"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccACMERegistrationDataSource_notFound(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccACMERegistrationDataSourceConfigNotFound(),
+				ExpectError: regexp.MustCompile(`no active account found for the supplied account key`),
+			},
+		},
+	})
+}
+
+func testAccACMERegistrationDataSourceConfig() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address = "nobody@example.test"
+}
+
+data "acme_registration" "reg" {
+  account_key_pem = acme_registration.reg.account_key_pem
+}
+`, pebbleDirBasic)
+}
+
+func testAccACMERegistrationDataSourceConfigNotFound() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+resource "tls_private_key" "private_key" {
+  algorithm   = "ECDSA"
+  ecdsa_curve = "P384"
+}
+
+data "acme_registration" "reg" {
+  account_key_pem = tls_private_key.private_key.private_key_pem
+}
+`, pebbleDirBasic)
+}
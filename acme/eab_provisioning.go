@@ -0,0 +1,193 @@
+package acme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	eabProvisioningTypeZeroSSL = "zerossl"
+	eabProvisioningTypeHTTP    = "http"
+
+	// The default ZeroSSL API endpoint, used for fetching EAB credentials.
+	eabProvisioningZeroSSLDefaultURL = "https://api.zerossl.com"
+
+	// The default fields in the JSON response of the generic HTTP adapter.
+	eabProvisioningHTTPDefaultKeyIDField = "kid"
+	eabProvisioningHTTPDefaultHMACField  = "hmac"
+)
+
+// eabProvisioningTimeout is the timeout for requests made to EAB provisioning
+// endpoints.
+const eabProvisioningTimeout = time.Second * 30
+
+// eabProvisioningMaxBodySize is the maximum size of a response body that will
+// be read from an EAB provisioning endpoint.
+const eabProvisioningMaxBodySize = 1024 * 1024
+
+// eabCredentials represents external account binding credentials fetched from
+// an EAB provisioning endpoint.
+type eabCredentials struct {
+	KeyID       string
+	HMACEncoded string
+}
+
+// provisionEABCredentials fetches external account binding credentials using
+// the settings in an eab_provisioning block. email is the email address of the
+// registration, used by providers that can look up credentials by email.
+func provisionEABCredentials(opts map[string]any, email string) (*eabCredentials, error) {
+	client := &http.Client{Timeout: eabProvisioningTimeout}
+
+	switch opts["type"].(string) {
+	case eabProvisioningTypeZeroSSL:
+		return provisionEABCredentialsZeroSSL(client, opts, email)
+
+	case eabProvisioningTypeHTTP:
+		return provisionEABCredentialsHTTP(client, opts)
+	}
+
+	return nil, fmt.Errorf("unknown eab_provisioning type %q", opts["type"].(string))
+}
+
+// provisionEABCredentialsZeroSSL fetches EAB credentials from the ZeroSSL API.
+// If an API key is supplied, it is used, otherwise the credentials are fetched
+// using the registration's email address.
+func provisionEABCredentialsZeroSSL(client *http.Client, opts map[string]any, email string) (*eabCredentials, error) {
+	baseURL := eabProvisioningZeroSSLDefaultURL
+	if v, ok := opts["url"].(string); ok && v != "" {
+		baseURL = v
+	}
+
+	var req *http.Request
+	var err error
+	if apiKey, ok := opts["api_key"].(string); ok && apiKey != "" {
+		req, err = http.NewRequest(
+			http.MethodPost,
+			strings.TrimSuffix(baseURL, "/")+"/acme/eab-credentials?access_key="+url.QueryEscape(apiKey),
+			nil,
+		)
+	} else {
+		if email == "" {
+			return nil, errors.New("eab_provisioning: email_address or api_key is required for zerossl")
+		}
+
+		req, err = http.NewRequest(
+			http.MethodPost,
+			strings.TrimSuffix(baseURL, "/")+"/acme/eab-credentials-email",
+			strings.NewReader(url.Values{"email": []string{email}}.Encode()),
+		)
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("eab_provisioning: error creating request: %w", err)
+	}
+
+	var result struct {
+		Success    bool   `json:"success"`
+		EABKeyID   string `json:"eab_kid"`
+		EABHMACKey string `json:"eab_hmac_key"`
+		Error      struct {
+			Code int    `json:"code"`
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+
+	body, err := doEABProvisioningRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("eab_provisioning: error parsing ZeroSSL response: %w", err)
+	}
+
+	if !result.Success {
+		return nil, fmt.Errorf("eab_provisioning: ZeroSSL returned error: %s (code %d)", result.Error.Type, result.Error.Code)
+	}
+
+	return newEABCredentials(result.EABKeyID, result.EABHMACKey)
+}
+
+// provisionEABCredentialsHTTP fetches EAB credentials using the generic HTTP
+// adapter: a POST to the configured URL that returns a JSON object containing
+// the key ID and HMAC key.
+func provisionEABCredentialsHTTP(client *http.Client, opts map[string]any) (*eabCredentials, error) {
+	endpoint, _ := opts["url"].(string)
+	if endpoint == "" {
+		return nil, errors.New("eab_provisioning: url is required for http")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("eab_provisioning: error creating request: %w", err)
+	}
+
+	if headers, ok := opts["headers"].(map[string]any); ok {
+		for k, v := range headers {
+			req.Header.Set(k, v.(string))
+		}
+	}
+
+	body, err := doEABProvisioningRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("eab_provisioning: error parsing response: %w", err)
+	}
+
+	keyIDField := eabProvisioningHTTPDefaultKeyIDField
+	if v, ok := opts["key_id_field"].(string); ok && v != "" {
+		keyIDField = v
+	}
+
+	hmacField := eabProvisioningHTTPDefaultHMACField
+	if v, ok := opts["hmac_field"].(string); ok && v != "" {
+		hmacField = v
+	}
+
+	keyID, _ := result[keyIDField].(string)
+	hmac, _ := result[hmacField].(string)
+	return newEABCredentials(keyID, hmac)
+}
+
+func doEABProvisioningRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("eab_provisioning: error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, eabProvisioningMaxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("eab_provisioning: error reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("eab_provisioning: unexpected response status: %s", resp.Status)
+	}
+
+	return body, nil
+}
+
+func newEABCredentials(keyID, hmac string) (*eabCredentials, error) {
+	if keyID == "" || hmac == "" {
+		return nil, errors.New("eab_provisioning: response did not contain a key ID and HMAC key")
+	}
+
+	return &eabCredentials{
+		KeyID:       keyID,
+		HMACEncoded: hmac,
+	}, nil
+}
@@ -0,0 +1,130 @@
+package acme
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProvisionEABCredentials_zeroSSLEmail(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/acme/eab-credentials-email" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if got := r.FormValue("email"); got != "nobody@example.com" {
+			t.Errorf("expected email to be nobody@example.com, got %q", got)
+		}
+
+		w.Write([]byte(`{"success":true,"eab_kid":"kid-1","eab_hmac_key":"aG1hYw"}`))
+	}))
+	defer srv.Close()
+
+	eab, err := provisionEABCredentials(map[string]any{
+		"type": eabProvisioningTypeZeroSSL,
+		"url":  srv.URL,
+	}, "nobody@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if eab.KeyID != "kid-1" || eab.HMACEncoded != "aG1hYw" {
+		t.Fatalf("unexpected credentials: %#v", eab)
+	}
+}
+
+func TestProvisionEABCredentials_zeroSSLAPIKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/acme/eab-credentials" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.URL.Query().Get("access_key") != "secret" {
+			w.Write([]byte(`{"success":false,"error":{"code":101,"type":"invalid_access_key"}}`))
+			return
+		}
+
+		w.Write([]byte(`{"success":true,"eab_kid":"kid-2","eab_hmac_key":"aG1hYw"}`))
+	}))
+	defer srv.Close()
+
+	eab, err := provisionEABCredentials(map[string]any{
+		"type":    eabProvisioningTypeZeroSSL,
+		"url":     srv.URL,
+		"api_key": "secret",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if eab.KeyID != "kid-2" {
+		t.Fatalf("expected key ID to be kid-2, got %q", eab.KeyID)
+	}
+
+	_, err = provisionEABCredentials(map[string]any{
+		"type":    eabProvisioningTypeZeroSSL,
+		"url":     srv.URL,
+		"api_key": "wrong",
+	}, "")
+	if err == nil || !strings.Contains(err.Error(), "invalid_access_key") {
+		t.Fatalf("expected invalid_access_key error, got %v", err)
+	}
+}
+
+func TestProvisionEABCredentials_zeroSSLNoEmail(t *testing.T) {
+	_, err := provisionEABCredentials(map[string]any{
+		"type": eabProvisioningTypeZeroSSL,
+	}, "")
+	if err == nil || !strings.Contains(err.Error(), "email_address or api_key is required") {
+		t.Fatalf("expected missing email error, got %v", err)
+	}
+}
+
+func TestProvisionEABCredentials_http(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"keyId":"kid-3","hmacKey":"aG1hYw"}`))
+	}))
+	defer srv.Close()
+
+	opts := map[string]any{
+		"type":         eabProvisioningTypeHTTP,
+		"url":          srv.URL,
+		"headers":      map[string]any{"Authorization": "Bearer token"},
+		"key_id_field": "keyId",
+		"hmac_field":   "hmacKey",
+	}
+	eab, err := provisionEABCredentials(opts, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if eab.KeyID != "kid-3" || eab.HMACEncoded != "aG1hYw" {
+		t.Fatalf("unexpected credentials: %#v", eab)
+	}
+
+	opts["headers"] = map[string]any{}
+	_, err = provisionEABCredentials(opts, "")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+
+	opts["headers"] = map[string]any{"Authorization": "Bearer token"}
+	opts["key_id_field"] = eabProvisioningHTTPDefaultKeyIDField
+	_, err = provisionEABCredentials(opts, "")
+	if err == nil || !strings.Contains(err.Error(), "did not contain a key ID and HMAC key") {
+		t.Fatalf("expected missing field error, got %v", err)
+	}
+}
@@ -31,17 +31,39 @@ package acme
 
 import (
 	"crypto"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge/resolver"
+	"github.com/go-acme/lego/v4/lego"
 	"github.com/go-acme/lego/v4/log"
+	"github.com/go-acme/lego/v4/platform/wait"
 )
 
 type localRenewOptions struct {
 	certificate.RenewOptions
 	UseARI bool
+
+	// The domains to renew the certificate for. If empty, the domains are
+	// extracted from the certificate being renewed.
+	Domains []string
+}
+
+// obtainRequest is a request for a certificate from either a list of
+// domains or a CSR, built by renewRequest.
+type obtainRequest struct {
+	certificate.ObtainRequest
+
+	// The CSR to obtain the certificate for. Domains and PrivateKey are not
+	// used if this is set.
+	CSR *x509.CertificateRequest
 }
 
 // renewWithOptions re-implements RenewWithOptions out of lego, with some
@@ -51,17 +73,40 @@ func renewWithOptions(
 	certRes certificate.Resource,
 	options localRenewOptions,
 ) (*certificate.Resource, error) {
+	request, err := renewRequest(certRes, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.CSR != nil {
+		return c.ObtainForCSR(certificate.ObtainForCSRRequest{
+			CSR:                            request.CSR,
+			NotBefore:                      request.NotBefore,
+			NotAfter:                       request.NotAfter,
+			Bundle:                         request.Bundle,
+			PreferredChain:                 request.PreferredChain,
+			Profile:                        request.Profile,
+			AlwaysDeactivateAuthorizations: request.AlwaysDeactivateAuthorizations,
+			ReplacesCertID:                 request.ReplacesCertID,
+		})
+	}
+
+	return c.Obtain(request.ObtainRequest)
+}
+
+// renewRequest returns the request to renew certRes with.
+func renewRequest(certRes certificate.Resource, options localRenewOptions) (obtainRequest, error) {
 	// Input certificate is PEM encoded.
 	// Decode it here as we may need the decoded cert later on in the renewal process.
 	// The input may be a bundle or a single certificate.
 	certificates, err := certcrypto.ParsePEMBundle(certRes.Certificate)
 	if err != nil {
-		return nil, err
+		return obtainRequest{}, err
 	}
 
 	x509Cert := certificates[0]
 	if x509Cert.IsCA {
-		return nil, fmt.Errorf("[%s] Certificate bundle starts with a CA certificate", certRes.Domain)
+		return obtainRequest{}, fmt.Errorf("[%s] Certificate bundle starts with a CA certificate", certRes.Domain)
 	}
 
 	// This is just meant to be informal for the user.
@@ -74,10 +119,10 @@ func renewWithOptions(
 	if len(certRes.CSR) > 0 {
 		csr, errP := certcrypto.PemDecodeTox509CSR(certRes.CSR)
 		if errP != nil {
-			return nil, errP
+			return obtainRequest{}, errP
 		}
 
-		request := certificate.ObtainForCSRRequest{CSR: csr}
+		request := obtainRequest{CSR: csr}
 
 		request.NotBefore = options.NotBefore
 		request.NotAfter = options.NotAfter
@@ -90,24 +135,31 @@ func renewWithOptions(
 			var err error
 			request.ReplacesCertID, err = certificate.MakeARICertID(x509Cert)
 			if err != nil {
-				return nil, fmt.Errorf("error generating ARI cert ID: %w", err)
+				return obtainRequest{}, fmt.Errorf("error generating ARI cert ID: %w", err)
 			}
 		}
 
-		return c.ObtainForCSR(request)
+		return request, nil
 	}
 
 	var privateKey crypto.PrivateKey
 	if certRes.PrivateKey != nil {
 		privateKey, err = certcrypto.ParsePEMPrivateKey(certRes.PrivateKey)
 		if err != nil {
-			return nil, err
+			return obtainRequest{}, err
 		}
 	}
 
-	request := certificate.ObtainRequest{
-		Domains:    certcrypto.ExtractDomains(x509Cert),
-		PrivateKey: privateKey,
+	domains := options.Domains
+	if len(domains) == 0 {
+		domains = certcrypto.ExtractDomains(x509Cert)
+	}
+
+	request := obtainRequest{
+		ObtainRequest: certificate.ObtainRequest{
+			Domains:    domains,
+			PrivateKey: privateKey,
+		},
 	}
 
 	request.MustStaple = options.MustStaple
@@ -123,9 +175,361 @@ func renewWithOptions(
 		var err error
 		request.ReplacesCertID, err = certificate.MakeARICertID(x509Cert)
 		if err != nil {
-			return nil, fmt.Errorf("error generating ARI cert ID: %w", err)
+			return obtainRequest{}, fmt.Errorf("error generating ARI cert ID: %w", err)
+		}
+	}
+
+	return request, nil
+}
+
+// publicKey returns the public key of the certificate to obtain, or nil if
+// the private key is generated when the order is finalized.
+func (r obtainRequest) publicKey() crypto.PublicKey {
+	if r.CSR != nil {
+		return r.CSR.PublicKey
+	}
+
+	if signer, ok := r.PrivateKey.(crypto.Signer); ok {
+		return signer.Public()
+	}
+
+	return nil
+}
+
+// certificateMatchesKey returns true if the first certificate in certPEM was
+// issued for publicKey.
+func certificateMatchesKey(certPEM []byte, publicKey crypto.PublicKey) bool {
+	key, ok := publicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return false
+	}
+
+	certs, err := certcrypto.ParsePEMBundle(certPEM)
+	if err != nil {
+		return false
+	}
+
+	return key.Equal(certs[0].PublicKey)
+}
+
+// orderIdentifiersEqual returns true if the identifiers of an order are the
+// same as domains, in any order.
+func orderIdentifiersEqual(identifiers []acme.Identifier, domains []string) bool {
+	normalize := func(v string) string {
+		if n, err := normalizeDomain(v); err == nil {
+			return n
+		}
+
+		return strings.ToLower(v)
+	}
+
+	want := make(map[string]bool)
+	for _, domain := range domains {
+		want[normalize(domain)] = true
+	}
+
+	got := make(map[string]bool)
+	for _, identifier := range identifiers {
+		got[normalize(identifier.Value)] = true
+	}
+
+	if len(want) != len(got) {
+		return false
+	}
+
+	for v := range want {
+		if !got[v] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// orderObtainer obtains certificates in the same way as lego's Certifier,
+// but with orders that can be checkpointed, and resumed from their current
+// status on a later run. Certifier always creates a new order, and does not
+// return it, so only the handling of the order is re-implemented here:
+// challenges are still solved with lego's resolver, and the chain is selected
+// with selectCertificateChain.
+type orderObtainer struct {
+	core     *api.Core
+	resolver *resolver.Prober
+	options  lego.CertificateConfig
+
+	// The URLs of the authorizations that challenges were solved for,
+	// including those solved for a resumed order on an earlier run.
+	solved map[string]bool
+}
+
+// Obtain obtains a certificate for request, resuming the order at orderURL if
+// it is set and still usable. checkpoint is called with the order once it has
+// been created or resumed, so that it can be saved for a later run if the
+// certificate is not obtained.
+func (o *orderObtainer) Obtain(
+	request obtainRequest,
+	orderURL string,
+	checkpoint func(acme.ExtendedOrder),
+) (*certificate.Resource, error) {
+	var domains []string
+	if request.CSR != nil {
+		domains = certcrypto.ExtractDomainsCSR(request.CSR)
+	} else {
+		domains = request.Domains
+	}
+
+	if len(domains) == 0 {
+		return nil, errors.New("no domains to obtain a certificate for")
+	}
+
+	var order acme.ExtendedOrder
+	if orderURL != "" {
+		order = o.resume(orderURL, domains)
+	}
+
+	if order.Location == "" {
+		log.Infof("[%s] acme: Obtaining bundled SAN certificate", strings.Join(domains, ", "))
+
+		var err error
+		order, err = o.core.Orders.NewWithOptions(domains, &api.OrderOptions{
+			NotBefore:      request.NotBefore,
+			NotAfter:       request.NotAfter,
+			Profile:        request.Profile,
+			ReplacesCertID: request.ReplacesCertID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	checkpoint(order)
+
+	switch order.Status {
+	case acme.StatusPending:
+		authz, err := o.getAuthorizations(order)
+		if err != nil {
+			o.deactivateAuthorizations(order, request.AlwaysDeactivateAuthorizations)
+			return nil, err
 		}
+
+		if o.solved == nil {
+			o.solved = make(map[string]bool)
+		}
+		for i, a := range authz {
+			if a.Status != acme.StatusValid {
+				o.solved[order.Authorizations[i]] = true
+			}
+		}
+
+		if err := o.resolver.Solve(authz); err != nil {
+			o.deactivateAuthorizations(order, request.AlwaysDeactivateAuthorizations)
+			return nil, err
+		}
+
+		log.Infof("[%s] acme: Validations succeeded; requesting certificates", strings.Join(domains, ", "))
+		fallthrough
+
+	case acme.StatusReady:
+		cert, err := o.finalize(domains, order, request)
+		if err != nil {
+			return nil, err
+		}
+
+		if request.AlwaysDeactivateAuthorizations {
+			o.deactivateAuthorizations(order, true)
+		}
+
+		return cert, nil
 	}
 
-	return c.Obtain(request)
+	// The order has already been finalized, so the certificate only needs to
+	// be downloaded once it has been issued. This can only be used if it was
+	// issued for the key in the request, which is not the case if the supplied
+	// key or the CSR has changed since the order was finalized.
+	cert, err := o.download(domains, order, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if !certificateMatchesKey(cert.Certificate, request.publicKey()) {
+		log.Infof("[%s] acme: Order %s was finalized with a different private key; creating a new order", domains[0], order.Location)
+		return o.Obtain(request, "", checkpoint)
+	}
+
+	if request.CSR != nil {
+		cert.CSR = certcrypto.PEMEncode(request.CSR)
+	} else {
+		cert.PrivateKey = certcrypto.PEMEncode(request.PrivateKey)
+	}
+
+	return cert, nil
+}
+
+// resume fetches the order at orderURL, and returns it if it can be resumed.
+// An order that can't be resumed is abandoned, and an empty order is returned
+// so that a new order is created.
+func (o *orderObtainer) resume(orderURL string, domains []string) acme.ExtendedOrder {
+	order, err := o.core.Orders.Get(orderURL)
+	if err != nil {
+		log.Infof("[%s] acme: Unable to fetch order %s to resume, creating a new order: %v", domains[0], orderURL, err)
+		return acme.ExtendedOrder{}
+	}
+
+	if order.Status == acme.StatusInvalid {
+		log.Infof("[%s] acme: Order %s is invalid, creating a new order: %v", domains[0], orderURL, order.Err())
+		return acme.ExtendedOrder{}
+	}
+
+	if !orderIdentifiersEqual(order.Identifiers, domains) {
+		// There is no way to cancel an order in ACME, so the order is
+		// abandoned by deactivating its pending authorizations.
+		log.Infof("[%s] acme: Abandoning order %s as its identifiers have changed", domains[0], orderURL)
+		o.deactivateAuthorizations(order, false)
+		return acme.ExtendedOrder{}
+	}
+
+	log.Infof("[%s] acme: Resuming order %s with status %q", domains[0], orderURL, order.Status)
+	return order
+}
+
+func (o *orderObtainer) getAuthorizations(order acme.ExtendedOrder) ([]acme.Authorization, error) {
+	var authorizations []acme.Authorization
+	for _, authzURL := range order.Authorizations {
+		authz, err := o.core.Authorizations.Get(authzURL)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Infof("[%s] AuthURL: %s", authz.Identifier.Value, authzURL)
+		authorizations = append(authorizations, authz)
+	}
+
+	return authorizations, nil
+}
+
+func (o *orderObtainer) deactivateAuthorizations(order acme.ExtendedOrder, force bool) {
+	for _, authzURL := range order.Authorizations {
+		auth, err := o.core.Authorizations.Get(authzURL)
+		if err != nil {
+			log.Infof("Unable to get the authorization for %s: %v", authzURL, err)
+			continue
+		}
+
+		if auth.Status == acme.StatusValid && !force {
+			log.Infof("Skipping deactivating of valid auth: %s", authzURL)
+			continue
+		}
+
+		log.Infof("Deactivating auth: %s", authzURL)
+
+		if o.core.Authorizations.Deactivate(authzURL) != nil {
+			log.Infof("Unable to deactivate the authorization: %s", authzURL)
+		}
+	}
+}
+
+// finalize finalizes a ready order with a CSR for the request, and downloads
+// the certificate.
+func (o *orderObtainer) finalize(domains []string, order acme.ExtendedOrder, request obtainRequest) (*certificate.Resource, error) {
+	var csr []byte
+	if request.CSR != nil {
+		csr = request.CSR.Raw
+	} else {
+		commonName := ""
+		if len(domains[0]) <= 64 && !o.options.DisableCommonName {
+			commonName = domains[0]
+		}
+
+		var san []string
+		if commonName != "" {
+			san = append(san, commonName)
+		}
+
+		for _, auth := range order.Identifiers {
+			if auth.Value != commonName {
+				san = append(san, auth.Value)
+			}
+		}
+
+		var err error
+		csr, err = certcrypto.CreateCSR(request.PrivateKey, certcrypto.CSROptions{
+			Domain:         commonName,
+			SAN:            san,
+			MustStaple:     request.MustStaple,
+			EmailAddresses: request.EmailAddresses,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	respOrder, err := o.core.Orders.UpdateForCSR(order.Finalize, csr)
+	if err != nil {
+		return nil, err
+	}
+
+	respOrder.Location = order.Location
+	cert, err := o.download(domains, respOrder, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.CSR != nil {
+		cert.CSR = certcrypto.PEMEncode(request.CSR)
+	} else {
+		cert.PrivateKey = certcrypto.PEMEncode(request.PrivateKey)
+	}
+
+	return cert, nil
+}
+
+// download waits for a finalized order to be issued, and downloads the
+// certificate with the chain selected by the request's preferred chain.
+func (o *orderObtainer) download(domains []string, order acme.ExtendedOrder, request obtainRequest) (*certificate.Resource, error) {
+	timeout := o.options.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	err := wait.For("certificate", timeout, timeout/60, func() (bool, error) {
+		if order.Status != acme.StatusValid {
+			var err error
+			if order, err = o.core.Orders.Get(order.Location); err != nil {
+				return false, err
+			}
+		}
+
+		switch order.Status {
+		case acme.StatusValid:
+			return true, nil
+		case acme.StatusInvalid:
+			return true, fmt.Errorf("invalid order: %w", order.Err())
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := o.core.Certificates.GetAll(order.Certificate, request.Bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	chains, err := certificateChainsFromRaw(raw, order.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, _ := selectCertificateChain(chains, order.Certificate, request.PreferredChain)
+	log.Infof("[%s] Server responded with a certificate.", domains[0])
+
+	return &certificate.Resource{
+		Domain:            domains[0],
+		CertURL:           chain.URL,
+		CertStableURL:     chain.URL,
+		Certificate:       raw[chain.URL].Cert,
+		IssuerCertificate: raw[chain.URL].Issuer,
+	}, nil
 }
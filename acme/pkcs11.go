@@ -0,0 +1,245 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// pkcs11URIScheme is the scheme prefix for PKCS#11 URIs, as per RFC 7512.
+const pkcs11URIScheme = "pkcs11:"
+
+// errPKCS11Unsupported is returned when a PKCS#11 key is used with a build of
+// the provider without cgo.
+var errPKCS11Unsupported = errors.New("PKCS#11 keys require a build of the provider with cgo, and the official release builds only support them on linux/amd64 and linux/arm64; " +
+	"on other platforms, build the provider from source with CGO_ENABLED=1 to use key_pkcs11_uri or account_key_pkcs11_uri")
+
+// pkcs11Signers holds the signers opened by pkcs11SignerForURI, keyed by URI.
+var (
+	pkcs11SignersMutex sync.Mutex
+	pkcs11Signers      = make(map[string]*pkcs11Signer)
+)
+
+// pkcs11SignerForURI returns a signer for the key referenced by a PKCS#11
+// URI. The signers are kept open for the life of the provider process, and
+// shared between resources, so that the module is only loaded and the token
+// only logged into once, and a signer is never closed while another resource
+// is using the same module.
+func pkcs11SignerForURI(s string) (*pkcs11Signer, error) {
+	pkcs11SignersMutex.Lock()
+	defer pkcs11SignersMutex.Unlock()
+
+	if signer, ok := pkcs11Signers[s]; ok {
+		return signer, nil
+	}
+
+	uri, err := parsePKCS11URI(s)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := openPKCS11Signer(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	pkcs11Signers[s] = signer
+	return signer, nil
+}
+
+// pkcs11URI represents the parts of a PKCS#11 URI (RFC 7512) that are used to
+// locate a private key in a token.
+type pkcs11URI struct {
+	// The path to the PKCS#11 module (the module-path query attribute).
+	ModulePath string
+
+	// The label of the token (the token path attribute).
+	Token string
+
+	// The label of the key (the object path attribute).
+	Object string
+
+	// The ID of the key (the id path attribute).
+	ID []byte
+
+	// The PIN to log into the token with (the pin-value query attribute).
+	PIN string
+
+	// The path to a file containing the PIN (the pin-source query attribute).
+	PINSource string
+}
+
+// parsePKCS11URI parses a PKCS#11 URI. The URI must include a module-path
+// query attribute, and at least one of the object or id path attributes.
+func parsePKCS11URI(s string) (*pkcs11URI, error) {
+	rest, ok := strings.CutPrefix(s, pkcs11URIScheme)
+	if !ok {
+		return nil, fmt.Errorf("PKCS#11 URI must start with %q", pkcs11URIScheme)
+	}
+
+	path, query, _ := strings.Cut(rest, "?")
+	result := &pkcs11URI{}
+	for attr := range strings.SplitSeq(path, ";") {
+		if attr == "" {
+			continue
+		}
+
+		k, v, err := parsePKCS11URIAttribute(attr)
+		if err != nil {
+			return nil, err
+		}
+
+		switch k {
+		case "token":
+			result.Token = v
+		case "object":
+			result.Object = v
+		case "id":
+			result.ID = []byte(v)
+		case "type":
+			if v != "private" {
+				return nil, fmt.Errorf("PKCS#11 URI object type must be \"private\", got %q", v)
+			}
+		}
+	}
+
+	for attr := range strings.SplitSeq(query, "&") {
+		if attr == "" {
+			continue
+		}
+
+		k, v, err := parsePKCS11URIAttribute(attr)
+		if err != nil {
+			return nil, err
+		}
+
+		switch k {
+		case "module-path":
+			result.ModulePath = v
+		case "pin-value":
+			result.PIN = v
+		case "pin-source":
+			result.PINSource = v
+		}
+	}
+
+	if result.ModulePath == "" {
+		return nil, errors.New("PKCS#11 URI is missing the module-path attribute")
+	}
+
+	if result.Object == "" && len(result.ID) == 0 {
+		return nil, errors.New("PKCS#11 URI must contain an object or id attribute")
+	}
+
+	if result.PIN != "" && result.PINSource != "" {
+		return nil, errors.New("PKCS#11 URI cannot contain both pin-value and pin-source")
+	}
+
+	return result, nil
+}
+
+// pin returns the PIN for the token, reading it from pin-source if necessary.
+func (u *pkcs11URI) pin() (string, error) {
+	if u.PINSource == "" {
+		return u.PIN, nil
+	}
+
+	// pin-source is usually a file: URI, but a bare path is also accepted.
+	b, err := os.ReadFile(strings.TrimPrefix(u.PINSource, "file:"))
+	if err != nil {
+		return "", fmt.Errorf("error reading PKCS#11 pin-source: %w", err)
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+func parsePKCS11URIAttribute(attr string) (string, string, error) {
+	k, v, ok := strings.Cut(attr, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid PKCS#11 URI attribute %q", attr)
+	}
+
+	v, err := url.PathUnescape(v)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid PKCS#11 URI attribute %q: %w", attr, err)
+	}
+
+	return k, v, nil
+}
+
+// pkcs11RSADigestInfoPrefixes are the DER-encoded DigestInfo prefixes for
+// RSA PKCS #1 v1.5 signatures, which need to be prepended to the digest when
+// signing using the raw CKM_RSA_PKCS mechanism.
+var pkcs11RSADigestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// pkcs11RSADigestInfo returns the DigestInfo structure for the supplied
+// digest, to be signed with CKM_RSA_PKCS.
+func pkcs11RSADigestInfo(hash crypto.Hash, digest []byte) ([]byte, error) {
+	prefix, ok := pkcs11RSADigestInfoPrefixes[hash]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash function for PKCS#11 RSA signature: %s", hash)
+	}
+
+	if len(digest) != hash.Size() {
+		return nil, fmt.Errorf("digest length %d does not match hash function %s", len(digest), hash)
+	}
+
+	return append(append([]byte{}, prefix...), digest...), nil
+}
+
+// pkcs11ECDSASignatureToASN1 converts a raw ECDSA signature as returned by
+// CKM_ECDSA (r || s) into the ASN.1 format expected by crypto.Signer.
+func pkcs11ECDSASignatureToASN1(sig []byte) ([]byte, error) {
+	if len(sig) == 0 || len(sig)%2 != 0 {
+		return nil, fmt.Errorf("invalid ECDSA signature length %d", len(sig))
+	}
+
+	n := len(sig) / 2
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{
+		R: new(big.Int).SetBytes(sig[:n]),
+		S: new(big.Int).SetBytes(sig[n:]),
+	})
+}
+
+var pkcs11ECCurveOIDs = map[string]elliptic.Curve{
+	"1.2.840.10045.3.1.7": elliptic.P256(),
+	"1.3.132.0.34":        elliptic.P384(),
+	"1.3.132.0.35":        elliptic.P521(),
+}
+
+// pkcs11ECPublicKey builds an ECDSA public key from the CKA_EC_PARAMS and
+// CKA_EC_POINT attributes of a PKCS#11 public key object.
+func pkcs11ECPublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, fmt.Errorf("error parsing EC parameters: %w", err)
+	}
+
+	curve, ok := pkcs11ECCurveOIDs[oid.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported EC curve %s", oid)
+	}
+
+	// CKA_EC_POINT is a DER-encoded OCTET STRING, although some modules return
+	// the raw point. Try both.
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) > 0 {
+		raw = point
+	}
+
+	return ecdsa.ParseUncompressedPublicKey(curve, raw)
+}
@@ -0,0 +1,288 @@
+//go:build cgo
+
+package acme
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// pkcs11Supported is true in builds with cgo, which can load PKCS#11 modules.
+const pkcs11Supported = true
+
+// pkcs11Signer is a crypto.Signer backed by a private key in a PKCS#11 token.
+type pkcs11Signer struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+	keyType uint
+	pub     crypto.PublicKey
+
+	// True if the module was initialized, or the token logged into, by this
+	// signer, rather than by another user of the module in the process.
+	initialized bool
+	loggedIn    bool
+
+	// Serializes signatures, which can't be made concurrently in a session.
+	mu sync.Mutex
+}
+
+var _ crypto.Signer = (*pkcs11Signer)(nil)
+
+// openPKCS11Signer loads the PKCS#11 module referenced by the URI, logs into
+// the token, and returns a signer for the key referenced by the URI. Close must
+// be called on the signer when it is no longer needed.
+func openPKCS11Signer(uri *pkcs11URI) (*pkcs11Signer, error) {
+	ctx := pkcs11.New(uri.ModulePath)
+	if ctx == nil {
+		return nil, fmt.Errorf("error loading PKCS#11 module %q", uri.ModulePath)
+	}
+
+	s := &pkcs11Signer{ctx: ctx}
+	if err := ctx.Initialize(); err == nil {
+		s.initialized = true
+	} else if !pkcs11IsError(err, pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		ctx.Destroy()
+		return nil, fmt.Errorf("error initializing PKCS#11 module: %w", err)
+	}
+
+	if err := s.open(uri); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *pkcs11Signer) open(uri *pkcs11URI) error {
+	slot, err := s.findSlot(uri.Token)
+	if err != nil {
+		return err
+	}
+
+	s.session, err = s.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf("error opening PKCS#11 session: %w", err)
+	}
+
+	pin, err := uri.pin()
+	if err != nil {
+		return err
+	}
+
+	if pin != "" {
+		if err := s.ctx.Login(s.session, pkcs11.CKU_USER, pin); err == nil {
+			s.loggedIn = true
+		} else if !pkcs11IsError(err, pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+			return fmt.Errorf("error logging into PKCS#11 token: %w", err)
+		}
+	}
+
+	template := []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY)}
+	if uri.Object != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, uri.Object))
+	}
+	if len(uri.ID) > 0 {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, uri.ID))
+	}
+
+	s.key, err = s.findObject(template)
+	if err != nil {
+		return fmt.Errorf("error finding private key: %w", err)
+	}
+
+	attrs, err := s.ctx.GetAttributeValue(s.session, s.key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+	})
+	if err != nil {
+		return fmt.Errorf("error reading private key attributes: %w", err)
+	}
+
+	s.keyType = pkcs11AttributeUint(attrs[0].Value)
+	pubTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, attrs[1].Value),
+	}
+	if uri.Object != "" {
+		pubTemplate = append(pubTemplate, pkcs11.NewAttribute(pkcs11.CKA_LABEL, uri.Object))
+	}
+
+	pubKey, err := s.findObject(pubTemplate)
+	if err != nil {
+		return fmt.Errorf("error finding public key: %w", err)
+	}
+
+	switch s.keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err := s.ctx.GetAttributeValue(s.session, pubKey, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return fmt.Errorf("error reading RSA public key: %w", err)
+		}
+
+		s.pub = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}
+
+	case pkcs11.CKK_EC:
+		attrs, err := s.ctx.GetAttributeValue(s.session, pubKey, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return fmt.Errorf("error reading EC public key: %w", err)
+		}
+
+		s.pub, err = pkcs11ECPublicKey(attrs[0].Value, attrs[1].Value)
+		if err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unsupported PKCS#11 key type %d", s.keyType)
+	}
+
+	return nil
+}
+
+func (s *pkcs11Signer) findSlot(token string) (uint, error) {
+	slots, err := s.ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("error listing PKCS#11 slots: %w", err)
+	}
+
+	for _, slot := range slots {
+		if token == "" {
+			return slot, nil
+		}
+
+		info, err := s.ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, fmt.Errorf("error reading PKCS#11 token info: %w", err)
+		}
+
+		if strings.TrimRight(info.Label, " \x00") == token {
+			return slot, nil
+		}
+	}
+
+	return 0, fmt.Errorf("PKCS#11 token %q not found", token)
+}
+
+func (s *pkcs11Signer) findObject(template []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	if err := s.ctx.FindObjectsInit(s.session, template); err != nil {
+		return 0, err
+	}
+
+	objs, _, err := s.ctx.FindObjects(s.session, 2)
+	if finalErr := s.ctx.FindObjectsFinal(s.session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	switch len(objs) {
+	case 0:
+		return 0, errors.New("no matching object found")
+	case 1:
+		return objs[0], nil
+	}
+
+	return 0, errors.New("more than one matching object found")
+}
+
+// Public returns the public key of the signer.
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.pub
+}
+
+// Sign signs the digest using the key in the token.
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	switch s.keyType {
+	case pkcs11.CKK_RSA:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, errors.New("RSA-PSS signatures are not supported for PKCS#11 keys")
+		}
+
+		data, err := pkcs11RSADigestInfo(opts.HashFunc(), digest)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.sign(pkcs11.CKM_RSA_PKCS, data)
+
+	case pkcs11.CKK_EC:
+		sig, err := s.sign(pkcs11.CKM_ECDSA, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		return pkcs11ECDSASignatureToASN1(sig)
+	}
+
+	return nil, fmt.Errorf("unsupported PKCS#11 key type %d", s.keyType)
+}
+
+func (s *pkcs11Signer) sign(mechanism uint, data []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, s.key); err != nil {
+		return nil, fmt.Errorf("error initializing PKCS#11 signature: %w", err)
+	}
+
+	sig, err := s.ctx.Sign(s.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("error signing with PKCS#11 key: %w", err)
+	}
+
+	return sig, nil
+}
+
+// Close logs out of the token and unloads the PKCS#11 module. The token is
+// only logged out of, and the module finalized, if that was done by the
+// signer, so that neither is torn down while still in use elsewhere.
+func (s *pkcs11Signer) Close() error {
+	if s.session != 0 {
+		if s.loggedIn {
+			s.ctx.Logout(s.session)
+		}
+
+		s.ctx.CloseSession(s.session)
+	}
+
+	if s.initialized {
+		s.ctx.Finalize()
+	}
+	s.ctx.Destroy()
+	return nil
+}
+
+func pkcs11IsError(err error, code uint) bool {
+	var e pkcs11.Error
+	return errors.As(err, &e) && uint(e) == code
+}
+
+// pkcs11AttributeUint decodes a CK_ULONG attribute value, which is returned
+// in native byte order (little-endian on all supported platforms).
+func pkcs11AttributeUint(b []byte) uint {
+	var v uint
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint(b[i])
+	}
+
+	return v
+}
@@ -0,0 +1,32 @@
+//go:build !cgo
+
+package acme
+
+import (
+	"crypto"
+	"io"
+)
+
+// pkcs11Supported is false in builds without cgo, which is required to load
+// PKCS#11 modules. The official release builds are built without cgo, other
+// than for linux/amd64 and linux/arm64.
+const pkcs11Supported = false
+
+// pkcs11Signer is a stub for builds without cgo, which is required to load
+// PKCS#11 modules.
+type pkcs11Signer struct{}
+
+var _ crypto.Signer = (*pkcs11Signer)(nil)
+
+// openPKCS11Signer always returns an error in builds without cgo.
+func openPKCS11Signer(_ *pkcs11URI) (*pkcs11Signer, error) {
+	return nil, errPKCS11Unsupported
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey { return nil }
+
+func (s *pkcs11Signer) Sign(_ io.Reader, _ []byte, _ crypto.SignerOpts) ([]byte, error) {
+	return nil, errPKCS11Unsupported
+}
+
+func (s *pkcs11Signer) Close() error { return nil }
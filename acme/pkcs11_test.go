@@ -0,0 +1,233 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParsePKCS11URI(t *testing.T) {
+	pinFile := filepath.Join(t.TempDir(), "pin")
+	testWriteFile(t, pinFile, []byte("5678\n"))
+
+	testCases := []struct {
+		name        string
+		uri         string
+		expected    *pkcs11URI
+		expectedPIN string
+		expectedErr bool
+	}{
+		{
+			name: "basic",
+			uri:  "pkcs11:token=acme;object=cert%20key;type=private?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-value=1234",
+			expected: &pkcs11URI{
+				ModulePath: "/usr/lib/softhsm/libsofthsm2.so",
+				Token:      "acme",
+				Object:     "cert key",
+				PIN:        "1234",
+			},
+			expectedPIN: "1234",
+		},
+		{
+			name: "id and pin-source",
+			uri:  "pkcs11:id=%01%02?module-path=/lib/p11.so&pin-source=file:" + pinFile,
+			expected: &pkcs11URI{
+				ModulePath: "/lib/p11.so",
+				ID:         []byte{0x01, 0x02},
+				PINSource:  "file:" + pinFile,
+			},
+			expectedPIN: "5678",
+		},
+		{
+			name:        "wrong scheme",
+			uri:         "file:token=acme;object=key?module-path=/lib/p11.so",
+			expectedErr: true,
+		},
+		{
+			name:        "missing module path",
+			uri:         "pkcs11:token=acme;object=key",
+			expectedErr: true,
+		},
+		{
+			name:        "missing object",
+			uri:         "pkcs11:token=acme?module-path=/lib/p11.so",
+			expectedErr: true,
+		},
+		{
+			name:        "public key type",
+			uri:         "pkcs11:object=key;type=public?module-path=/lib/p11.so",
+			expectedErr: true,
+		},
+		{
+			name:        "both pin-value and pin-source",
+			uri:         "pkcs11:object=key?module-path=/lib/p11.so&pin-value=1&pin-source=/pin",
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parsePKCS11URI(tc.uri)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, actual)
+			}
+
+			pin, err := actual.pin()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if pin != tc.expectedPIN {
+				t.Fatalf("expected PIN to be %q, got %q", tc.expectedPIN, pin)
+			}
+		})
+	}
+}
+
+func TestPKCS11RSADigestInfo(t *testing.T) {
+	digest := sha256.Sum256([]byte("test"))
+	actual, err := pkcs11RSADigestInfo(crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var info struct {
+		Algorithm struct {
+			Algorithm  asn1.ObjectIdentifier
+			Parameters asn1.RawValue
+		}
+		Digest []byte
+	}
+	if _, err := asn1.Unmarshal(actual, &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.Algorithm.Algorithm.Equal(asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}) {
+		t.Fatalf("unexpected digest algorithm: %s", info.Algorithm.Algorithm)
+	}
+
+	if !reflect.DeepEqual(info.Digest, digest[:]) {
+		t.Fatal("digest mismatch")
+	}
+
+	if _, err := pkcs11RSADigestInfo(crypto.SHA256, digest[:20]); err == nil {
+		t.Fatal("expected error for short digest")
+	}
+}
+
+func TestPKCS11ECDSASignatureToASN1(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte("test"))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Convert to raw r || s as a token would return it.
+	var rs struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := append(rs.R.FillBytes(make([]byte, 32)), rs.S.FillBytes(make([]byte, 32))...)
+	actual, err := pkcs11ECDSASignatureToASN1(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ecdsa.VerifyASN1(&key.PublicKey, digest[:], actual) {
+		t.Fatal("converted signature does not verify")
+	}
+}
+
+func TestPKCS11ECPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 34})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	point, err := asn1.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range [][]byte{point, raw} {
+		actual, err := pkcs11ECPublicKey(params, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !actual.Equal(&key.PublicKey) {
+			t.Fatal("public key mismatch")
+		}
+	}
+}
+
+// TestPKCS11Signer tests the PKCS#11 signer against a real token, such as one
+// created with SoftHSMv2. ACME_PKCS11_TEST_URI must be set to the URI of an
+// existing key.
+func TestPKCS11Signer(t *testing.T) {
+	if os.Getenv("ACME_PKCS11_TEST_URI") == "" {
+		t.Skip("ACME_PKCS11_TEST_URI must be set for the PKCS#11 signer test")
+	}
+
+	uri, err := parsePKCS11URI(os.Getenv("ACME_PKCS11_TEST_URI"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := openPKCS11Signer(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer signer.Close()
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames: []string{"www.example.com"},
+	}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		t.Fatalf("CSR signature does not verify: %s", err)
+	}
+}
@@ -0,0 +1,222 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// The PEM preamble for encrypted PKCS#8 private keys.
+const preambleEncryptedPKCS8PrivateKey = "ENCRYPTED PRIVATE KEY"
+
+// Parameters for PBES2 encryption of private keys. The iteration count follows
+// current OWASP guidance for PBKDF2-HMAC-SHA256.
+const (
+	pkcs8PBKDF2Iterations = 600000
+	pkcs8PBKDF2SaltLength = 16
+	pkcs8AES256KeyLength  = 32
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// encryptedPrivateKeyInfo is the EncryptedPrivateKeyInfo structure from RFC
+// 5208.
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params is the PBES2-params structure from RFC 8018.
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params is the PBKDF2-params structure from RFC 8018. The optional
+// keyLength field is omitted.
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	PRF            pkix.AlgorithmIdentifier
+}
+
+// pbkdf2ParamsWithKeyLength is PBKDF2-params with the optional keyLength
+// field, used for parsing.
+type pbkdf2ParamsWithKeyLength struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// encryptPrivateKeyPEM takes a PEM-encoded private key and returns it as a
+// PEM-encoded PKCS#8 encrypted private key, using PBES2 with
+// PBKDF2-HMAC-SHA256 and AES-256-CBC.
+func encryptPrivateKeyPEM(keyPEM []byte, passphrase string) ([]byte, error) {
+	key, err := privateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling private key: %w", err)
+	}
+
+	salt := make([]byte, pkcs8PBKDF2SaltLength)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	dk, err := pbkdf2.Key(sha256.New, passphrase, salt, pkcs8PBKDF2Iterations, pkcs8AES256KeyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(dk)
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS#7 padding
+	padLen := aes.BlockSize - len(der)%aes.BlockSize
+	plaintext := make([]byte, len(der)+padLen)
+	copy(plaintext, der)
+	for i := len(der); i < len(plaintext); i++ {
+		plaintext[i] = byte(padLen)
+	}
+
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pkcs8PBKDF2Iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: ciphertext,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: preambleEncryptedPKCS8PrivateKey, Bytes: result}), nil
+}
+
+// decryptPrivateKeyPEM decrypts a PEM-encoded PKCS#8 encrypted private key as
+// created by encryptPrivateKeyPEM.
+func decryptPrivateKeyPEM(encryptedPEM []byte, passphrase string) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(encryptedPEM)
+	if block == nil || block.Type != preambleEncryptedPKCS8PrivateKey {
+		return nil, errors.New("cannot decode encrypted private key PEM data")
+	}
+
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(block.Bytes, &info); err != nil {
+		return nil, fmt.Errorf("error parsing encrypted private key: %w", err)
+	}
+
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported encryption algorithm %s", info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("error parsing PBES2 parameters: %w", err)
+	}
+
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %s", params.KeyDerivationFunc.Algorithm)
+	}
+
+	if !params.EncryptionScheme.Algorithm.Equal(oidAES256CBC) {
+		return nil, fmt.Errorf("unsupported encryption scheme %s", params.EncryptionScheme.Algorithm)
+	}
+
+	var kdfParams pbkdf2ParamsWithKeyLength
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, fmt.Errorf("error parsing PBKDF2 parameters: %w", err)
+	}
+
+	if !kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA256) {
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF %s", kdfParams.PRF.Algorithm)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("error parsing encryption scheme parameters: %w", err)
+	}
+
+	if len(iv) != aes.BlockSize || len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted private key data")
+	}
+
+	dk, err := pbkdf2.Key(sha256.New, passphrase, kdfParams.Salt, kdfParams.IterationCount, pkcs8AES256KeyLength)
+	if err != nil {
+		return nil, err
+	}
+
+	cb, err := aes.NewCipher(dk)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(cb, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	// Remove PKCS#7 padding. An invalid padding value almost always means an
+	// incorrect passphrase.
+	padLen := int(plaintext[len(plaintext)-1])
+	if padLen == 0 || padLen > aes.BlockSize {
+		return nil, errors.New("error decrypting private key: incorrect passphrase")
+	}
+	for _, b := range plaintext[len(plaintext)-padLen:] {
+		if int(b) != padLen {
+			return nil, errors.New("error decrypting private key: incorrect passphrase")
+		}
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(plaintext[:len(plaintext)-padLen])
+	if err != nil {
+		return nil, errors.New("error decrypting private key: incorrect passphrase")
+	}
+
+	return key, nil
+}
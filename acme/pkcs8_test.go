@@ -0,0 +1,97 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+func TestEncryptPrivateKeyPEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		key  interface{ Equal(crypto.PrivateKey) bool }
+	}{
+		{
+			name: "RSA",
+			key:  rsaKey,
+		},
+		{
+			name: "ECDSA",
+			key:  ecKey,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encrypted, err := encryptPrivateKeyPEM(certcrypto.PEMEncode(tc.key), "passphrase")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := decryptPrivateKeyPEM(encrypted, "passphrase")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tc.key.Equal(actual) {
+				t.Fatal("decrypted key does not match original key")
+			}
+
+			if _, err := decryptPrivateKeyPEM(encrypted, "wrong"); err == nil {
+				t.Fatal("expected error decrypting with the wrong passphrase")
+			}
+		})
+	}
+}
+
+// TestEncryptPrivateKeyPEMOpenSSL checks that keys encrypted by
+// encryptPrivateKeyPEM can be read by OpenSSL, if it is installed.
+func TestEncryptPrivateKeyPEMOpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found in PATH")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := encryptPrivateKeyPEM(certcrypto.PEMEncode(key), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	testWriteFile(t, path, encrypted)
+
+	out, err := exec.Command(openssl, "pkcs8", "-in", path, "-passin", "pass:passphrase").CombinedOutput()
+	if err != nil {
+		t.Fatalf("openssl failed to decrypt key: %s\n%s", err, out)
+	}
+
+	actual, err := privateKeyFromPEM(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !key.Equal(actual) {
+		t.Fatal("key decrypted by openssl does not match original key")
+	}
+}
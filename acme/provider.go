@@ -17,12 +17,15 @@ func Provider() *schema.Provider {
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"acme_registration": resourceACMERegistration(),
-			"acme_certificate":  resourceACMECertificate(),
+			"acme_registration":    resourceACMERegistration(),
+			"acme_certificate":     resourceACMECertificate(),
+			"acme_certificate_set": resourceACMECertificateSet(),
 		},
 
 		DataSourcesMap: map[string]*schema.Resource{
-			"acme_server_url": dataSourceACMEServerURL(),
+			"acme_server_url":     dataSourceACMEServerURL(),
+			"acme_client_storage": dataSourceACMEClientStorage(),
+			"acme_registration":   dataSourceACMERegistration(),
 		},
 
 		ConfigureFunc: configureProvider,
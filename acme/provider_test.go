@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"go/build"
 	"io"
@@ -62,6 +63,12 @@ const mainIntermediateURL = "https://localhost:15000/intermediates/0"
 // URL to the alternate certificate for preferred chain tests
 const alternateIntermediateURL = "https://localhost:15000/intermediates/1"
 
+// URL to the root of the main certificate chain
+const mainRootURL = "https://localhost:15000/roots/0"
+
+// URL to the root of the alternate certificate chain
+const alternateRootURL = "https://localhost:15000/roots/1"
+
 // URL to the main certificate for static-profile tests
 const profileIntermediateURL = "https://localhost:15002/intermediates/0"
 
@@ -121,6 +128,12 @@ func getPebbleCertificate(url string) *x509.Certificate {
 	return certs[0]
 }
 
+// getPebbleCertificatePEM returns the certificate at the supplied URL in PEM
+// format.
+func getPebbleCertificatePEM(url string) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: getPebbleCertificate(url).Raw}))
+}
+
 // getPebbleCertificateIssuer returns the issuer CN of the
 // certificate at the supplied URL.
 func getPebbleCertificateIssuer(url string) string {
@@ -2,15 +2,20 @@ package acme
 
 import (
 	"context"
+	"crypto"
 	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"log"
 	"math/rand"
+	"net"
+	"strings"
 	"time"
 
 	"github.com/go-acme/lego/v4/acme"
 	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
 	"github.com/go-acme/lego/v4/challenge/dns01"
 	"github.com/go-acme/lego/v4/lego"
@@ -34,6 +39,14 @@ const (
 	RevocationReasonAACompromise         RevocationReason = "aa-compromise"
 )
 
+// resourceACMECertificateRenewalTriggerKeys are the arguments that renew the
+// certificate in place when changed.
+var resourceACMECertificateRenewalTriggerKeys = []string{
+	"validity_days",
+	"validity",
+	"renewal_triggers",
+}
+
 // resourceACMECertificate returns the current version of the
 // acme_registration resource and needs to be updated when the schema
 // version is incremented.
@@ -41,37 +54,61 @@ func resourceACMECertificate() *schema.Resource { return resourceACMECertificate
 
 func resourceACMECertificateV5() *schema.Resource {
 	return &schema.Resource{
-		Create:        resourceACMECertificateCreate,
-		Read:          resourceACMECertificateRead,
-		CustomizeDiff: resourceACMECertificateCustomizeDiff,
-		Update:        resourceACMECertificateUpdate,
-		Delete:        resourceACMECertificateDelete,
-		MigrateState:  resourceACMECertificateMigrateState,
-		SchemaVersion: 5,
+		CreateWithoutTimeout: resourceACMECertificateDiagnostics(resourceACMECertificateCreate),
+		ReadWithoutTimeout:   resourceACMECertificateDiagnostics(resourceACMECertificateRefresh),
+		CustomizeDiff:        resourceACMECertificateCustomizeDiff,
+		UpdateWithoutTimeout: resourceACMECertificateDiagnostics(resourceACMECertificateUpdate),
+		Delete:               resourceACMECertificateDelete,
+		MigrateState:         resourceACMECertificateMigrateState,
+		SchemaVersion:        5,
 		StateUpgraders: []schema.StateUpgrader{
 			resourceACMECertificateStateUpgraderV4(),
 		},
 		Schema: map[string]*schema.Schema{
 			"account_key_pem": {
-				Type:      schema.TypeString,
-				Required:  true,
-				ForceNew:  true,
-				Sensitive: true,
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Sensitive:    true,
+				ExactlyOneOf: []string{"account_key_pem", "account_key_pkcs11_uri"},
+			},
+			"account_key_pkcs11_uri": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Sensitive:    true,
+				ExactlyOneOf: []string{"account_key_pem", "account_key_pkcs11_uri"},
+				ValidateFunc: validatePKCS11URI,
 			},
 			"common_name": {
-				Type:          schema.TypeString,
-				Optional:      true,
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				ValidateFunc:     validateDomain,
+				DiffSuppressFunc: suppressEquivalentDomainDiff,
+				AtLeastOneOf:     []string{"common_name", "subject_alternative_names", "ip_addresses", "certificate_request_pem"},
+				ConflictsWith:    []string{"certificate_request_pem"},
+			},
+			"subject_alternative_names": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:             schema.TypeString,
+					ValidateFunc:     validateDomain,
+					DiffSuppressFunc: suppressEquivalentDomainDiff,
+				},
+				Set:           hashDomain,
 				ForceNew:      true,
-				AtLeastOneOf:  []string{"common_name", "subject_alternative_names", "certificate_request_pem"},
+				AtLeastOneOf:  []string{"common_name", "subject_alternative_names", "ip_addresses", "certificate_request_pem"},
 				ConflictsWith: []string{"certificate_request_pem"},
 			},
-			"subject_alternative_names": {
+			"ip_addresses": {
 				Type:          schema.TypeSet,
 				Optional:      true,
-				Elem:          &schema.Schema{Type: schema.TypeString},
+				Elem:          &schema.Schema{Type: schema.TypeString, ValidateFunc: validation.IsIPAddress},
 				Set:           schema.HashString,
 				ForceNew:      true,
-				AtLeastOneOf:  []string{"common_name", "subject_alternative_names", "certificate_request_pem"},
+				AtLeastOneOf:  []string{"common_name", "subject_alternative_names", "ip_addresses", "certificate_request_pem"},
 				ConflictsWith: []string{"certificate_request_pem"},
 			},
 			"key_type": {
@@ -79,32 +116,168 @@ func resourceACMECertificateV5() *schema.Resource {
 				Optional:      true,
 				ForceNew:      true,
 				Default:       "2048",
-				ConflictsWith: []string{"certificate_request_pem"},
+				ConflictsWith: []string{"certificate_request_pem", "key_types"},
 				ValidateFunc:  validateKeyType,
 			},
+			"key_types": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MinItems: 1,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateKeyType,
+				},
+				ConflictsWith: []string{
+					"key_type",
+					"certificate_request_pem",
+					"key_pkcs11_uri",
+					"private_key_pem",
+					"private_key_pem_wo",
+					"private_key_passphrase",
+					"omit_private_key_pem",
+				},
+			},
+			"key_rotation": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  keyRotationReuse,
+				ValidateFunc: validation.StringInSlice(
+					[]string{keyRotationReuse, keyRotationAlways, keyRotationMaxAgeDays},
+					false,
+				),
+			},
+			"key_rotation_max_age_days": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"private_key_created_at": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"private_key_supplied": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
 			"certificate_request_pem": {
 				Type:          schema.TypeString,
 				Optional:      true,
 				ForceNew:      true,
-				AtLeastOneOf:  []string{"common_name", "subject_alternative_names", "certificate_request_pem"},
-				ConflictsWith: []string{"common_name", "subject_alternative_names", "key_type"},
+				AtLeastOneOf:  []string{"common_name", "subject_alternative_names", "ip_addresses", "certificate_request_pem"},
+				ConflictsWith: []string{"common_name", "subject_alternative_names", "ip_addresses", "key_type", "key_types", "key_pkcs11_uri"},
+			},
+			"key_pkcs11_uri": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				Sensitive:     true,
+				ConflictsWith: []string{"certificate_request_pem", "key_type", "key_types"},
+				ValidateFunc:  validatePKCS11URI,
 			},
 			"validity_days": {
-				Type:         schema.TypeInt,
-				Optional:     true,
-				ValidateFunc: validation.IntAtLeast(1),
+				Type:          schema.TypeInt,
+				Optional:      true,
+				ValidateFunc:  validation.IntAtLeast(1),
+				ConflictsWith: []string{"validity"},
+			},
+			"validity": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ValidateFunc:  validateCertificateLifetime,
+				ConflictsWith: []string{"validity_days"},
 			},
 			"min_days_remaining": {
 				Type:          schema.TypeInt,
 				Optional:      true,
 				Default:       30,
-				ConflictsWith: []string{"min_days_dynamic"},
+				ConflictsWith: []string{"min_days_dynamic", "renew_before"},
 			},
 			"min_days_dynamic": {
 				Type:          schema.TypeBool,
 				Optional:      true,
 				Default:       false,
-				ConflictsWith: []string{"min_days_remaining"},
+				ConflictsWith: []string{"min_days_remaining", "renew_before"},
+			},
+			"renew_before": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ValidateFunc:  validateCertificateDuration,
+				ConflictsWith: []string{"min_days_remaining", "min_days_dynamic"},
+			},
+			"renewal_triggers": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"renewal_window": renewalWindowSchema(),
+			"deploy_hook":    deployHookSchema(),
+			"renewal_window_status": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"order_url": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"authorizations": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"identifier": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"url": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"status": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"challenge_type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"validated_at": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"reused": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+					},
+				},
+			},
+			"pending_order_url": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"pending_order_authorizations": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"pending_order_private_key_pem": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"pending_order_solved_authorizations": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"next_renewal_at": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"renewal_reason": {
+				Type:     schema.TypeString,
+				Computed: true,
 			},
 			"use_renewal_info": {
 				Type:     schema.TypeBool,
@@ -310,7 +483,50 @@ func resourceACMECertificateV5() *schema.Resource {
 				Type:     schema.TypeString,
 				Optional: true,
 				Default:  "",
-				ForceNew: true,
+			},
+			"refresh_chain": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"available_chains": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"url": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"issuer_pem": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"root_common_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+			"trusted_roots_pem": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "",
+			},
+			"verify_chain": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  verifyChainOff,
+				ValidateFunc: validation.StringInSlice([]string{
+					verifyChainOff,
+					verifyChainWarn,
+					verifyChainFail,
+				}, false),
+			},
+			"chain_verification_error": {
+				Type:     schema.TypeString,
+				Computed: true,
 			},
 			"profile": {
 				Type:     schema.TypeString,
@@ -332,14 +548,66 @@ func resourceACMECertificateV5() *schema.Resource {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"domains": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"certificate_ip_addresses": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 			"certificate_domain": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
 			"private_key_pem": {
-				Type:      schema.TypeString,
-				Computed:  true,
-				Sensitive: true,
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				Sensitive:     true,
+				ConflictsWith: []string{"certificate_request_pem", "key_type", "key_types", "key_pkcs11_uri", "private_key_pem_wo"},
+				ValidateFunc:  validateCertificatePrivateKey,
+			},
+			"private_key_pem_wo": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				WriteOnly:     true,
+				ConflictsWith: []string{"certificate_request_pem", "key_type", "key_types", "key_pkcs11_uri", "private_key_pem"},
+				RequiredWith:  []string{"private_key_pem_wo_version"},
+				ValidateFunc:  validateCertificatePrivateKey,
+			},
+			"private_key_pem_wo_version": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ForceNew:     true,
+				RequiredWith: []string{"private_key_pem_wo"},
+			},
+			"private_key_passphrase": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				WriteOnly:     true,
+				RequiredWith:  []string{"private_key_passphrase_version"},
+				ConflictsWith: []string{"key_types"},
+			},
+			"private_key_passphrase_version": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				RequiredWith: []string{"private_key_passphrase"},
+			},
+			"private_key_pem_encrypted": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"omit_private_key_pem": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"private_key_pem", "key_types"},
+				RequiredWith:  []string{"private_key_passphrase_version"},
 			},
 			"certificate_pem": {
 				Type:     schema.TypeString,
@@ -349,11 +617,65 @@ func resourceACMECertificateV5() *schema.Resource {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"certificate_pem_fullchain": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"certificate_der": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"issuer_der": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"certificate_p7b": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"certificate_info": certificateInfoSchema(),
+			"certificate_scts": certificateSCTsSchema(),
+			"require_scts":     requireSCTsSchema(),
+			"check_revocation": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"revocation_status": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"revoked_at": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"revocation_reason": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"fetch_ocsp_response": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"ocsp_response": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"ocsp_next_update": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 			"certificate_p12": {
 				Type:      schema.TypeString,
 				Computed:  true,
 				Sensitive: true,
 			},
+			"certificate_jks": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
 			"certificate_not_before": {
 				Type:     schema.TypeString,
 				Computed: true,
@@ -366,12 +688,69 @@ func resourceACMECertificateV5() *schema.Resource {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"certificate_pem_by_key_type": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"issuer_pem_by_key_type": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"private_key_pem_by_key_type": {
+				Type:      schema.TypeMap,
+				Computed:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+			"certificate_p12_by_key_type": {
+				Type:      schema.TypeMap,
+				Computed:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+			"certificate_url_by_key_type": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"certificate_serial_by_key_type": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"certificate_not_before_by_key_type": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"certificate_not_after_by_key_type": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 			"certificate_p12_password": {
 				Type:      schema.TypeString,
 				Optional:  true,
 				Default:   "",
 				Sensitive: true,
 			},
+			"p12_encoding": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  p12EncodingModern,
+				ValidateFunc: validation.StringInSlice([]string{
+					p12EncodingModern,
+					p12EncodingLegacyRC2,
+					p12EncodingLegacyDES,
+				}, false),
+			},
+			"certificate_friendly_name": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "",
+			},
 			"revoke_certificate_on_destroy": {
 				Type:     schema.TypeBool,
 				Optional: true,
@@ -414,12 +793,19 @@ func resourceACMECertificateCreate(d *schema.ResourceData, meta any) error {
 	// certificates drift during renewal (they are effectively new
 	// certificates). Use the certificate_url to get the URL of the
 	// current certificate instead.
-	resourceUUID, err := uuid.GenerateUUID()
-	if err != nil {
-		return fmt.Errorf("error generating UUID for resource: %s", err)
+	//
+	// A certificate whose order failed to complete on creation already has an
+	// ID, and is created again by update to resume the order.
+	resourceUUID := d.Id()
+	if resourceUUID == "" {
+		var err error
+		resourceUUID, err = uuid.GenerateUUID()
+		if err != nil {
+			return fmt.Errorf("error generating UUID for resource: %s", err)
+		}
 	}
 
-	client, _, err := expandACMEClient(d, meta, true)
+	client, user, err := expandACMEClient(d, meta, true)
 	if err != nil {
 		return err
 	}
@@ -430,53 +816,56 @@ func resourceACMECertificateCreate(d *schema.ResourceData, meta any) error {
 		return err
 	}
 
-	var cert *certificate.Resource
-
-	var notAfter time.Time
-	if v, ok := d.GetOk("validity_days"); ok {
-		// Note that we add a 15 minute skew to the duration to account for delays
-		// between the start of the challenge and the actual order.
-		notAfter = time.Now().Add(time.Duration(v.(int))*24*time.Hour + time.Minute*15)
-	}
+	notAfter := resourceACMECertificateNotAfter(d)
 
+	var request obtainRequest
 	if v, ok := d.GetOk("certificate_request_pem"); ok {
-		var csr *x509.CertificateRequest
-		csr, err = csrFromPEM([]byte(v.(string)))
+		request.CSR, err = csrFromPEM([]byte(v.(string)))
+		if err != nil {
+			return err
+		}
+	} else if _, ok := d.GetOk("key_pkcs11_uri"); ok {
+		request.CSR, _, err = resourceACMECertificatePKCS11CSR(d)
 		if err != nil {
 			return err
 		}
-		cert, err = client.Certificate.ObtainForCSR(certificate.ObtainForCSRRequest{
-			CSR:                            csr,
-			NotAfter:                       notAfter,
-			Bundle:                         true,
-			PreferredChain:                 d.Get("preferred_chain").(string),
-			Profile:                        d.Get("profile").(string),
-			AlwaysDeactivateAuthorizations: d.Get("deactivate_authorizations").(bool),
-		})
 	} else {
-		domains := []string{}
-		cn := d.Get("common_name").(string)
-		if cn != "" {
-			domains = append(domains, cn)
+		if v, _ := resourceACMECertificatePrivateKeyInput(d); v != "" {
+			request.PrivateKey, err = privateKeyFromPEM([]byte(v))
+			if err != nil {
+				return err
+			}
 		}
 
-		if s, ok := d.GetOk("subject_alternative_names"); ok {
-			for _, v := range stringSlice(s.(*schema.Set).List()) {
-				if v != cn {
-					domains = append(domains, v)
-				}
-			}
+		request.Domains, err = resourceACMECertificateDomains(d)
+		if err != nil {
+			return err
 		}
 
-		cert, err = client.Certificate.Obtain(certificate.ObtainRequest{
-			Domains:                        domains,
-			NotAfter:                       notAfter,
-			Bundle:                         true,
-			MustStaple:                     d.Get("must_staple").(bool),
-			PreferredChain:                 d.Get("preferred_chain").(string),
-			Profile:                        d.Get("profile").(string),
-			AlwaysDeactivateAuthorizations: d.Get("deactivate_authorizations").(bool),
-		})
+		request.MustStaple = d.Get("must_staple").(bool)
+	}
+
+	request.NotAfter = notAfter
+	request.Bundle = true
+	request.PreferredChain = d.Get("preferred_chain").(string)
+	request.Profile = d.Get("profile").(string)
+	// Authorizations are deactivated after the orders for any additional key
+	// types instead, so that those orders can re-use them.
+	request.AlwaysDeactivateAuthorizations = d.Get("deactivate_authorizations").(bool) &&
+		(request.CSR != nil || len(resourceACMECertificateAdditionalKeyTypes(d)) == 0)
+
+	cert, err := resourceACMECertificateObtain(d, meta, client, user, request)
+	var pending *certificateOrderPendingError
+	if errors.As(err, &pending) {
+		// Save the order with the resource. Terraform taints the resource as
+		// its creation failed, but if it is untainted, the order is resumed on
+		// the next apply (see resourceACMECertificateUpdate).
+		d.SetId(resourceUUID)
+		if err := resourceACMECertificateSetDomains(d); err != nil {
+			return err
+		}
+
+		return err
 	}
 
 	if err != nil {
@@ -484,15 +873,63 @@ func resourceACMECertificateCreate(d *schema.ResourceData, meta any) error {
 	}
 
 	d.SetId(resourceUUID)
-	password := d.Get("certificate_p12_password").(string)
-	if err := saveCertificateResource(d, cert, password); err != nil {
+	if err := resourceACMECertificateSave(d, cert); err != nil {
 		return err
 	}
 
-	return resourceACMECertificateRead(d, meta)
-}
-
+	// Check the SCT policy after the certificate has been saved, so that the
+	// resource is tainted and the certificate is replaced on the next apply if
+	// the policy is not met.
+	if err := resourceACMECertificateCheckSCTs(d); err != nil {
+		return err
+	}
+
+	if err := resourceACMECertificateVerifyChain(d); err != nil {
+		return err
+	}
+
+	if err := resourceACMECertificateSetDomains(d); err != nil {
+		return err
+	}
+
+	// Obtain the certificates for any additional key types. This is done after
+	// the primary certificate has been saved, so that it is not lost (and the
+	// resource is tainted) if one of these fails.
+	additionalCerts, err := resourceACMECertificateObtainByKeyType(d, client, notAfter)
+	if err != nil {
+		return err
+	}
+
+	if len(resourceACMECertificateKeyTypes(d)) > 0 {
+		if err := saveCertificateResourcesByKeyType(d, cert, additionalCerts, expandCertificateEncodeOptions(d)); err != nil {
+			return err
+		}
+	}
+
+	if resourceACMECertificateManagesKey(d) {
+		d.Set("private_key_created_at", time.Now().Format(time.RFC3339))
+	} else {
+		d.Set("private_key_created_at", "")
+	}
+	d.Set("private_key_supplied", resourceACMECertificatePrivateKeySupplied(d))
+
+	if err := resourceACMECertificateRead(d, meta); err != nil {
+		return err
+	}
+
+	resourceACMECertificateNextRenewalRefresh(d, time.Now(), true)
+
+	// Run the deploy hook last, once the certificate and those for any
+	// additional key types have been saved.
+	return resourceACMECertificateRunDeployHook(d, cert.PrivateKey, deployHookEventCreate)
+}
+
 func resourceACMECertificateRead(d *schema.ResourceData, meta any) error {
+	if resourceACMECertificateCreatePending(d) {
+		// There is no certificate to read until its order has completed.
+		return nil
+	}
+
 	client, _, err := expandACMEClient(d, meta, true)
 	if err != nil {
 		return err
@@ -517,12 +954,48 @@ func resourceACMECertificateRead(d *schema.ResourceData, meta any) error {
 
 		dstCR := expandCertificateResource(d)
 		dstCR.Certificate = srcCR.Certificate
-		password := d.Get("certificate_p12_password").(string)
-		if err := saveCertificateResource(d, dstCR, password); err != nil {
+		if err := saveCertificateResource(d, dstCR, expandCertificateEncodeOptions(d)); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := d.GetOk("certificate_p7b"); !ok || len(d.Get("certificate_info").([]any)) == 0 {
+		// Populate the chain formats and certificate info for certificates
+		// created before they were added. The bundles with the private key are
+		// populated on renewal.
+		if err := saveCertificateChainFormats(d, expandCertificateResource(d).Certificate); err != nil {
+			return err
+		}
+	}
+
+	if d.Get("private_key_created_at").(string) == "" && resourceACMECertificateManagesKey(d) {
+		// The age of keys created before private_key_created_at was added is
+		// unknown, so it is counted from the first read instead, rather than
+		// rotating the key straight away under max_age_days.
+		d.Set("private_key_created_at", time.Now().Format(time.RFC3339))
+	}
+
+	if len(d.Get("domains").([]any)) == 0 {
+		// Populate domains for certificates created before it was added.
+		if err := resourceACMECertificateSetDomains(d); err != nil {
 			return err
 		}
 	}
 
+	if err := resourceACMECertificateChainRefresh(d, meta); err != nil {
+		return err
+	}
+
+	if err := resourceACMECertificateRevocationRefresh(d); err != nil {
+		return err
+	}
+
+	if err := resourceACMECertificateOCSPRefresh(d, time.Now()); err != nil {
+		return err
+	}
+
+	resourceACMECertificateVerifyChainRefresh(d)
+
 	if err := resourceACMECertificateRenewalInfoRefresh(d, client, time.Now()); err != nil {
 		return err
 	}
@@ -549,19 +1022,27 @@ func resourceACMECertificateCustomizeDiff(_ context.Context, d *schema.ResourceD
 		}
 	}
 
-	// Validate that validity_days does not fall within min_days_remaining,
-	// which would cause the certificate to be renewed on every apply.
-	if v, ok := d.GetOk("validity_days"); ok && !d.Get("min_days_dynamic").(bool) {
-		days := v.(int)
-		minDays := d.Get("min_days_remaining").(int)
-		if days <= minDays {
-			return fmt.Errorf(
-				"validity_days (%d day(s)) is within min_days_remaining (%d); "+
-					"this would trigger immediate renewal on every apply - "+
-					"reduce min_days_remaining or increase validity_days",
-				days, minDays,
-			)
-		}
+	// Validate that validity_days or validity does not fall within
+	// min_days_remaining or renew_before, which would cause the certificate to
+	// be renewed on every apply.
+	if err := validateCertificateRenewalThreshold(d); err != nil {
+		return err
+	}
+
+	if err := validateCertificateKeyTypes(d); err != nil {
+		return err
+	}
+
+	if err := validateCertificateIPAddresses(d); err != nil {
+		return err
+	}
+
+	if err := resourceACMECertificateDomainsDiff(d); err != nil {
+		return err
+	}
+
+	if d.Get("key_rotation").(string) == keyRotationMaxAgeDays && d.Get("key_rotation_max_age_days").(int) < 1 {
+		return fmt.Errorf("key_rotation_max_age_days must be set when key_rotation is %q", keyRotationMaxAgeDays)
 	}
 
 	// There's nothing for us to do in a Create diff, so if there's no ID yet,
@@ -570,30 +1051,167 @@ func resourceACMECertificateCustomizeDiff(_ context.Context, d *schema.ResourceD
 		return nil
 	}
 
-	shouldRenew, err := resourceACMECertificateShouldRenew(d, time.Now())
+	// private_key_pem is also computed when the key is generated by the
+	// provider, so it can't be ForceNew in the schema, otherwise any change to
+	// the generated key would replace the resource. Only a change to a
+	// supplied key forces a new certificate.
+	if config := d.GetRawConfig(); d.HasChange("private_key_pem") && config.IsKnown() && !config.IsNull() && !config.GetAttr("private_key_pem").IsNull() {
+		if err := d.ForceNew("private_key_pem"); err != nil {
+			return err
+		}
+	}
+
+	if config := d.GetRawConfig(); config.IsKnown() && !config.IsNull() {
+		if supplied := resourceACMECertificatePrivateKeySupplied(d); supplied != d.Get("private_key_supplied").(bool) {
+			if err := d.SetNew("private_key_supplied", supplied); err != nil {
+				return err
+			}
+		}
+	}
+
+	now := time.Now()
+	shouldRenew, renewalWindowStatus, err := resourceACMECertificateRenewalSchedule(d, now)
+	if err != nil {
+		return err
+	}
+
+	nextRenewal, err := resourceACMECertificateNextRenewal(d, now)
 	if err != nil {
 		return err
 	}
 
-	if !shouldRenew && d.HasChange("validity_days") {
+	if !shouldRenew && d.HasChanges(resourceACMECertificateRenewalTriggerKeys...) {
 		shouldRenew = true
+		renewalWindowStatus = ""
+		nextRenewal.Reason = renewalReasonRenewalTriggers
+		if d.HasChanges("validity_days", "validity") {
+			nextRenewal.Reason = renewalReasonValidityChanged
+		}
+	}
+
+	if !shouldRenew {
+		shouldRenew, err = resourceACMECertificateKeyExpired(d, now)
+		if err != nil {
+			return err
+		}
+
+		if shouldRenew {
+			renewalWindowStatus = ""
+			nextRenewal.Reason = renewalReasonKeyRotation
+		}
+	}
+
+	// Explain a deferred or scheduled renewal in the plan.
+	if err := d.SetNew("renewal_window_status", renewalWindowStatus); err != nil {
+		return err
+	}
+
+	// Show why the certificate is renewed in the plan. The next renewal of a
+	// renewed certificate is only known after apply.
+	if err := d.SetNew("renewal_reason", nextRenewal.Reason); err != nil {
+		return err
+	}
+
+	if shouldRenew {
+		d.SetNewComputed("next_renewal_at")
+	} else if err := d.SetNew("next_renewal_at", nextRenewal.String()); err != nil {
+		return err
 	}
 
 	if shouldRenew {
 		d.SetNewComputed("certificate_pem")
+		d.SetNewComputed("certificate_pem_fullchain")
+		d.SetNewComputed("certificate_der")
+		d.SetNewComputed("certificate_p7b")
+		d.SetNewComputed("certificate_info")
+		d.SetNewComputed("certificate_scts")
+		d.SetNewComputed("revocation_status")
+		d.SetNewComputed("revoked_at")
+		d.SetNewComputed("revocation_reason")
+		if resourceACMECertificateOCSPEnabled(d) {
+			d.SetNewComputed("ocsp_response")
+			d.SetNewComputed("ocsp_next_update")
+		}
 		d.SetNewComputed("certificate_p12")
+		d.SetNewComputed("certificate_jks")
 		d.SetNewComputed("certificate_url")
 		d.SetNewComputed("certificate_domain")
+		d.SetNewComputed("certificate_ip_addresses")
 		d.SetNewComputed("certificate_not_before")
 		d.SetNewComputed("certificate_not_after")
-		d.SetNewComputed("private_key_pem")
+		// Only flag the private key as changing if it will be rotated, so that
+		// key rotations are visible in the plan.
+		rotateKey, err := resourceACMECertificateShouldRotateKey(d, now)
+		if err != nil {
+			return err
+		}
+
+		if rotateKey {
+			if !d.Get("omit_private_key_pem").(bool) {
+				d.SetNewComputed("private_key_pem")
+			}
+			d.SetNewComputed("private_key_pem_encrypted")
+			d.SetNewComputed("private_key_created_at")
+		}
 		d.SetNewComputed("issuer_pem")
+		d.SetNewComputed("issuer_der")
 		d.SetNewComputed("certificate_serial")
 		d.SetNewComputed("renewal_info_window_start")
 		d.SetNewComputed("renewal_info_window_end")
 		d.SetNewComputed("renewal_info_window_selected")
 		d.SetNewComputed("renewal_info_explanation_url")
 		d.SetNewComputed("renewal_info_retry_after")
+		d.SetNewComputed("chain_verification_error")
+		d.SetNewComputed("order_url")
+		d.SetNewComputed("authorizations")
+		// The order is saved if the certificate can't be obtained, and cleared
+		// once it has been.
+		d.SetNewComputed("pending_order_url")
+		d.SetNewComputed("pending_order_authorizations")
+		d.SetNewComputed("pending_order_private_key_pem")
+		d.SetNewComputed("pending_order_solved_authorizations")
+		if len(resourceACMECertificateKeyTypes(d)) > 0 {
+			for _, k := range resourceACMECertificateKeyTypeMaps {
+				d.SetNewComputed(k)
+			}
+		}
+	} else if d.HasChange("preferred_chain") || resourceACMECertificateChainChanged(d) {
+		// The chain is re-selected in place, without reissuing the certificate.
+		for _, k := range certificateChainFields {
+			d.SetNewComputed(k)
+		}
+		d.SetNewComputed("chain_verification_error")
+		if len(resourceACMECertificateKeyTypes(d)) > 0 {
+			for _, k := range resourceACMECertificateKeyTypeMaps {
+				d.SetNewComputed(k)
+			}
+		}
+	}
+
+	if d.HasChange("private_key_passphrase_version") {
+		d.SetNewComputed("private_key_pem_encrypted")
+	}
+
+	if d.HasChanges("verify_chain", "trusted_roots_pem") {
+		d.SetNewComputed("chain_verification_error")
+	}
+
+	if d.HasChanges("certificate_p12_password", "p12_encoding", "certificate_friendly_name") {
+		d.SetNewComputed("certificate_p12")
+		d.SetNewComputed("certificate_jks")
+		if len(resourceACMECertificateKeyTypes(d)) > 0 {
+			d.SetNewComputed("certificate_p12_by_key_type")
+		}
+	}
+
+	if d.HasChange("omit_private_key_pem") {
+		if d.Get("omit_private_key_pem").(bool) {
+			d.SetNew("private_key_pem", "")
+		} else {
+			d.SetNewComputed("private_key_pem")
+		}
+		d.SetNewComputed("certificate_p12")
+		d.SetNewComputed("certificate_jks")
 	}
 
 	return nil
@@ -601,24 +1219,84 @@ func resourceACMECertificateCustomizeDiff(_ context.Context, d *schema.ResourceD
 
 // resourceACMECertificateUpdate renews a certificate if it has been flagged as changed.
 func resourceACMECertificateUpdate(d *schema.ResourceData, meta any) error {
-	shouldRenew, err := resourceACMECertificateShouldRenew(d, time.Now())
+	if resourceACMECertificateCreatePending(d) {
+		return resourceACMECertificateCreate(d, meta)
+	}
+
+	now := time.Now()
+	shouldRenew, err := resourceACMECertificateShouldRenew(d, now)
 	if err != nil {
 		return err
 	}
 
-	if !shouldRenew && d.HasChange("validity_days") {
+	// The event to run the deploy hook for, and the private key to pass to
+	// it, if the certificate or its chain has changed.
+	var deployEvent string
+	var deployKey []byte
+
+	if !shouldRenew && d.HasChanges(resourceACMECertificateRenewalTriggerKeys...) {
 		shouldRenew = true
 	}
 
 	if !shouldRenew {
-		// when the certificate hasn't changed but the p12 password has, we still need to regenerate the p12
-		if d.HasChange("certificate_p12_password") {
-			cert := expandCertificateResource(d)
-			password := d.Get("certificate_p12_password").(string)
-			if err := saveCertificateResource(d, cert, password); err != nil {
+		shouldRenew, err = resourceACMECertificateKeyExpired(d, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !shouldRenew {
+		// Enable partial mode to keep the current chain and settings in state
+		// if the chain does not verify.
+		d.Partial(true)
+
+		var cert *certificate.Resource
+		if d.HasChange("preferred_chain") || resourceACMECertificateChainChanged(d) {
+			// Re-select the chain without reissuing the certificate. This saves
+			// all of the bundles, so covers the below changes too.
+			cert, err = resourceACMECertificateUpdateChain(d, meta)
+			if err != nil {
+				return err
+			}
+
+			deployEvent = deployHookEventChain
+		} else if d.HasChanges(
+			"certificate_p12_password",
+			"p12_encoding",
+			"certificate_friendly_name",
+			"private_key_passphrase_version",
+			"omit_private_key_pem",
+		) {
+			// when the certificate hasn't changed but the p12 password, the
+			// bundle encoding, or the key encryption settings have, we still need
+			// to regenerate the p12 and JKS bundles and the encrypted key
+			cert, err = resourceACMECertificateExpand(d)
+			if err != nil {
+				return err
+			}
+
+			if err := resourceACMECertificateSave(d, cert); err != nil {
+				return err
+			}
+		}
+
+		if cert != nil && len(resourceACMECertificateKeyTypes(d)) > 0 {
+			if err := saveCertificateResourcesByKeyType(d, cert, expandCertificateResourcesByKeyType(d, false), expandCertificateEncodeOptions(d)); err != nil {
+				return err
+			}
+		}
+
+		if cert != nil || d.HasChanges("verify_chain", "trusted_roots_pem") {
+			if err := resourceACMECertificateVerifyChain(d); err != nil {
 				return err
 			}
 		}
+
+		if cert != nil {
+			deployKey = cert.PrivateKey
+		}
+
+		d.Partial(false)
 	} else {
 		// Enable partial mode to protect the certificate during renewal
 		d.Partial(true)
@@ -628,12 +1306,39 @@ func resourceACMECertificateUpdate(d *schema.ResourceData, meta any) error {
 			return err
 		}
 
-		client, _, err := expandACMEClient(d, meta, true)
+		client, user, err := expandACMEClient(d, meta, true)
+		if err != nil {
+			return err
+		}
+
+		rotateKey, err := resourceACMECertificateShouldRotateKey(d, now)
 		if err != nil {
 			return err
 		}
 
-		cert := expandCertificateResource(d)
+		var cert *certificate.Resource
+		if rotateKey {
+			// Clear out the key so that a new one is generated on renewal.
+			cert = expandCertificateResource(d)
+			cert.PrivateKey = nil
+		} else {
+			cert, err = resourceACMECertificateExpand(d)
+			if err != nil {
+				return err
+			}
+		}
+		if v, _ := resourceACMECertificatePrivateKeyInput(d); v != "" {
+			// Renew with the supplied private key, which is not in state if it was
+			// supplied as write-only.
+			cert.PrivateKey = []byte(v)
+		}
+		if _, ok := d.GetOk("key_pkcs11_uri"); ok {
+			// Renew using a fresh CSR signed by the key in the token.
+			_, cert.CSR, err = resourceACMECertificatePKCS11CSR(d)
+			if err != nil {
+				return err
+			}
+		}
 
 		dnsCloser, err := setCertificateChallengeProviders(client, d)
 		defer dnsCloser()
@@ -641,39 +1346,77 @@ func resourceACMECertificateUpdate(d *schema.ResourceData, meta any) error {
 			return err
 		}
 
-		var notAfter time.Time
-		if v, ok := d.GetOk("validity_days"); ok {
-			// Note that we add a 15 minute skew to the duration to account for delays
-			// between the start of the challenge and the actual order.
-			notAfter = time.Now().Add(time.Duration(v.(int))*24*time.Hour + time.Minute*15)
-		}
-
-		newCert, err := renewWithOptions(
-			client.Certificate,
-			*cert,
-			localRenewOptions{
-				RenewOptions: certificate.RenewOptions{
-					NotAfter:                       notAfter,
-					Bundle:                         true,
-					PreferredChain:                 d.Get("preferred_chain").(string),
-					Profile:                        d.Get("profile").(string),
-					MustStaple:                     d.Get("must_staple").(bool),
-					AlwaysDeactivateAuthorizations: d.Get("deactivate_authorizations").(bool),
-				},
-				UseARI: d.Get("use_renewal_info").(bool),
+		notAfter := resourceACMECertificateNotAfter(d)
+
+		renewOptions := localRenewOptions{
+			RenewOptions: certificate.RenewOptions{
+				NotAfter:                       notAfter,
+				Bundle:                         true,
+				PreferredChain:                 d.Get("preferred_chain").(string),
+				Profile:                        d.Get("profile").(string),
+				MustStaple:                     d.Get("must_staple").(bool),
+				AlwaysDeactivateAuthorizations: d.Get("deactivate_authorizations").(bool),
 			},
-		)
+			UseARI: d.Get("use_renewal_info").(bool),
+		}
+
+		if _, ok := d.GetOk("certificate_request_pem"); !ok {
+			// Renew with the normalized domains, rather than the ones read back
+			// from the certificate.
+			renewOptions.Domains, err = resourceACMECertificateDomains(d)
+			if err != nil {
+				return err
+			}
+		}
+
+		primaryOptions := renewOptions
+		if len(resourceACMECertificateAdditionalKeyTypes(d)) > 0 {
+			// Keep the authorizations around for the additional key types.
+			primaryOptions.AlwaysDeactivateAuthorizations = false
+		}
+
+		newCert, err := resourceACMECertificateRenewOrder(d, meta, client, user, *cert, primaryOptions)
+		if err != nil {
+			return err
+		}
+
+		// Renew the certificates for any additional key types along with the
+		// primary certificate. Partial mode ensures that none of the renewed
+		// certificates are saved if any of these fail.
+		additionalCerts, err := resourceACMECertificateRenewByKeyType(d, client, renewOptions, rotateKey)
 		if err != nil {
 			return err
 		}
 
-		password := d.Get("certificate_p12_password").(string)
-		if err := saveCertificateResource(d, newCert, password); err != nil {
+		if err := resourceACMECertificateSave(d, newCert); err != nil {
+			return err
+		}
+
+		// Partial mode keeps the old certificate in state if the renewed
+		// certificate does not meet the SCT policy, or its chain does not
+		// verify.
+		if err := resourceACMECertificateCheckSCTs(d); err != nil {
+			return err
+		}
+
+		if err := resourceACMECertificateVerifyChain(d); err != nil {
 			return err
 		}
 
+		if len(resourceACMECertificateKeyTypes(d)) > 0 {
+			if err := saveCertificateResourcesByKeyType(d, newCert, additionalCerts, expandCertificateEncodeOptions(d)); err != nil {
+				return err
+			}
+		}
+
+		if rotateKey {
+			d.Set("private_key_created_at", time.Now().Format(time.RFC3339))
+		}
+
 		// Complete, safe to turn off partial mode now.
 		d.Partial(false)
+		deployEvent = deployHookEventRenew
+		deployKey = newCert.PrivateKey
 
 		// Clear out ARI computed data so that it can be properly refreshed on the
 		// below read.
@@ -682,9 +1425,415 @@ func resourceACMECertificateUpdate(d *schema.ResourceData, meta any) error {
 		d.Set("renewal_info_window_selected", "")
 		d.Set("renewal_info_explanation_url", "")
 		d.Set("renewal_info_retry_after", "")
+
+		// Clear out the revocation status of the old certificate, so that it is
+		// not carried over to the new one if the below read can't check it.
+		d.Set("revocation_status", "")
+		d.Set("revoked_at", "")
+		d.Set("revocation_reason", "")
 	}
 
-	return resourceACMECertificateRead(d, meta)
+	if err := resourceACMECertificateRead(d, meta); err != nil {
+		return err
+	}
+
+	resourceACMECertificateNextRenewalRefresh(d, time.Now(), false)
+
+	if deployEvent != "" {
+		// Run the deploy hook last, once the certificate and those for any
+		// additional key types have been saved, so that a failure does not
+		// discard them.
+		return resourceACMECertificateRunDeployHook(d, deployKey, deployEvent)
+	}
+
+	return nil
+}
+
+// resourceACMECertificateDomains returns the domains for the certificate,
+// with the common name first. Domains are normalized with normalizeDomain, and
+// duplicates after normalization are removed.
+func resourceACMECertificateDomains(d resourceDataOrDiff) ([]string, error) {
+	domains := []string{}
+	seen := make(map[string]bool)
+	add := func(v string) error {
+		domain, err := normalizeDomain(v)
+		if err != nil {
+			return err
+		}
+
+		if !seen[domain] {
+			domains = append(domains, domain)
+			seen[domain] = true
+		}
+
+		return nil
+	}
+
+	if cn := d.Get("common_name").(string); cn != "" {
+		if err := add(cn); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range stringSlice(d.Get("subject_alternative_names").(*schema.Set).List()) {
+		if err := add(v); err != nil {
+			return nil, err
+		}
+	}
+
+	// IP addresses are passed to lego along with the domains, which orders them
+	// as ip identifiers (RFC 8738).
+	for _, v := range stringSlice(d.Get("ip_addresses").(*schema.Set).List()) {
+		domains = append(domains, net.ParseIP(v).String())
+	}
+
+	return domains, nil
+}
+
+// resourceACMECertificateIdentifiers returns the identifiers that are ordered
+// for the certificate, either from the CSR in certificate_request_pem, or from
+// resourceACMECertificateDomains.
+func resourceACMECertificateIdentifiers(d resourceDataOrDiff) ([]string, error) {
+	if v := d.Get("certificate_request_pem").(string); v != "" {
+		csr, err := csrFromPEM([]byte(v))
+		if err != nil {
+			return nil, err
+		}
+
+		return certcrypto.ExtractDomainsCSR(csr), nil
+	}
+
+	return resourceACMECertificateDomains(d)
+}
+
+// resourceACMECertificateSetDomains sets domains to the identifiers ordered
+// for the certificate.
+func resourceACMECertificateSetDomains(d *schema.ResourceData) error {
+	domains, err := resourceACMECertificateIdentifiers(d)
+	if err != nil {
+		return err
+	}
+
+	return d.Set("domains", domains)
+}
+
+// resourceACMECertificateDomainsDiff sets domains in the diff to the
+// identifiers that will be ordered, or marks it as computed if they are not
+// known yet.
+func resourceACMECertificateDomainsDiff(d *schema.ResourceDiff) error {
+	if d.Id() != "" && !d.HasChanges("common_name", "subject_alternative_names", "ip_addresses", "certificate_request_pem") {
+		return nil
+	}
+
+	config := d.GetRawConfig()
+	if config.IsNull() {
+		return nil
+	}
+
+	if !config.IsWhollyKnown() {
+		for _, k := range []string{"common_name", "subject_alternative_names", "ip_addresses", "certificate_request_pem"} {
+			if !config.GetAttr(k).IsWhollyKnown() {
+				return d.SetNewComputed("domains")
+			}
+		}
+	}
+
+	domains, err := resourceACMECertificateIdentifiers(d)
+	if err != nil {
+		return err
+	}
+
+	return d.SetNew("domains", domains)
+}
+
+// validateCertificateIPAddresses checks that ip_addresses is used with a
+// challenge type that can validate IP address identifiers. Only http-01 and
+// tls-alpn-01 can be used for these (RFC 8738), so at least one HTTP or TLS
+// challenge needs to be configured. Wildcard domains can only be validated
+// with dns-01, so they also require dns_challenge when combined with IP
+// addresses.
+func validateCertificateIPAddresses(d resourceDataOrDiff) error {
+	if len(d.Get("ip_addresses").(*schema.Set).List()) == 0 {
+		return nil
+	}
+
+	hasHTTPOrTLS := false
+	for _, k := range []string{
+		"http_challenge",
+		"http_webroot_challenge",
+		"http_memcached_challenge",
+		"http_s3_challenge",
+		"tls_challenge",
+	} {
+		if len(d.Get(k).([]any)) > 0 {
+			hasHTTPOrTLS = true
+		}
+	}
+
+	if !hasHTTPOrTLS {
+		return errors.New(
+			"ip_addresses can only be validated with http-01 or tls-alpn-01 challenges; " +
+				"dns_challenge cannot be used for IP address identifiers, so an HTTP or TLS challenge must be configured",
+		)
+	}
+
+	names := stringSlice(d.Get("subject_alternative_names").(*schema.Set).List())
+	names = append(names, d.Get("common_name").(string))
+	for _, name := range names {
+		if strings.HasPrefix(name, "*.") && len(d.Get("dns_challenge").([]any)) == 0 {
+			return fmt.Errorf(
+				"wildcard domain %q can only be validated with dns-01, which cannot be used for ip_addresses; "+
+					"dns_challenge must be configured alongside an HTTP or TLS challenge to combine wildcards with IP addresses",
+				name,
+			)
+		}
+	}
+
+	return nil
+}
+
+// resourceACMECertificatePrivateKeyInput returns the private key supplied for
+// the certificate in either private_key_pem or private_key_pem_wo, and whether
+// or not it was supplied as write-only. An empty string is returned if a key
+// has not been supplied (including when private_key_pem is only set in state).
+func resourceACMECertificatePrivateKeyInput(d resourceDataOrDiffWithConfig) (string, bool) {
+	if v := resourceACMECertificateRawConfigString(d, "private_key_pem"); v != "" {
+		return v, false
+	}
+
+	if v := resourceACMECertificateRawConfigString(d, "private_key_pem_wo"); v != "" {
+		return v, true
+	}
+
+	return "", false
+}
+
+// resourceACMECertificateRawConfigString returns a string attribute from the
+// raw configuration, which is where write-only attributes need to be read
+// from. An empty string is returned if the value is null or unknown.
+func resourceACMECertificateRawConfigString(d resourceDataOrDiffWithConfig, key string) string {
+	config := d.GetRawConfig()
+	if config.IsNull() || !config.IsKnown() {
+		return ""
+	}
+
+	if v := config.GetAttr(key); v.IsKnown() && !v.IsNull() {
+		return v.AsString()
+	}
+
+	return ""
+}
+
+// resourceACMECertificatePrivateKeySupplied returns true if a private key is
+// set in private_key_pem or private_key_pem_wo in the configuration, even if
+// its value is not known yet. This is saved in private_key_supplied.
+func resourceACMECertificatePrivateKeySupplied(d resourceDataOrDiffWithConfig) bool {
+	config := d.GetRawConfig()
+	if config.IsNull() || !config.IsKnown() {
+		return false
+	}
+
+	return !config.GetAttr("private_key_pem").IsNull() || !config.GetAttr("private_key_pem_wo").IsNull()
+}
+
+// resourceACMECertificateManagesKey returns true if the certificate's private
+// key is generated by the provider, versus being supplied through a CSR, a
+// PKCS#11 token, or private_key_pem/private_key_pem_wo. key_rotation only
+// applies to keys generated by the provider.
+func resourceACMECertificateManagesKey(d resourceDataOrDiffWithConfig) bool {
+	if _, ok := d.GetOk("certificate_request_pem"); ok {
+		return false
+	}
+
+	if _, ok := d.GetOk("key_pkcs11_uri"); ok {
+		return false
+	}
+
+	if d.GetRawConfig().IsNull() {
+		// The configuration is not available during refresh, and a supplied
+		// private_key_pem can't be told apart from a generated key in state.
+		return !d.Get("private_key_supplied").(bool)
+	}
+
+	v, _ := resourceACMECertificatePrivateKeyInput(d)
+	return v == ""
+}
+
+// resourceACMECertificateShouldRotateKey returns true if the private key
+// should be replaced with a new one when the certificate is renewed, as per
+// key_rotation.
+func resourceACMECertificateShouldRotateKey(d resourceDataOrDiffWithConfig, now time.Time) (bool, error) {
+	if !resourceACMECertificateManagesKey(d) {
+		return false, nil
+	}
+
+	// The CA will refuse to issue a new certificate for a compromised key.
+	if resourceACMECertificateRevoked(d) && d.Get("revocation_reason").(string) == string(RevocationReasonKeyCompromise) {
+		return true, nil
+	}
+
+	switch d.Get("key_rotation").(string) {
+	case keyRotationAlways:
+		return true, nil
+
+	case keyRotationMaxAgeDays:
+		return resourceACMECertificateKeyExpired(d, now)
+	}
+
+	return false, nil
+}
+
+// resourceACMECertificateKeyExpired returns true if key_rotation is
+// max_age_days and the private key is at least key_rotation_max_age_days
+// old. This triggers a renewal on its own, regardless of the certificate's
+// expiry. A key with an unknown age (from state prior to the addition of
+// private_key_created_at, before it is seeded on read) is not considered
+// expired.
+func resourceACMECertificateKeyExpired(d resourceDataOrDiffWithConfig, now time.Time) (bool, error) {
+	if d.Get("key_rotation").(string) != keyRotationMaxAgeDays || !resourceACMECertificateManagesKey(d) {
+		return false, nil
+	}
+
+	v := d.Get("private_key_created_at").(string)
+	if v == "" {
+		return false, nil
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return false, fmt.Errorf("error parsing private_key_created_at: %w", err)
+	}
+
+	maxAge := time.Duration(d.Get("key_rotation_max_age_days").(int)) * 24 * time.Hour
+	return now.Sub(createdAt) >= maxAge, nil
+}
+
+// resourceACMECertificateSave saves the certificate to state with
+// saveCertificateResource. If a private key was supplied, it is used as-is for
+// the certificate (so that the encoding in private_key_pem does not drift), and
+// if it was supplied as write-only, it is kept out of private_key_pem.
+func resourceACMECertificateSave(d *schema.ResourceData, cert *certificate.Resource) error {
+	v, writeOnly := resourceACMECertificatePrivateKeyInput(d)
+	if v != "" {
+		cert.PrivateKey = []byte(v)
+	}
+
+	opts := expandCertificateEncodeOptions(d)
+	if err := saveCertificateResource(d, cert, opts); err != nil {
+		return err
+	}
+
+	omit := d.Get("omit_private_key_pem").(bool)
+	if writeOnly || omit {
+		d.Set("private_key_pem", "")
+	}
+
+	if omit && opts.Password == "" {
+		// An unencrypted PKCS#12 or JKS bundle would expose the key just the same.
+		d.Set("certificate_p12", "")
+		d.Set("certificate_jks", "")
+	}
+
+	return resourceACMECertificateSaveEncryptedKey(d, cert.PrivateKey)
+}
+
+// resourceACMECertificateExpand returns the certificate.Resource for the
+// certificate in state, like expandCertificateResource. If the private key is
+// not in state as plaintext, it is decrypted from private_key_pem_encrypted.
+func resourceACMECertificateExpand(d *schema.ResourceData) (*certificate.Resource, error) {
+	cert := expandCertificateResource(d)
+	if len(cert.PrivateKey) > 0 {
+		return cert, nil
+	}
+
+	// The key may be in the old state when omit_private_key_pem is being
+	// toggled on.
+	if o, _ := d.GetChange("private_key_pem"); o.(string) != "" {
+		cert.PrivateKey = []byte(o.(string))
+		return cert, nil
+	}
+
+	o, _ := d.GetChange("private_key_pem_encrypted")
+	if o.(string) == "" {
+		return cert, nil
+	}
+
+	key, err := decryptPrivateKeyPEM([]byte(o.(string)), resourceACMECertificateRawConfigString(d, "private_key_passphrase"))
+	if err != nil {
+		return nil, fmt.Errorf(
+			"unable to decrypt private_key_pem_encrypted: %w; note that when omit_private_key_pem is set, "+
+				"the private key can only be recovered with the passphrase it was encrypted with, "+
+				"so private_key_passphrase can only be changed along with a new key",
+			err,
+		)
+	}
+
+	cert.PrivateKey = certcrypto.PEMEncode(key)
+	return cert, nil
+}
+
+// resourceACMECertificateSaveEncryptedKey sets private_key_pem_encrypted to
+// the supplied key, encrypted with private_key_passphrase. The existing value
+// is kept if it already holds the same key, so that it does not change on
+// every save.
+func resourceACMECertificateSaveEncryptedKey(d *schema.ResourceData, keyPEM []byte) error {
+	passphrase := resourceACMECertificateRawConfigString(d, "private_key_passphrase")
+	if passphrase == "" || len(keyPEM) == 0 {
+		d.Set("private_key_pem_encrypted", "")
+		return nil
+	}
+
+	key, err := privateKeyFromPEM(keyPEM)
+	if err != nil {
+		return err
+	}
+
+	if v := d.Get("private_key_pem_encrypted").(string); v != "" {
+		if existing, err := decryptPrivateKeyPEM([]byte(v), passphrase); err == nil {
+			if k, ok := existing.(interface{ Equal(crypto.PrivateKey) bool }); ok && k.Equal(key) {
+				return nil
+			}
+		}
+	}
+
+	encrypted, err := encryptPrivateKeyPEM(keyPEM, passphrase)
+	if err != nil {
+		return fmt.Errorf("error encrypting private key: %w", err)
+	}
+
+	d.Set("private_key_pem_encrypted", string(encrypted))
+	return nil
+}
+
+// resourceACMECertificatePKCS11CSR generates a CSR for the certificate's
+// domains, signed with the key referenced by key_pkcs11_uri. The CSR is
+// returned both parsed and in PEM format.
+func resourceACMECertificatePKCS11CSR(d *schema.ResourceData) (*x509.CertificateRequest, []byte, error) {
+	signer, err := pkcs11SignerForURI(d.Get("key_pkcs11_uri").(string))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	domains, err := resourceACMECertificateDomains(d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := certcrypto.CreateCSR(signer, certcrypto.CSROptions{
+		Domain:     domains[0],
+		SAN:        domains,
+		MustStaple: d.Get("must_staple").(bool),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating CSR with PKCS#11 key: %w", err)
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return csr, pem.EncodeToMemory(&pem.Block{Type: preambleCertificateRequest, Bytes: der}), nil
 }
 
 // resourceACMECertificateDelete "deletes" the certificate by revoking it.
@@ -699,72 +1848,124 @@ func resourceACMECertificateDelete(d *schema.ResourceData, meta any) error {
 	}
 
 	cert := expandCertificateResource(d)
+	if len(cert.Certificate) == 0 {
+		// The certificate was never created, as its order failed to complete.
+		return nil
+	}
+
 	remaining, err := certSecondsRemaining(cert, time.Now())
 	if err != nil {
 		return err
 	}
 
 	if remaining >= 0 {
-		maybeReason, ok := d.GetOk("revoke_certificate_reason")
-		if ok {
-			reason := RevocationReason(maybeReason.(string))
-			reasonNum, err := GetRevocationReason(reason)
-			if err != nil {
-				return err
+		if err := resourceACMECertificateRevoke(d, client, cert); err != nil {
+			return err
+		}
+	}
+
+	for kt, cert := range expandCertificateResourcesByKeyType(d, false) {
+		if len(cert.Certificate) == 0 {
+			continue
+		}
+
+		remaining, err := certSecondsRemaining(cert, time.Now())
+		if err != nil {
+			return fmt.Errorf("error reading %s certificate: %w", kt, err)
+		}
+
+		if remaining >= 0 {
+			if err := resourceACMECertificateRevoke(d, client, cert); err != nil {
+				return fmt.Errorf("error revoking %s certificate: %w", kt, err)
 			}
-			return client.Certificate.RevokeWithReason(cert.Certificate, &reasonNum)
 		}
-		return client.Certificate.Revoke(cert.Certificate)
 	}
+
 	return nil
 }
 
+// resourceACMECertificateRevoke revokes the supplied certificate, with
+// revoke_certificate_reason if it has been set.
+func resourceACMECertificateRevoke(d *schema.ResourceData, client *lego.Client, cert *certificate.Resource) error {
+	maybeReason, ok := d.GetOk("revoke_certificate_reason")
+	if ok {
+		reason := RevocationReason(maybeReason.(string))
+		reasonNum, err := GetRevocationReason(reason)
+		if err != nil {
+			return err
+		}
+		return client.Certificate.RevokeWithReason(cert.Certificate, &reasonNum)
+	}
+	return client.Certificate.Revoke(cert.Certificate)
+}
+
 // resourceACMECertificateHasExpired checks the acme_certificate
 // resource to see if it has expired.
 func resourceACMECertificateHasExpired(d resourceDataOrDiff, now time.Time) (bool, error) {
+	renewAt, _, err := resourceACMECertificateRenewalThreshold(d, now)
+	if err != nil || renewAt.IsZero() {
+		return false, err
+	}
+
+	result := !now.Before(renewAt)
+	log.Printf("[DEBUG] acme_certificate renewal check: (now >= renewal threshold) => (%s >= %s) => %t",
+		now.UTC().Format(time.RFC3339),
+		renewAt.UTC().Format(time.RFC3339),
+		result,
+	)
+
+	return result, nil
+}
+
+// resourceACMECertificateRenewalThreshold returns the time that the
+// certificate reaches its renewal threshold, from renew_before,
+// min_days_remaining or min_days_dynamic, along with the renewal reason for the
+// threshold. The zero time is returned if the certificate is never renewed.
+func resourceACMECertificateRenewalThreshold(d resourceDataOrDiff, now time.Time) (time.Time, string, error) {
 	cert := expandCertificateResource(d)
-	remaining, err := certDaysRemaining(cert, now)
+	remaining, err := certSecondsRemaining(cert, now)
 	if err != nil {
-		return false, err
+		return time.Time{}, "", fmt.Errorf("unable to calculate time to certificate expiry: %s", err)
 	}
 
-	if d.Get("min_days_dynamic").(bool) {
-		lifetimeDays, err := certLifetimeDays(cert)
-		if err != nil {
-			return false, err
+	lifetime, err := certLifetime(cert)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	expiry := time.Unix(now.Unix()+remaining, 0)
+	if renewBefore, k := resourceACMECertificateRenewBefore(d, lifetime); k != "" {
+		reason := renewalReasonMinDays
+		if k == "renew_before" {
+			reason = renewalReasonRenewBefore
 		}
 
+		log.Printf("[DEBUG] acme_certificate renewal threshold: (expiry - %s) => (%s - %s)",
+			k,
+			expiry.UTC().Format(time.RFC3339),
+			renewBefore,
+		)
+
+		return expiry.Add(-renewBefore), reason, nil
+	}
+
+	if d.Get("min_days_dynamic").(bool) {
 		threshold := 1.0 / 3.0
-		if lifetimeDays <= 10.0 {
+		if lifetime <= 10*24*time.Hour {
 			threshold = 0.5
 		}
 
-		remF := float64(remaining)
-		remPct := remF / lifetimeDays
-		result := remPct <= threshold
-		log.Printf("[DEBUG] acme_certificate dynamic renewal check: (remaining / lifetimeDays <= threshold) => (%.2f / %.2f <= %.2f) => (%.2f <= %.2f) => %t",
-			remF,
-			lifetimeDays,
-			threshold,
-			remPct,
+		log.Printf("[DEBUG] acme_certificate dynamic renewal threshold: (expiry - lifetime * threshold) => (%s - %s * %.2f)",
+			expiry.UTC().Format(time.RFC3339),
+			lifetime,
 			threshold,
-			result,
 		)
 
-		return result, nil
+		return expiry.Add(-time.Duration(float64(lifetime) * threshold)), renewalReasonDynamicThreshold, nil
 	}
 
-	mindays := d.Get("min_days_remaining").(int)
-	if mindays < 0 {
-		log.Printf("[WARN] min_days_remaining is set to less than 0, certificate will never be renewed")
-		return false, nil
-	}
-
-	if int64(mindays) >= remaining {
-		return true, nil
-	}
-
-	return false, nil
+	log.Printf("[WARN] min_days_remaining is set to less than 0, certificate will never be renewed")
+	return time.Time{}, "", nil
 }
 
 func resourceACMECertificatePreCheckDelay(delay int) dns01.WrapPreCheckFunc {
@@ -931,59 +2132,66 @@ func resourceACMECertificateRenewalInfoRefresh(
 	return nil
 }
 
+// resourceACMECertificateShouldRenew returns true if the certificate should be
+// renewed now.
 func resourceACMECertificateShouldRenew(d resourceDataOrDiff, now time.Time) (bool, error) {
-	if d.Get("use_renewal_info").(bool) {
-		canSleep, err := resourceACMECertificateRenewalInfoCanSleepUntilSelected(d, now)
-		if err != nil {
-			return false, err
-		}
+	shouldRenew, _, err := resourceACMECertificateRenewalSchedule(d, now)
+	return shouldRenew, err
+}
 
-		if canSleep {
-			return true, nil
-		}
+// resourceACMECertificateRenewalSchedule returns true if the certificate should
+// be renewed now, along with the status of the renewal window (see
+// resourceACMECertificateRenewalWindowCheck).
+func resourceACMECertificateRenewalSchedule(d resourceDataOrDiff, now time.Time) (bool, string, error) {
+	due, err := resourceACMECertificateRenewalDue(d, now)
+	if err != nil || !due {
+		return false, "", err
+	}
+
+	deferredUntil, status, err := resourceACMECertificateRenewalWindowCheck(d, now)
+	if err != nil {
+		return false, "", err
 	}
 
-	return resourceACMECertificateHasExpired(d, now)
+	return deferredUntil.IsZero(), status, nil
 }
 
-func resourceACMECertificateRenewalInfoCanSleepUntilSelected(
-	d resourceDataOrDiff,
-	now time.Time,
-) (bool, error) {
-	var selectedTime time.Time
-	if selected := d.Get("renewal_info_window_selected").(string); selected != "" {
-		var err error
-		selectedTime, err = time.Parse(time.RFC3339, selected)
-		if err != nil {
-			return false, fmt.Errorf("malformed renewal_info_window_selected: %w", err)
-		}
-	} else {
-		return false, errors.New(
+// resourceACMECertificateRenewalDue returns true if the certificate is due for
+// renewal, before the renewal window is taken into account.
+func resourceACMECertificateRenewalDue(d resourceDataOrDiff, now time.Time) (bool, error) {
+	r, err := resourceACMECertificateRenewalDueAt(d, now)
+	if err != nil || r.DueAt.IsZero() {
+		return false, err
+	}
+
+	return !now.Before(r.DueAt), nil
+}
+
+// resourceACMECertificateRenewalInfoSelected returns the renewal time
+// selected from the ARI suggested window.
+func resourceACMECertificateRenewalInfoSelected(d resourceDataOrDiff) (time.Time, error) {
+	selected := d.Get("renewal_info_window_selected").(string)
+	if selected == "" {
+		return time.Time{}, errors.New(
 			"renewal_info_window_selected expected to be set. This is a bug, please report it")
 	}
 
-	canSleepUntil := now.Add(time.Second * time.Duration(d.Get("renewal_info_max_sleep").(int)))
-	if canSleepUntil.Before(selectedTime) {
-		return false, nil
+	selectedTime, err := time.Parse(time.RFC3339, selected)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed renewal_info_window_selected: %w", err)
 	}
 
-	return true, nil
+	return selectedTime, nil
 }
 
 func resourceACMECertificateSleepUntilRenewalTime(d *schema.ResourceData) error {
-	if !d.Get("use_renewal_info").(bool) {
+	if !d.Get("use_renewal_info").(bool) || resourceACMECertificateRevoked(d) {
 		return nil
 	}
 
-	var selectedTime time.Time
-	if selected := d.Get("renewal_info_window_selected").(string); selected != "" {
-		var err error
-		selectedTime, err = time.Parse(time.RFC3339, selected)
-		if err != nil {
-			return fmt.Errorf("malformed renewal_info_window_selected: %w", err)
-		}
-	} else {
-		return errors.New("renewal_info_window_selected expected to be set. This is a bug, please report it")
+	selectedTime, err := resourceACMECertificateRenewalInfoSelected(d)
+	if err != nil {
+		return err
 	}
 
 	sleepDuration := time.Until(selectedTime)
@@ -0,0 +1,242 @@
+package acme
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceACMECertificateKeyTypeMaps are the attributes holding the
+// certificates issued for each entry in key_types, keyed by key type.
+var resourceACMECertificateKeyTypeMaps = []string{
+	"certificate_pem_by_key_type",
+	"issuer_pem_by_key_type",
+	"private_key_pem_by_key_type",
+	"certificate_p12_by_key_type",
+	"certificate_url_by_key_type",
+	"certificate_serial_by_key_type",
+	"certificate_not_before_by_key_type",
+	"certificate_not_after_by_key_type",
+}
+
+// resourceACMECertificateKeyTypes returns the key types in key_types. The
+// first key type is the primary key type, which is used for the top-level
+// certificate attributes; the certificates for the rest are issued after the
+// primary certificate, re-using its authorizations.
+func resourceACMECertificateKeyTypes(d resourceDataOrDiff) []string {
+	return stringSlice(d.Get("key_types").([]any))
+}
+
+// resourceACMECertificateAdditionalKeyTypes returns the key types in
+// key_types after the primary key type.
+func resourceACMECertificateAdditionalKeyTypes(d resourceDataOrDiff) []string {
+	keyTypes := resourceACMECertificateKeyTypes(d)
+	if len(keyTypes) < 2 {
+		return nil
+	}
+
+	return keyTypes[1:]
+}
+
+// validateCertificateKeyTypes checks key_types for duplicate entries.
+func validateCertificateKeyTypes(d resourceDataOrDiff) error {
+	seen := make(map[string]bool)
+	for _, kt := range resourceACMECertificateKeyTypes(d) {
+		if seen[kt] {
+			return fmt.Errorf("duplicate key type %q in key_types", kt)
+		}
+
+		seen[kt] = true
+	}
+
+	return nil
+}
+
+// expandCertificateResourcesByKeyType returns the certificates for the
+// additional key types in key_types (all but the first) from state. If old is
+// true, the values from the prior state are used, which is needed during
+// renewal as the new values will be blank.
+func expandCertificateResourcesByKeyType(d *schema.ResourceData, old bool) map[string]*certificate.Resource {
+	get := func(key string) map[string]any {
+		if old {
+			o, _ := d.GetChange(key)
+			return o.(map[string]any)
+		}
+
+		return d.Get(key).(map[string]any)
+	}
+
+	certs := get("certificate_pem_by_key_type")
+	issuers := get("issuer_pem_by_key_type")
+	keys := get("private_key_pem_by_key_type")
+	urls := get("certificate_url_by_key_type")
+
+	result := make(map[string]*certificate.Resource)
+	for _, kt := range resourceACMECertificateAdditionalKeyTypes(d) {
+		certPEM, _ := certs[kt].(string)
+		issuerPEM, _ := issuers[kt].(string)
+		keyPEM, _ := keys[kt].(string)
+		url, _ := urls[kt].(string)
+		result[kt] = &certificate.Resource{
+			Domain:      d.Get("certificate_domain").(string),
+			CertURL:     url,
+			PrivateKey:  []byte(keyPEM),
+			Certificate: []byte(certPEM + issuerPEM),
+		}
+	}
+
+	return result
+}
+
+// resourceACMECertificateGenerateKey generates a new private key of the
+// supplied key type, in PEM format.
+func resourceACMECertificateGenerateKey(keyType string) ([]byte, error) {
+	key, err := certcrypto.GeneratePrivateKey(certcrypto.KeyType(keyType))
+	if err != nil {
+		return nil, fmt.Errorf("error generating %s private key: %w", keyType, err)
+	}
+
+	return certcrypto.PEMEncode(key), nil
+}
+
+// saveCertificateResourcesByKeyType sets the *_by_key_type attributes from
+// the primary certificate and the certificates for the additional key types.
+func saveCertificateResourcesByKeyType(
+	d *schema.ResourceData,
+	primary *certificate.Resource,
+	additional map[string]*certificate.Resource,
+	opts certificateEncodeOptions,
+) error {
+	values := make(map[string]map[string]string)
+	for _, k := range resourceACMECertificateKeyTypeMaps {
+		values[k] = make(map[string]string)
+	}
+
+	for i, kt := range resourceACMECertificateKeyTypes(d) {
+		cert := primary
+		if i > 0 {
+			cert = additional[kt]
+		}
+
+		issued, notBefore, notAfter, serial, issuer, err := splitPEMBundle(cert.Certificate)
+		if err != nil {
+			return fmt.Errorf("error reading %s certificate: %w", kt, err)
+		}
+
+		pfxB64, err := bundleToPKCS12(cert.Certificate, cert.PrivateKey, opts)
+		if err != nil {
+			return fmt.Errorf("error creating %s PKCS#12 bundle: %w", kt, err)
+		}
+
+		values["certificate_pem_by_key_type"][kt] = string(issued)
+		values["issuer_pem_by_key_type"][kt] = string(issuer)
+		values["private_key_pem_by_key_type"][kt] = string(cert.PrivateKey)
+		values["certificate_p12_by_key_type"][kt] = string(pfxB64)
+		values["certificate_url_by_key_type"][kt] = cert.CertURL
+		values["certificate_serial_by_key_type"][kt] = serial
+		values["certificate_not_before_by_key_type"][kt] = notBefore
+		values["certificate_not_after_by_key_type"][kt] = notAfter
+	}
+
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resourceACMECertificateObtainByKeyType obtains the certificates for the
+// additional key types in key_types, after the primary certificate has been
+// issued. As each order is for the same identifiers under the same account,
+// CAs that re-use valid authorizations will not need the challenges solved
+// again. Authorizations are only deactivated after the last order, if
+// deactivate_authorizations is set.
+func resourceACMECertificateObtainByKeyType(
+	d *schema.ResourceData,
+	client *lego.Client,
+	notAfter time.Time,
+) (map[string]*certificate.Resource, error) {
+	keyTypes := resourceACMECertificateAdditionalKeyTypes(d)
+	if len(keyTypes) == 0 {
+		return nil, nil
+	}
+
+	domains, err := resourceACMECertificateDomains(d)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*certificate.Resource)
+	for i, kt := range keyTypes {
+		keyPEM, err := resourceACMECertificateGenerateKey(kt)
+		if err != nil {
+			return nil, err
+		}
+
+		privateKey, err := privateKeyFromPEM(keyPEM)
+		if err != nil {
+			return nil, err
+		}
+
+		cert, err := client.Certificate.Obtain(certificate.ObtainRequest{
+			Domains:                        domains,
+			PrivateKey:                     privateKey,
+			NotAfter:                       notAfter,
+			Bundle:                         true,
+			MustStaple:                     d.Get("must_staple").(bool),
+			PreferredChain:                 d.Get("preferred_chain").(string),
+			Profile:                        d.Get("profile").(string),
+			AlwaysDeactivateAuthorizations: d.Get("deactivate_authorizations").(bool) && i == len(keyTypes)-1,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error creating %s certificate: %w", kt, err)
+		}
+
+		result[kt] = cert
+	}
+
+	return result, nil
+}
+
+// resourceACMECertificateRenewByKeyType renews the certificates for the
+// additional key types in key_types, after the primary certificate has been
+// renewed, so that all of the certificates are always renewed together.
+func resourceACMECertificateRenewByKeyType(
+	d *schema.ResourceData,
+	client *lego.Client,
+	options localRenewOptions,
+	rotateKey bool,
+) (map[string]*certificate.Resource, error) {
+	keyTypes := resourceACMECertificateAdditionalKeyTypes(d)
+	certs := expandCertificateResourcesByKeyType(d, true)
+	deactivate := options.AlwaysDeactivateAuthorizations
+	result := make(map[string]*certificate.Resource)
+	for i, kt := range keyTypes {
+		cert := certs[kt]
+		if rotateKey || len(cert.PrivateKey) == 0 {
+			// Generate the key here, as lego would otherwise generate a key of the
+			// primary key type.
+			var err error
+			cert.PrivateKey, err = resourceACMECertificateGenerateKey(kt)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		options.AlwaysDeactivateAuthorizations = deactivate && i == len(keyTypes)-1
+		newCert, err := renewWithOptions(client.Certificate, *cert, options)
+		if err != nil {
+			return nil, fmt.Errorf("error renewing %s certificate: %w", kt, err)
+		}
+
+		result[kt] = newCert
+	}
+
+	return result, nil
+}
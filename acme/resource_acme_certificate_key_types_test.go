@@ -0,0 +1,63 @@
+package acme
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestResourceACMECertificateKeyTypes(t *testing.T) {
+	testCases := []struct {
+		name               string
+		keyTypes           []any
+		expectedAdditional []string
+		expectedErr        bool
+	}{
+		{
+			name:               "not set",
+			expectedAdditional: nil,
+		},
+		{
+			name:               "single",
+			keyTypes:           []any{"P256"},
+			expectedAdditional: nil,
+		},
+		{
+			name:               "dual",
+			keyTypes:           []any{"P256", "2048"},
+			expectedAdditional: []string{"2048"},
+		},
+		{
+			name:        "duplicate",
+			keyTypes:    []any{"P256", "2048", "P256"},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]any{}
+			if tc.keyTypes != nil {
+				raw["key_types"] = tc.keyTypes
+			}
+
+			d := schema.TestResourceDataRaw(t, resourceACMECertificate().Schema, raw)
+			err := validateCertificateKeyTypes(d)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatal("expected error, got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := resourceACMECertificateAdditionalKeyTypes(d)
+			if !reflect.DeepEqual(tc.expectedAdditional, actual) {
+				t.Fatalf("expected additional key types to be %#v, got %#v", tc.expectedAdditional, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+//go:build cgo
+
+package acme
+
+import (
+	"crypto"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/miekg/pkcs11"
+)
+
+// The token and key created in SoftHSM for the PKCS#11 acceptance test.
+const (
+	testAccSoftHSMTokenLabel = "acme"
+	testAccSoftHSMKeyLabel   = "www37"
+	testAccSoftHSMAccountKey = "account"
+	testAccSoftHSMSOPIN      = "5678"
+	testAccSoftHSMPIN        = "1234"
+)
+
+func TestAccACMECertificate_pkcs11(t *testing.T) {
+	testAccACMECertificate_pkcs11_preCheck(t)
+
+	module := os.Getenv("ACME_SOFTHSM2_MODULE")
+	pub := testAccSoftHSMCreateKey(t, module, testAccSoftHSMKeyLabel)
+
+	wantEnv := os.Environ()
+	var certURL string
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		CheckDestroy:      testAccCheckACMECertificateStatus("acme_certificate.certificate", certificateStatusRevoked),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigPKCS11(module, "1"),
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						certURL = s.RootModule().Resources["acme_certificate.certificate"].Primary.Attributes["certificate_url"]
+						return nil
+					},
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "id", uuidRegexp),
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "certificate_url", certURLRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www37", ""),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "private_key_pem", ""),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "certificate_p12", ""),
+					testAccCheckACMECertificatePKCS11PublicKey("acme_certificate.certificate", pub),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				// Renewal signs a new CSR with the same key in the token.
+				Config: testAccACMECertificateConfigPKCS11(module, "2"),
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						if certURL == s.RootModule().Resources["acme_certificate.certificate"].Primary.Attributes["certificate_url"] {
+							return errors.New("certificate URL did not change")
+						}
+
+						return nil
+					},
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www37", ""),
+					testAccCheckACMECertificatePKCS11PublicKey("acme_certificate.certificate", pub),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
+func TestAccACMECertificate_pkcs11AccountKey(t *testing.T) {
+	testAccACMECertificate_pkcs11_preCheck(t)
+
+	module := os.Getenv("ACME_SOFTHSM2_MODULE")
+	testAccSoftHSMCreateKey(t, module, testAccSoftHSMAccountKey)
+
+	wantEnv := os.Environ()
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		CheckDestroy:      testAccCheckACMECertificateStatus("acme_certificate.certificate", certificateStatusRevoked),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigPKCS11AccountKey(module),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMERegistrationValid("acme_registration.reg", true, pebbleDirBasic),
+					resource.TestCheckResourceAttr("acme_registration.reg", "account_key_pem", ""),
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "certificate_url", certURLRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www38", ""),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
+func testAccACMECertificate_pkcs11_preCheck(t *testing.T) {
+	t.Helper()
+
+	if os.Getenv("ACME_SOFTHSM2_MODULE") == "" {
+		t.Skip("ACME_SOFTHSM2_MODULE must be set to the path of the SoftHSMv2 PKCS#11 module for the PKCS#11 acceptance test")
+	}
+}
+
+// testAccSoftHSMCreateKey creates a SoftHSM token in a temporary directory,
+// and generates an ECDSA P-256 key with the given label in it, returning the
+// public key. The SOFTHSM2_CONF environment variable is set for the rest of
+// the test so that the provider uses the token, and the signers opened by the
+// provider are closed at the end of the test, so that the next test can
+// initialize the module with its own token.
+func testAccSoftHSMCreateKey(t *testing.T, module, label string) crypto.PublicKey {
+	t.Helper()
+	t.Cleanup(testAccClosePKCS11Signers)
+
+	dir := t.TempDir()
+	tokenDir := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokenDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := filepath.Join(dir, "softhsm2.conf")
+	testWriteFile(t, conf, []byte(fmt.Sprintf("directories.tokendir = %s\nobjectstore.backend = file\n", tokenDir)))
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		t.Fatalf("error loading PKCS#11 module %q", module)
+	}
+	defer ctx.Destroy()
+
+	if err := ctx.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Finalize()
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(slots) == 0 {
+		t.Fatal("no SoftHSM slots found")
+	}
+
+	if err := ctx.InitToken(slots[0], testAccSoftHSMSOPIN, testAccSoftHSMTokenLabel); err != nil {
+		t.Fatal(err)
+	}
+
+	// SoftHSM moves the token to a new slot once it is initialized.
+	slot, err := testAccSoftHSMFindSlot(ctx, testAccSoftHSMTokenLabel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.CloseSession(session)
+
+	if err := ctx.Login(session, pkcs11.CKU_SO, testAccSoftHSMSOPIN); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.InitPIN(session, testAccSoftHSMPIN); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.Logout(session); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.Login(session, pkcs11.CKU_USER, testAccSoftHSMPIN); err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Logout(session)
+
+	params, err := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := []byte{0x01}
+	pubKey, _, err := ctx.GenerateKeyPair(
+		session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attrs, err := ctx.GetAttributeValue(session, pubKey, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := pkcs11ECPublicKey(attrs[0].Value, attrs[1].Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pub
+}
+
+// testAccClosePKCS11Signers closes the signers cached by pkcs11SignerForURI.
+func testAccClosePKCS11Signers() {
+	pkcs11SignersMutex.Lock()
+	defer pkcs11SignersMutex.Unlock()
+
+	for uri, signer := range pkcs11Signers {
+		signer.Close()
+		delete(pkcs11Signers, uri)
+	}
+}
+
+func testAccSoftHSMFindSlot(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, err
+		}
+
+		if strings.TrimRight(info.Label, " \x00") == label {
+			return slot, nil
+		}
+	}
+
+	return 0, fmt.Errorf("SoftHSM token %q not found", label)
+}
+
+func testAccCheckACMECertificatePKCS11PublicKey(name string, pub crypto.PublicKey) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("Can't find ACME certificate: %s", name)
+		}
+
+		certs, err := parsePEMBundle([]byte(rs.Primary.Attributes["certificate_pem"]))
+		if err != nil {
+			return err
+		}
+
+		if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(certs[0].PublicKey) {
+			return fmt.Errorf("public key for cert and PKCS#11 key %s don't match", testAccSoftHSMKeyLabel)
+		}
+
+		return nil
+	}
+}
+
+func testAccACMECertificateConfigPKCS11(module, trigger string) string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem = "${acme_registration.reg.account_key_pem}"
+  common_name     = "www37.${var.domain}"
+  key_pkcs11_uri  = "pkcs11:token=%s;object=%s?module-path=%s&pin-value=%s"
+
+  renewal_triggers = {
+    rotation = "%s"
+  }
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		testAccSoftHSMTokenLabel,
+		testAccSoftHSMKeyLabel,
+		module,
+		testAccSoftHSMPIN,
+		trigger,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateConfigPKCS11AccountKey(module string) string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address          = "${var.email_address}"
+  account_key_pkcs11_uri = "pkcs11:token=%s;object=%s?module-path=%s&pin-value=%s"
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pkcs11_uri = "${acme_registration.reg.account_key_pkcs11_uri}"
+  common_name            = "www38.${var.domain}"
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		testAccSoftHSMTokenLabel,
+		testAccSoftHSMAccountKey,
+		module,
+		testAccSoftHSMPIN,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
@@ -0,0 +1,856 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceACMECertificateSetSharedKeys are the arguments of acme_certificate
+// that are also used by acme_certificate_set, with the same behavior. These
+// cover the ACME client and the challenge configuration, so that the client
+// and challenge helpers can be shared between the two resources.
+var resourceACMECertificateSetSharedKeys = []string{
+	"account_key_pem",
+	"account_key_pkcs11_uri",
+	"key_type",
+	"min_days_remaining",
+	"dns_challenge",
+	"http_challenge",
+	"http_webroot_challenge",
+	"http_memcached_challenge",
+	"http_s3_challenge",
+	"tls_challenge",
+	"pre_check_delay",
+	"recursive_nameservers",
+	"disable_complete_propagation",
+	"propagation_wait",
+	"must_staple",
+	"preferred_chain",
+	"profile",
+	"cert_timeout",
+	"deactivate_authorizations",
+	"revoke_certificate_on_destroy",
+	"revoke_certificate_reason",
+}
+
+// unknownVariableValue is the value that marks an element of a map as unknown
+// in a plan. It matches the SDK's internal hcl2shim.UnknownVariableValue, which
+// can't be imported.
+const unknownVariableValue = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+// resourceACMECertificateSetCertificateMaps are the attributes holding the
+// certificates issued for each shard, keyed by shard ID.
+var resourceACMECertificateSetCertificateMaps = []string{
+	"certificate_pem",
+	"issuer_pem",
+	"private_key_pem",
+	"certificate_url",
+	"certificate_serial",
+	"certificate_not_after",
+	"failed_domains",
+}
+
+func resourceACMECertificateSet() *schema.Resource {
+	s := map[string]*schema.Schema{
+		"domains": {
+			Type:     schema.TypeSet,
+			Required: true,
+			MinItems: 1,
+			Elem: &schema.Schema{
+				Type:             schema.TypeString,
+				ValidateFunc:     validateDomain,
+				DiffSuppressFunc: suppressEquivalentDomainDiff,
+			},
+			Set: hashDomain,
+		},
+		"max_sans_per_certificate": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      100,
+			ForceNew:     true,
+			ValidateFunc: validation.IntAtLeast(1),
+		},
+		"domain_shards": {
+			Type:     schema.TypeMap,
+			Computed: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"certificate_pem": {
+			Type:     schema.TypeMap,
+			Computed: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"issuer_pem": {
+			Type:     schema.TypeMap,
+			Computed: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"private_key_pem": {
+			Type:      schema.TypeMap,
+			Computed:  true,
+			Sensitive: true,
+			Elem:      &schema.Schema{Type: schema.TypeString},
+		},
+		"certificate_url": {
+			Type:     schema.TypeMap,
+			Computed: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"certificate_serial": {
+			Type:     schema.TypeMap,
+			Computed: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"certificate_not_after": {
+			Type:     schema.TypeMap,
+			Computed: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"failed_domains": {
+			Type:     schema.TypeMap,
+			Computed: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+	}
+
+	certSchema := resourceACMECertificateV5().Schema
+	for _, k := range resourceACMECertificateSetSharedKeys {
+		s[k] = resourceACMECertificateSetSharedSchema(certSchema[k])
+	}
+
+	// acme_certificate re-selects the chain in place when preferred_chain
+	// changes, but the set does not track alternate chains, so the change
+	// replaces the certificates instead.
+	s["preferred_chain"].ForceNew = true
+
+	return &schema.Resource{
+		CreateContext: resourceACMECertificateSetCreate,
+		ReadContext:   resourceACMECertificateSetRead,
+		CustomizeDiff: resourceACMECertificateSetCustomizeDiff,
+		UpdateContext: resourceACMECertificateSetUpdate,
+		Delete:        resourceACMECertificateSetDelete,
+		Schema:        s,
+	}
+}
+
+// resourceACMECertificateSetSharedSchema returns a copy of an acme_certificate
+// attribute for acme_certificate_set, without references to attributes that
+// only exist in acme_certificate.
+func resourceACMECertificateSetSharedSchema(src *schema.Schema) *schema.Schema {
+	filter := func(keys []string) []string {
+		var result []string
+		for _, k := range keys {
+			if slices.Contains(resourceACMECertificateSetSharedKeys, k) {
+				result = append(result, k)
+			}
+		}
+
+		return result
+	}
+
+	s := *src
+	s.ConflictsWith = filter(s.ConflictsWith)
+	s.AtLeastOneOf = filter(s.AtLeastOneOf)
+	s.ExactlyOneOf = filter(s.ExactlyOneOf)
+	s.RequiredWith = filter(s.RequiredWith)
+	return &s
+}
+
+func resourceACMECertificateSetCreate(ctx context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	resourceUUID, err := uuid.GenerateUUID()
+	if err != nil {
+		return diag.Errorf("error generating UUID for resource: %s", err)
+	}
+
+	diags := resourceACMECertificateSetApply(d, meta)
+	if diags.HasError() {
+		return diags
+	}
+
+	// Only keep the resource if at least one shard has a certificate, so that a
+	// set that failed entirely can be corrected without being tainted.
+	if len(d.Get("certificate_pem").(map[string]any)) == 0 {
+		return diag.Errorf("error creating certificates: %s", resourceACMECertificateSetFailures(d))
+	}
+
+	d.SetId(resourceUUID)
+	return append(diags, resourceACMECertificateSetRead(ctx, d, meta)...)
+}
+
+// resourceACMECertificateSetRead refreshes the certificates for each shard.
+// A certificate that is missing from state is recovered from the CA using its
+// URL. If it can't be recovered, or can't be parsed, it is dropped from state,
+// so that the plan reissues the shard, and a warning is returned. Renewal of
+// the certificates is checked in CustomizeDiff.
+func resourceACMECertificateSetRead(_ context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	var diags diag.Diagnostics
+	certs := expandCertificateSetResources(d, false)
+	pems := stringMap(d.Get("certificate_pem").(map[string]any))
+	keys := stringMap(d.Get("private_key_pem").(map[string]any))
+	var client *lego.Client
+	for id, url := range stringMap(d.Get("certificate_url").(map[string]any)) {
+		if pems[id] != "" || url == "" {
+			continue
+		}
+
+		if client == nil {
+			var err error
+			client, _, err = expandACMEClient(d, meta, true)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+		}
+
+		src, err := client.Certificate.Get(url, true)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Certificate for shard %s could not be recovered", id),
+				Detail:   fmt.Sprintf("The certificate is missing from state, and could not be fetched from the CA: %s", err),
+			})
+			continue
+		}
+
+		certs[id] = &certificate.Resource{
+			CertURL:     url,
+			PrivateKey:  []byte(keys[id]),
+			Certificate: src.Certificate,
+		}
+	}
+
+	var dropped []string
+	for id, cert := range certs {
+		if _, _, _, _, _, err := splitPEMBundle(cert.Certificate); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Certificate for shard %s dropped", id),
+				Detail:   fmt.Sprintf("The certificate is missing or could not be read, and will be issued again on the next apply: %s", err),
+			})
+			dropped = append(dropped, id)
+		}
+	}
+
+	shards := stringMap(d.Get("domain_shards").(map[string]any))
+	failures := stringMap(d.Get("failed_domains").(map[string]any))
+	if err := saveCertificateSetResources(d, shards, certs, dropped, failures); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	return diags
+}
+
+// resourceACMECertificateSetCustomizeDiff computes the shard for each domain,
+// and plans the certificates of the shards that need a certificate to be
+// issued or dropped (see resourceACMECertificateSetPlanShards).
+func resourceACMECertificateSetCustomizeDiff(_ context.Context, d *schema.ResourceDiff, meta any) error {
+	if !d.NewValueKnown("domains") {
+		d.SetNewComputed("domain_shards")
+		for _, k := range resourceACMECertificateSetCertificateMaps {
+			d.SetNewComputed(k)
+		}
+
+		return nil
+	}
+
+	domains, err := resourceACMECertificateSetDomainNames(d)
+	if err != nil {
+		return err
+	}
+
+	prior, _ := d.GetChange("domain_shards")
+	shards := assignCertificateShards(stringMap(prior.(map[string]any)), domains, d.Get("max_sans_per_certificate").(int))
+	if err := d.SetNew("domain_shards", shards); err != nil {
+		return err
+	}
+
+	// Everything is computed on create.
+	if d.Id() == "" {
+		return nil
+	}
+
+	work, err := resourceACMECertificateSetPendingShards(d, shards, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if len(work) > 0 {
+		return resourceACMECertificateSetPlanShards(d, shards, work)
+	}
+
+	return nil
+}
+
+// resourceACMECertificateSetPlanShards marks the certificates of the shards
+// in work as unknown in the plan, along with the failures for their domains,
+// and removes the certificates of the shards that are dropped. The
+// certificates of the other shards are left as they are.
+func resourceACMECertificateSetPlanShards(d *schema.ResourceDiff, shards map[string]string, work map[string][]string) error {
+	for _, k := range resourceACMECertificateSetCertificateMaps {
+		old, _ := d.GetChange(k)
+		values := stringMap(old.(map[string]any))
+		if k == "failed_domains" {
+			for domain := range values {
+				if _, ok := shards[domain]; !ok {
+					delete(values, domain)
+				}
+			}
+
+			for _, domains := range work {
+				for _, domain := range domains {
+					values[domain] = unknownVariableValue
+				}
+			}
+		} else {
+			for id, domains := range work {
+				if len(domains) == 0 {
+					delete(values, id)
+				} else {
+					values[id] = unknownVariableValue
+				}
+			}
+		}
+
+		if err := d.SetNew(k, values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func resourceACMECertificateSetUpdate(_ context.Context, d *schema.ResourceData, meta any) diag.Diagnostics {
+	return resourceACMECertificateSetApply(d, meta)
+}
+
+// resourceACMECertificateSetDelete "deletes" the certificate set by revoking
+// the certificates for all shards.
+func resourceACMECertificateSetDelete(d *schema.ResourceData, meta any) error {
+	if !d.Get("revoke_certificate_on_destroy").(bool) {
+		return nil
+	}
+
+	client, _, err := expandACMEClient(d, meta, true)
+	if err != nil {
+		return err
+	}
+
+	certs := expandCertificateSetResources(d, false)
+	for _, id := range sortedShardIDs(certs) {
+		if err := resourceACMECertificateSetRevoke(d, client, id, certs[id]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resourceACMECertificateSetApply issues certificates for all shards that need
+// them and drops the certificates for shards that no longer have any domains.
+//
+// Each shard is issued independently. If the order for a shard fails because
+// of particular domains, the shard is issued again without them; the failed
+// domains are recorded in failed_domains and retried on the next apply. If the
+// domains can't be identified from the error, the whole shard is recorded as
+// failed and keeps its existing certificate. The other shards are not
+// affected either way, and the failures are returned as warnings.
+func resourceACMECertificateSetApply(d *schema.ResourceData, meta any) diag.Diagnostics {
+	domains, err := resourceACMECertificateSetDomainNames(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// domain_shards is known at plan time, unless the domains were not.
+	shards := stringMap(d.Get("domain_shards").(map[string]any))
+	if len(shards) == 0 {
+		prior, _ := d.GetChange("domain_shards")
+		shards = assignCertificateShards(stringMap(prior.(map[string]any)), domains, d.Get("max_sans_per_certificate").(int))
+	}
+
+	now := time.Now()
+	work, err := resourceACMECertificateSetPendingShards(d, shards, now)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var diags diag.Diagnostics
+	certs := expandCertificateSetResources(d, true)
+
+	// Keep the failures for the shards that are not reissued, so that they are
+	// not reissued on the next apply.
+	failures := make(map[string]string)
+	oldFailures, _ := d.GetChange("failed_domains")
+	for domain, reason := range stringMap(oldFailures.(map[string]any)) {
+		if _, ok := work[shards[domain]]; !ok {
+			failures[domain] = reason
+		}
+	}
+
+	if len(work) > 0 {
+		client, _, err := expandACMEClient(d, meta, true)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		dnsCloser, err := setCertificateChallengeProviders(client, d)
+		defer dnsCloser()
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		for _, id := range sortedShardIDs(work) {
+			shardDomains := work[id]
+			if len(shardDomains) == 0 {
+				continue
+			}
+
+			cert, failed, err := resourceACMECertificateSetObtain(d, client, certs[id], shardDomains)
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Warning,
+					Summary:  fmt.Sprintf("Certificate for shard %s could not be issued", id),
+					Detail:   err.Error(),
+				})
+			}
+
+			for domain, reason := range failed {
+				failures[domain] = reason
+			}
+
+			if cert != nil {
+				certs[id] = cert
+			}
+		}
+	}
+
+	// Drop the certificates for shards that have no domains left.
+	var dropped []string
+	for id := range certs {
+		if _, ok := work[id]; ok && len(work[id]) == 0 {
+			dropped = append(dropped, id)
+		}
+	}
+
+	if err := saveCertificateSetResources(d, shards, certs, dropped, failures); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if len(failures) > 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Certificates could not be issued for some domains",
+			Detail:   "The domains are retried on the next apply that reissues their shards:" + resourceACMECertificateSetFailures(d),
+		})
+	}
+
+	if len(dropped) == 0 || !d.Get("revoke_certificate_on_destroy").(bool) {
+		return diags
+	}
+
+	client, _, err := expandACMEClient(d, meta, true)
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	sort.Strings(dropped)
+	for _, id := range dropped {
+		if err := resourceACMECertificateSetRevoke(d, client, id, certs[id]); err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+	}
+
+	return diags
+}
+
+// resourceACMECertificateSetObtain obtains the certificate for a shard, re-using
+// the private key of the shard's current certificate if there is one. If the
+// order fails because of particular domains, it is retried once without them.
+// The failed domains are returned along with the reason for the failure.
+func resourceACMECertificateSetObtain(
+	d *schema.ResourceData,
+	client *lego.Client,
+	current *certificate.Resource,
+	domains []string,
+) (*certificate.Resource, map[string]string, error) {
+	request := certificate.ObtainRequest{
+		Domains:                        domains,
+		Bundle:                         true,
+		MustStaple:                     d.Get("must_staple").(bool),
+		PreferredChain:                 d.Get("preferred_chain").(string),
+		Profile:                        d.Get("profile").(string),
+		AlwaysDeactivateAuthorizations: d.Get("deactivate_authorizations").(bool),
+	}
+
+	if current != nil && len(current.PrivateKey) > 0 {
+		key, err := privateKeyFromPEM(current.PrivateKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		request.PrivateKey = key
+	}
+
+	failed := make(map[string]string)
+	cert, err := client.Certificate.Obtain(request)
+	if err != nil {
+		bad := certificateSetFailedDomains(err, domains)
+		var good []string
+		for _, domain := range domains {
+			if _, ok := bad[domain]; !ok {
+				good = append(good, domain)
+			}
+		}
+
+		if len(bad) == 0 || len(good) == 0 {
+			for _, domain := range domains {
+				failed[domain] = err.Error()
+			}
+
+			return nil, failed, err
+		}
+
+		for domain, reason := range bad {
+			failed[domain] = reason
+		}
+
+		request.Domains = good
+		cert, err = client.Certificate.Obtain(request)
+		if err != nil {
+			for _, domain := range good {
+				failed[domain] = err.Error()
+			}
+
+			return nil, failed, err
+		}
+	}
+
+	return cert, failed, nil
+}
+
+// certificateSetFailedDomains returns the domains in domains that an error
+// from lego's Obtain can be attributed to, along with the reason for each.
+// These are either the identifiers of the subproblems of an ACME problem, or
+// the domains from a failure to authorize some of the domains in the order.
+func certificateSetFailedDomains(err error, domains []string) map[string]string {
+	result := make(map[string]string)
+
+	var problem *acme.ProblemDetails
+	if errors.As(err, &problem) {
+		for _, sub := range problem.SubProblems {
+			if slices.Contains(domains, sub.Identifier.Value) {
+				result[sub.Identifier.Value] = sub.Detail
+			}
+		}
+
+		if len(result) > 0 {
+			return result
+		}
+	}
+
+	// Authorization failures are reported one domain per line, in the form
+	// "<domain>: <error>".
+	for _, line := range strings.Split(err.Error(), "\n") {
+		for _, domain := range domains {
+			if reason, ok := strings.CutPrefix(line, domain+": "); ok {
+				result[domain] = reason
+			}
+		}
+	}
+
+	return result
+}
+
+// resourceACMECertificateSetPendingShards returns the shards that need a
+// certificate to be issued, along with their domains. A shard needs a
+// certificate if it does not have one, if its certificate does not cover the
+// domains assigned to it (see certificateSetShardCovered), or if its
+// certificate has reached min_days_remaining before expiry, compared in the
+// same way as for acme_certificate. Shards that have a
+// certificate in state but no domains left are returned with no domains.
+func resourceACMECertificateSetPendingShards(
+	d resourceDataOrDiff,
+	shards map[string]string,
+	now time.Time,
+) (map[string][]string, error) {
+	byShard := make(map[string][]string)
+	for domain, id := range shards {
+		byShard[id] = append(byShard[id], domain)
+	}
+
+	certs := expandCertificateSetResources(d, true)
+	failed, _ := d.GetChange("failed_domains")
+	result := make(map[string][]string)
+	for id, domains := range byShard {
+		sort.Strings(domains)
+		cert, ok := certs[id]
+		if !ok {
+			result[id] = domains
+			continue
+		}
+
+		certs, err := parsePEMBundle(cert.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("error reading certificate for shard %s: %w", id, err)
+		}
+
+		if !certificateSetShardCovered(certcrypto.ExtractDomains(certs[0]), domains, stringMap(failed.(map[string]any))) {
+			result[id] = domains
+			continue
+		}
+
+		remaining, err := certSecondsRemaining(cert, now)
+		if err != nil {
+			return nil, fmt.Errorf("error reading certificate for shard %s: %w", id, err)
+		}
+
+		// A negative min_days_remaining never renews the certificates.
+		mindays := d.Get("min_days_remaining").(int)
+		expiry := time.Unix(now.Unix()+remaining, 0)
+		if mindays >= 0 && !now.Before(expiry.Add(-time.Duration(mindays)*24*time.Hour)) {
+			result[id] = domains
+		}
+	}
+
+	for id := range certs {
+		if _, ok := byShard[id]; !ok {
+			result[id] = nil
+		}
+	}
+
+	return result, nil
+}
+
+// certificateSetShardCovered returns true if a shard's certificate, issued for
+// the domains in issued, covers the domains assigned to the shard. The
+// certificate must not have any domains that are no longer assigned to the
+// shard, and must have all of the assigned domains, except for those in
+// failed_domains. A domain that keeps failing therefore doesn't cause the
+// shard to be reissued on every apply; it is retried when the shard's
+// domains change, or when the shard is renewed.
+func certificateSetShardCovered(issued, assigned []string, failed map[string]string) bool {
+	for _, domain := range issued {
+		if !slices.Contains(assigned, domain) {
+			return false
+		}
+	}
+
+	for _, domain := range assigned {
+		if _, ok := failed[domain]; !ok && !slices.Contains(issued, domain) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// assignCertificateShards assigns each domain to a shard, keyed by domain.
+// Shards are identified by integers from 0, as strings. Domains keep the shard
+// they were assigned to before, so that adding or removing a domain only
+// changes the certificate for one shard. New domains are assigned in sorted
+// order to the lowest shard that has fewer than maxSANs domains.
+func assignCertificateShards(prior map[string]string, domains []string, maxSANs int) map[string]string {
+	result := make(map[string]string)
+	counts := make(map[string]int)
+	var added []string
+	for _, domain := range domains {
+		if id, ok := prior[domain]; ok {
+			result[domain] = id
+			counts[id]++
+		} else {
+			added = append(added, domain)
+		}
+	}
+
+	sort.Strings(added)
+	id := 0
+	for _, domain := range added {
+		for counts[strconv.Itoa(id)] >= maxSANs {
+			id++
+		}
+
+		result[domain] = strconv.Itoa(id)
+		counts[strconv.Itoa(id)]++
+	}
+
+	return result
+}
+
+// resourceACMECertificateSetDomainNames returns the normalized domains in
+// domains, without duplicates.
+func resourceACMECertificateSetDomainNames(d resourceDataOrDiff) ([]string, error) {
+	var domains []string
+	for _, v := range stringSlice(d.Get("domains").(*schema.Set).List()) {
+		domain, err := normalizeDomain(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+
+	sort.Strings(domains)
+	return domains, nil
+}
+
+// expandCertificateSetResources returns the certificates for each shard from
+// state. If old is true, the values from the prior state are used, which is
+// needed when the certificates have been flagged as NewComputed.
+func expandCertificateSetResources(d resourceDataOrDiff, old bool) map[string]*certificate.Resource {
+	get := func(key string) map[string]any {
+		if old {
+			o, _ := d.GetChange(key)
+			return o.(map[string]any)
+		}
+
+		return d.Get(key).(map[string]any)
+	}
+
+	certs := get("certificate_pem")
+	issuers := get("issuer_pem")
+	keys := get("private_key_pem")
+	urls := get("certificate_url")
+
+	result := make(map[string]*certificate.Resource)
+	for id, v := range certs {
+		certPEM, _ := v.(string)
+		issuerPEM, _ := issuers[id].(string)
+		keyPEM, _ := keys[id].(string)
+		url, _ := urls[id].(string)
+		result[id] = &certificate.Resource{
+			CertURL:     url,
+			PrivateKey:  []byte(keyPEM),
+			Certificate: []byte(certPEM + issuerPEM),
+		}
+	}
+
+	return result
+}
+
+// saveCertificateSetResources sets the certificate attributes for each shard,
+// leaving out the dropped shards, and failed_domains. Failures are only kept
+// for domains that are still assigned to a shard.
+func saveCertificateSetResources(
+	d *schema.ResourceData,
+	shards map[string]string,
+	certs map[string]*certificate.Resource,
+	dropped []string,
+	failures map[string]string,
+) error {
+	values := make(map[string]map[string]string)
+	for _, k := range resourceACMECertificateSetCertificateMaps {
+		values[k] = make(map[string]string)
+	}
+
+	for id, cert := range certs {
+		if slices.Contains(dropped, id) {
+			continue
+		}
+
+		issued, _, notAfter, serial, issuer, err := splitPEMBundle(cert.Certificate)
+		if err != nil {
+			return fmt.Errorf("error reading certificate for shard %s: %w", id, err)
+		}
+
+		values["certificate_pem"][id] = string(issued)
+		values["issuer_pem"][id] = string(issuer)
+		values["private_key_pem"][id] = string(cert.PrivateKey)
+		values["certificate_url"][id] = cert.CertURL
+		values["certificate_serial"][id] = serial
+		values["certificate_not_after"][id] = notAfter
+	}
+
+	for domain, reason := range failures {
+		if _, ok := shards[domain]; ok {
+			values["failed_domains"][domain] = reason
+		}
+	}
+
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			return err
+		}
+	}
+
+	return d.Set("domain_shards", shards)
+}
+
+// resourceACMECertificateSetRevoke revokes the certificate for a shard, if it
+// has not expired yet.
+func resourceACMECertificateSetRevoke(d *schema.ResourceData, client *lego.Client, id string, cert *certificate.Resource) error {
+	remaining, err := certSecondsRemaining(cert, time.Now())
+	if err != nil {
+		return fmt.Errorf("error reading certificate for shard %s: %w", id, err)
+	}
+
+	if remaining < 0 {
+		return nil
+	}
+
+	if err := resourceACMECertificateRevoke(d, client, cert); err != nil {
+		return fmt.Errorf("error revoking certificate for shard %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// resourceACMECertificateSetFailures returns failed_domains as a string for
+// error and log messages.
+func resourceACMECertificateSetFailures(d *schema.ResourceData) string {
+	failures := stringMap(d.Get("failed_domains").(map[string]any))
+	domains := make([]string, 0, len(failures))
+	for domain := range failures {
+		domains = append(domains, domain)
+	}
+
+	sort.Strings(domains)
+	var b strings.Builder
+	for _, domain := range domains {
+		fmt.Fprintf(&b, "\n%s: %s", domain, failures[domain])
+	}
+
+	return b.String()
+}
+
+// sortedShardIDs returns the keys of a map keyed by shard ID, in numeric
+// order.
+func sortedShardIDs[V any](m map[string]V) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+
+	return ids
+}
+
+// stringMap converts a map[string]any from a TypeMap attribute into a
+// map[string]string.
+func stringMap(src map[string]any) map[string]string {
+	result := make(map[string]string, len(src))
+	for k, v := range src {
+		result[k] = v.(string)
+	}
+
+	return result
+}
@@ -0,0 +1,519 @@
+package acme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+const certificateSetResourceName = "acme_certificate_set.certificates"
+
+func TestAssignCertificateShards(t *testing.T) {
+	testCases := []struct {
+		name     string
+		prior    map[string]string
+		domains  []string
+		maxSANs  int
+		expected map[string]string
+	}{
+		{
+			name:    "create",
+			domains: []string{"d.example.com", "a.example.com", "c.example.com", "b.example.com", "e.example.com"},
+			maxSANs: 2,
+			expected: map[string]string{
+				"a.example.com": "0",
+				"b.example.com": "0",
+				"c.example.com": "1",
+				"d.example.com": "1",
+				"e.example.com": "2",
+			},
+		},
+		{
+			name: "add domain",
+			prior: map[string]string{
+				"a.example.com": "0",
+				"b.example.com": "0",
+				"c.example.com": "1",
+			},
+			domains: []string{"0.example.com", "a.example.com", "b.example.com", "c.example.com"},
+			maxSANs: 2,
+			expected: map[string]string{
+				"0.example.com": "1",
+				"a.example.com": "0",
+				"b.example.com": "0",
+				"c.example.com": "1",
+			},
+		},
+		{
+			name: "remove domain",
+			prior: map[string]string{
+				"a.example.com": "0",
+				"b.example.com": "0",
+				"c.example.com": "1",
+			},
+			domains: []string{"b.example.com", "c.example.com"},
+			maxSANs: 2,
+			expected: map[string]string{
+				"b.example.com": "0",
+				"c.example.com": "1",
+			},
+		},
+		{
+			name: "fill emptied shard",
+			prior: map[string]string{
+				"c.example.com": "1",
+				"d.example.com": "1",
+			},
+			domains: []string{"c.example.com", "d.example.com", "z.example.com"},
+			maxSANs: 2,
+			expected: map[string]string{
+				"c.example.com": "1",
+				"d.example.com": "1",
+				"z.example.com": "0",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := assignCertificateShards(tc.prior, tc.domains, tc.maxSANs)
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCertificateSetFailedDomains(t *testing.T) {
+	domains := []string{"a.example.com", "b.example.com", "c.example.com"}
+	testCases := []struct {
+		name     string
+		err      error
+		expected map[string]string
+	}{
+		{
+			name: "authorization failures",
+			err: fmt.Errorf(
+				"error: one or more domains had a problem:\n%w",
+				errors.Join(
+					errors.New("b.example.com: acme: error: 400 :: urn:ietf:params:acme:error:dns :: no such host"),
+					errors.New("c.example.com: acme: error: 403 :: urn:ietf:params:acme:error:unauthorized :: invalid response"),
+				),
+			),
+			expected: map[string]string{
+				"b.example.com": "acme: error: 400 :: urn:ietf:params:acme:error:dns :: no such host",
+				"c.example.com": "acme: error: 403 :: urn:ietf:params:acme:error:unauthorized :: invalid response",
+			},
+		},
+		{
+			name: "rejected identifier",
+			err: fmt.Errorf("acme: error creating order: %w", &acme.ProblemDetails{
+				Type:   "urn:ietf:params:acme:error:rejectedIdentifier",
+				Detail: "order contains forbidden identifiers",
+				SubProblems: []acme.SubProblem{
+					{
+						Type:       "urn:ietf:params:acme:error:rejectedIdentifier",
+						Detail:     "forbidden by policy",
+						Identifier: acme.Identifier{Type: "dns", Value: "a.example.com"},
+					},
+				},
+			}),
+			expected: map[string]string{
+				"a.example.com": "forbidden by policy",
+			},
+		},
+		{
+			name:     "unknown",
+			err:      errors.New("acme: error: 429 :: too many requests"),
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := certificateSetFailedDomains(tc.err, domains)
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResourceACMECertificateSetPendingShards(t *testing.T) {
+	now := time.Now()
+	b := testGenerateCertificateBundle(t, now.Add(-time.Hour), now.Add(90*24*time.Hour), []string{"a.example.com", "b.example.com"}, nil)
+
+	testCases := []struct {
+		name     string
+		shards   map[string]string
+		failed   map[string]string
+		minDays  string
+		expected map[string][]string
+	}{
+		{
+			name:     "covered",
+			shards:   map[string]string{"a.example.com": "0", "b.example.com": "0"},
+			expected: map[string][]string{},
+		},
+		{
+			name:   "added domain",
+			shards: map[string]string{"a.example.com": "0", "b.example.com": "0", "c.example.com": "0"},
+			expected: map[string][]string{
+				"0": {"a.example.com", "b.example.com", "c.example.com"},
+			},
+		},
+		{
+			name:     "failed domain",
+			shards:   map[string]string{"a.example.com": "0", "b.example.com": "0", "c.example.com": "0"},
+			failed:   map[string]string{"c.example.com": "no such host"},
+			expected: map[string][]string{},
+		},
+		{
+			name:   "failed domain with added domain",
+			shards: map[string]string{"a.example.com": "0", "b.example.com": "0", "c.example.com": "0", "d.example.com": "0"},
+			failed: map[string]string{"c.example.com": "no such host"},
+			expected: map[string][]string{
+				"0": {"a.example.com", "b.example.com", "c.example.com", "d.example.com"},
+			},
+		},
+		{
+			name:   "failed domain with removed domain",
+			shards: map[string]string{"a.example.com": "0", "c.example.com": "0"},
+			failed: map[string]string{"c.example.com": "no such host"},
+			expected: map[string][]string{
+				"0": {"a.example.com", "c.example.com"},
+			},
+		},
+		{
+			name:    "min_days_remaining reached",
+			shards:  map[string]string{"a.example.com": "0", "b.example.com": "0"},
+			minDays: "90",
+			expected: map[string][]string{
+				"0": {"a.example.com", "b.example.com"},
+			},
+		},
+		{
+			name:     "min_days_remaining not reached",
+			shards:   map[string]string{"a.example.com": "0", "b.example.com": "0"},
+			minDays:  "89",
+			expected: map[string][]string{},
+		},
+		{
+			name:     "negative min_days_remaining",
+			shards:   map[string]string{"a.example.com": "0", "b.example.com": "0"},
+			minDays:  "-1",
+			expected: map[string][]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.minDays == "" {
+				tc.minDays = "30"
+			}
+
+			attrs := map[string]string{
+				"min_days_remaining": tc.minDays,
+				"certificate_pem.%":  "1",
+				"certificate_pem.0":  string(b.CertPEM),
+				"issuer_pem.%":       "1",
+				"issuer_pem.0":       string(b.IssuerPEM),
+				"failed_domains.%":   strconv.Itoa(len(tc.failed)),
+			}
+			for domain, reason := range tc.failed {
+				attrs["failed_domains."+domain] = reason
+			}
+
+			d := resourceACMECertificateSet().Data(&terraform.InstanceState{ID: "set", Attributes: attrs})
+			actual, err := resourceACMECertificateSetPendingShards(d, tc.shards, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Fatalf("expected %#v, got %#v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResourceACMECertificateSetRead(t *testing.T) {
+	now := time.Now()
+	b := testGenerateCertificateBundle(t, now.Add(-time.Hour), now.Add(90*24*time.Hour), []string{"a.example.com"}, nil)
+
+	// Shard 1 has lost its certificate, and has no URL to recover it from.
+	d := resourceACMECertificateSet().Data(&terraform.InstanceState{
+		ID: "set",
+		Attributes: map[string]string{
+			"domain_shards.%":             "2",
+			"domain_shards.a.example.com": "0",
+			"domain_shards.b.example.com": "1",
+			"certificate_pem.%":           "2",
+			"certificate_pem.0":           string(b.CertPEM),
+			"certificate_pem.1":           "",
+			"issuer_pem.%":                "2",
+			"issuer_pem.0":                string(b.IssuerPEM),
+			"issuer_pem.1":                string(b.IssuerPEM),
+			"private_key_pem.%":           "2",
+			"private_key_pem.0":           string(b.KeyPEM),
+			"private_key_pem.1":           string(b.KeyPEM),
+			"certificate_serial.%":        "2",
+			"certificate_serial.0":        "stale",
+			"certificate_serial.1":        "stale",
+		},
+	})
+
+	diags := resourceACMECertificateSetRead(context.Background(), d, nil)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || diags[0].Summary != "Certificate for shard 1 dropped" {
+		t.Fatalf("expected a warning for dropping shard 1, got %#v", diags)
+	}
+
+	certs, err := parsePEMBundle(b.CertPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{"0": certs[0].SerialNumber.String()}
+	if actual := d.Get("certificate_serial").(map[string]any); !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected certificate_serial to be %#v, got %#v", expected, actual)
+	}
+
+	for _, k := range []string{"certificate_pem", "issuer_pem", "private_key_pem"} {
+		if _, ok := d.Get(k).(map[string]any)["1"]; ok {
+			t.Fatalf("expected shard 1 to be dropped from %s", k)
+		}
+	}
+
+	// The plan now issues a certificate for shard 1.
+	pending, err := resourceACMECertificateSetPendingShards(d, stringMap(d.Get("domain_shards").(map[string]any)), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := map[string][]string{"1": {"b.example.com"}}; !reflect.DeepEqual(expected, pending) {
+		t.Fatalf("expected pending shards to be %#v, got %#v", expected, pending)
+	}
+}
+
+func TestResourceACMECertificateSetPlanShards(t *testing.T) {
+	now := time.Now()
+	b := testGenerateCertificateBundle(t, now.Add(-time.Hour), now.Add(90*24*time.Hour), []string{"a.example.com"}, nil)
+
+	r := resourceACMECertificateSet()
+	d := r.TestResourceData()
+	d.SetId("set")
+	for k, v := range map[string]any{
+		"account_key_pem":          "key",
+		"domains":                  []any{"a.example.com", "b.example.com"},
+		"max_sans_per_certificate": 1,
+		"domain_shards":            map[string]any{"a.example.com": "0", "b.example.com": "1"},
+		"certificate_pem":          map[string]any{"0": string(b.CertPEM)},
+		"issuer_pem":               map[string]any{"0": string(b.IssuerPEM)},
+		"failed_domains":           map[string]any{"b.example.com": "error"},
+	} {
+		if err := d.Set(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := d.State()
+	cfg := terraform.NewResourceConfigRaw(map[string]any{
+		"account_key_pem":          "key",
+		"domains":                  []any{"a.example.com", "b.example.com"},
+		"max_sans_per_certificate": 1,
+	})
+
+	// Shard 1 has no certificate, so only its outputs are unknown.
+	diff, err := r.SimpleDiff(context.Background(), state, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prior, err := state.AttrsAsObjectValue(r.CoreConfigSchema().ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	planned, err := diff.ApplyToValue(prior, r.CoreConfigSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, expected := range map[string]map[string]bool{
+		"certificate_pem": {"0": true, "1": false},
+		"issuer_pem":      {"0": true, "1": false},
+		"failed_domains":  {"b.example.com": false},
+	} {
+		m := planned.GetAttr(k)
+		if !m.IsKnown() {
+			t.Fatalf("expected %s to be known", k)
+		}
+
+		values := m.AsValueMap()
+		if len(values) != len(expected) {
+			t.Fatalf("expected %s to have %d elements, got %#v", k, len(expected), values)
+		}
+
+		for id, known := range expected {
+			if values[id].IsKnown() != known {
+				t.Fatalf("expected %s.%s to be known=%t, got %#v", k, id, known, values[id])
+			}
+		}
+	}
+
+	if v := planned.GetAttr("certificate_pem").Index(cty.StringVal("0")).AsString(); v != string(b.CertPEM) {
+		t.Fatalf("expected certificate_pem.0 to be kept, got %q", v)
+	}
+}
+
+func TestAccACMECertificateSet_basic(t *testing.T) {
+	wantEnv := os.Environ()
+	serials := make(map[string]string)
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateSetConfig("www27", "www28", "www29"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(certificateSetResourceName, "certificate_pem.%", "2"),
+					resource.TestCheckResourceAttr(certificateSetResourceName, "failed_domains.%", "0"),
+					resource.TestCheckResourceAttr(certificateSetResourceName, "domain_shards.www27."+pebbleCertDomain, "0"),
+					resource.TestCheckResourceAttr(certificateSetResourceName, "domain_shards.www28."+pebbleCertDomain, "0"),
+					resource.TestCheckResourceAttr(certificateSetResourceName, "domain_shards.www29."+pebbleCertDomain, "1"),
+					testAccCheckACMECertificateSetShards(serials, nil),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				Config: testAccACMECertificateSetConfig("www27", "www28", "www29", "www30"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(certificateSetResourceName, "certificate_pem.%", "2"),
+					resource.TestCheckResourceAttr(certificateSetResourceName, "domain_shards.www30."+pebbleCertDomain, "1"),
+					testAccCheckACMECertificateSetShards(serials, []string{"1"}),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
+// testAccCheckACMECertificateSetShards checks that the certificate for each
+// shard covers exactly the domains assigned to the shard, and that only the
+// shards in reissued have new certificates since the last check.
+func testAccCheckACMECertificateSetShards(serials map[string]string, reissued []string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[certificateSetResourceName]
+		if !ok {
+			return fmt.Errorf("Can't find ACME certificate set: %s", certificateSetResourceName)
+		}
+
+		shards := make(map[string][]string)
+		for domain, id := range testAccStateMap(rs.Primary.Attributes, "domain_shards") {
+			shards[id] = append(shards[id], domain)
+		}
+
+		for id, domains := range shards {
+			certs, err := parsePEMBundle([]byte(rs.Primary.Attributes["certificate_pem."+id]))
+			if err != nil {
+				return fmt.Errorf("error reading certificate for shard %s: %w", id, err)
+			}
+
+			issued := certcrypto.ExtractDomains(certs[0])
+			sort.Strings(issued)
+			sort.Strings(domains)
+			if !reflect.DeepEqual(issued, domains) {
+				return fmt.Errorf("expected certificate for shard %s to have domains %v, got %v", id, domains, issued)
+			}
+
+			serial := rs.Primary.Attributes["certificate_serial."+id]
+			if prior, ok := serials[id]; ok && (prior != serial) != slices.Contains(reissued, id) {
+				return fmt.Errorf("unexpected reissue state for shard %s (reissued: %t)", id, prior != serial)
+			}
+
+			serials[id] = serial
+		}
+
+		return nil
+	}
+}
+
+// testAccStateMap returns the values of a map attribute from flatmapped state.
+func testAccStateMap(attrs map[string]string, key string) map[string]string {
+	result := make(map[string]string)
+	for k, v := range attrs {
+		if name, ok := strings.CutPrefix(k, key+"."); ok && name != "%" {
+			result[name] = v
+		}
+	}
+
+	return result
+}
+
+func testAccACMECertificateSetConfig(hosts ...string) string {
+	var domains string
+	for _, host := range hosts {
+		domains += fmt.Sprintf("    \"%s.${var.domain}\",\n", host)
+	}
+
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "acme_certificate_set" "certificates" {
+  account_key_pem          = "${acme_registration.reg.account_key_pem}"
+  max_sans_per_certificate = 2
+  domains = [
+%s  ]
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		domains,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
@@ -1,6 +1,7 @@
 package acme
 
 import (
+	"bytes"
 	"context"
 	"crypto"
 	"crypto/ecdsa"
@@ -14,6 +15,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"strings"
@@ -23,6 +25,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	"github.com/rainycape/memcache"
 	"software.sslmate.com/src/go-pkcs12"
@@ -33,6 +36,8 @@ const standardResourceName = "acme_certificate.certificate"
 var uuidRegexp = regexp.MustCompile(`^[a-zA-Z0-9]{8}-[a-zA-Z0-9]{4}-[a-zA-Z0-9]{4}-[a-zA-Z0-9]{4}-[a-zA-Z0-9]{12}$`)
 var certURLRegexp = regexp.MustCompile(`^https://localhost:1400[012]/certZ/[a-z0-9]+(/alternate/\d+)?$`)
 
+var orderURLRegexp = regexp.MustCompile(`^https://localhost:1400[012]/my-order/[A-Za-z0-9_-]+$`)
+
 func TestAccACMECertificate_basic(t *testing.T) {
 	wantEnv := os.Environ()
 	resource.Test(t, resource.TestCase{
@@ -48,6 +53,14 @@ func TestAccACMECertificate_basic(t *testing.T) {
 					testAccCheckACMECertificateValid("acme_certificate.certificate", "www", "www2"),
 					testAccCheckACMECertificateIntermediateEqual("acme_certificate.certificate", getPebbleCertificate(mainIntermediateURL)),
 					testAccCheckACMECertificateStatus("acme_certificate.certificate", certificateStatusValid),
+					resource.TestCheckResourceAttrSet("acme_certificate.certificate", "next_renewal_at"),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "renewal_reason", renewalReasonMinDays),
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "order_url", orderURLRegexp),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "authorizations.#", "2"),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "authorizations.0.status", "valid"),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "authorizations.0.challenge_type", "dns-01"),
+					resource.TestCheckResourceAttrSet("acme_certificate.certificate", "authorizations.0.validated_at"),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "authorizations.0.reused", "false"),
 					testAccCheckEnvironNotChanged(wantEnv),
 				),
 			},
@@ -274,6 +287,451 @@ func TestAccACMECertificate_p12Password(t *testing.T) {
 	})
 }
 
+func TestAccACMECertificate_bundleFormats(t *testing.T) {
+	wantEnv := os.Environ()
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigBundleFormats(p12EncodingLegacyDES, "My-Certificate"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "id", uuidRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www31", "www32"),
+					testAccCheckACMECertificateBundleFormats("acme_certificate.certificate"),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "certificate_info.0.key_algorithm", "RSA"),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "certificate_info.0.key_size", "2048"),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "certificate_info.0.dns_names.#", "2"),
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "certificate_info.0.sha256_fingerprint", regexp.MustCompile(`^([0-9A-F]{2}:){31}[0-9A-F]{2}$`)),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				Config: testAccACMECertificateConfigBundleFormats(p12EncodingModern, ""),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "id", uuidRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www31", "www32"),
+					testAccCheckACMECertificateBundleFormats("acme_certificate.certificate"),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
+func TestAccACMECertificate_verifyChain(t *testing.T) {
+	wantEnv := os.Environ()
+	var serial string
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigVerifyChain(alternateRootURL, verifyChainWarn),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "id", uuidRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www33", ""),
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "chain_verification_error", regexp.MustCompile(`error verifying certificate chain with root "CN=Pebble Root CA`)),
+					testAccCheckACMECertificateSaveSerial(&serial),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				Config: testAccACMECertificateConfigVerifyChain(mainRootURL, verifyChainFail),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "chain_verification_error", ""),
+					testAccCheckACMECertificateCheckSerialEqual(&serial, true),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				Config:      testAccACMECertificateConfigVerifyChain(alternateRootURL, verifyChainFail),
+				ExpectError: regexp.MustCompile(`error verifying certificate chain with root "CN=Pebble Root CA`),
+			},
+		},
+	})
+}
+
+func TestAccACMECertificate_privateKey(t *testing.T) {
+	wantEnv := os.Environ()
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigPrivateKey(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "id", uuidRegexp),
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "certificate_url", certURLRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www18", "www19"),
+					resource.TestCheckResourceAttrPair(
+						"acme_certificate.certificate", "private_key_pem",
+						"tls_private_key.cert_private_key", "private_key_pem",
+					),
+					testAccCheckACMECertificatePublicKey("acme_certificate.certificate", "tls_private_key.cert_private_key"),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
+func TestAccACMECertificate_privateKeyWriteOnly(t *testing.T) {
+	wantEnv := os.Environ()
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigPrivateKeyWriteOnly(),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "id", uuidRegexp),
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "certificate_url", certURLRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www20", "www21"),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "private_key_pem", ""),
+					resource.TestCheckNoResourceAttr("acme_certificate.certificate", "private_key_pem_wo"),
+					resource.TestCheckResourceAttrSet("acme_certificate.certificate", "certificate_p12"),
+					testAccCheckACMECertificatePublicKey("acme_certificate.certificate", "tls_private_key.cert_private_key"),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
+func TestAccACMECertificate_keyRotation(t *testing.T) {
+	testCases := []struct {
+		policy     string
+		commonName string
+		keyEqual   bool
+	}{
+		{
+			policy:     keyRotationReuse,
+			commonName: "www22",
+			keyEqual:   true,
+		},
+		{
+			policy:     keyRotationAlways,
+			commonName: "www23",
+			keyEqual:   false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			wantEnv := os.Environ()
+			var serial, key string
+			resource.Test(t, resource.TestCase{
+				ProviderFactories: testAccProviders,
+				ExternalProviders: testAccExternalProviders,
+				Steps: []resource.TestStep{
+					{
+						Config: testAccACMECertificateConfigKeyRotation(tc.commonName, tc.policy),
+						Check: resource.ComposeTestCheckFunc(
+							testAccCheckACMECertificateValid(standardResourceName, tc.commonName, ""),
+							resource.TestCheckResourceAttrSet(standardResourceName, "private_key_created_at"),
+							testAccCheckACMECertificateSaveSerial(&serial),
+							testAccCheckACMECertificateSavePrivateKey(&key),
+							testAccCheckEnvironNotChanged(wantEnv),
+						),
+						ExpectNonEmptyPlan: true,
+					},
+					{
+						Config: testAccACMECertificateConfigKeyRotation(tc.commonName, tc.policy),
+						Check: resource.ComposeTestCheckFunc(
+							testAccCheckACMECertificateValid(standardResourceName, tc.commonName, ""),
+							testAccCheckACMECertificateCheckSerialEqual(&serial, false),
+							testAccCheckACMECertificateCheckPrivateKeyEqual(&key, tc.keyEqual),
+							testAccCheckEnvironNotChanged(wantEnv),
+						),
+						ExpectNonEmptyPlan: true,
+					},
+				},
+			})
+		})
+	}
+}
+
+func TestAccACMECertificate_privateKeyEncrypted(t *testing.T) {
+	wantEnv := os.Environ()
+	var serial, key string
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigPrivateKeyEncrypted(false),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateValid(standardResourceName, "www24", ""),
+					resource.TestCheckNoResourceAttr(standardResourceName, "private_key_passphrase"),
+					testAccCheckACMECertificateEncryptedPrivateKey("correct horse battery staple", &key),
+					testAccCheckACMECertificateSaveSerial(&serial),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				Config: testAccACMECertificateConfigPrivateKeyEncrypted(true),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateValid(standardResourceName, "www24", ""),
+					resource.TestCheckResourceAttr(standardResourceName, "private_key_pem", ""),
+					resource.TestCheckResourceAttr(standardResourceName, "certificate_p12", ""),
+					testAccCheckACMECertificateEncryptedPrivateKey("correct horse battery staple", &key),
+					testAccCheckACMECertificateCheckSerialEqual(&serial, true),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
+func TestAccACMECertificate_idn(t *testing.T) {
+	wantEnv := os.Environ()
+	san, err := normalizeDomain("bücher26")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigIDN(),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateValid(standardResourceName, "www26", san),
+					resource.TestCheckResourceAttr(standardResourceName, "domains.#", "2"),
+					resource.TestCheckResourceAttr(standardResourceName, "domains.0", "www26."+pebbleCertDomain),
+					resource.TestCheckResourceAttr(standardResourceName, "domains.1", san+"."+pebbleCertDomain),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
+func TestAccACMECertificate_keyTypes(t *testing.T) {
+	wantEnv := os.Environ()
+	serials := make(map[string]string)
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigKeyTypes(),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateValid(standardResourceName, "www25", ""),
+					resource.TestCheckResourceAttr(standardResourceName, "certificate_pem_by_key_type.%", "2"),
+					resource.TestCheckResourceAttrPair(standardResourceName, "certificate_pem", standardResourceName, "certificate_pem_by_key_type.P256"),
+					resource.TestCheckResourceAttrPair(standardResourceName, "private_key_pem", standardResourceName, "private_key_pem_by_key_type.P256"),
+					resource.TestCheckResourceAttrSet(standardResourceName, "certificate_p12_by_key_type.2048"),
+					testAccCheckACMECertificateKeyTypes(serials, false),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccACMECertificateConfigKeyTypes(),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateValid(standardResourceName, "www25", ""),
+					resource.TestCheckResourceAttrPair(standardResourceName, "certificate_pem", standardResourceName, "certificate_pem_by_key_type.P256"),
+					testAccCheckACMECertificateKeyTypes(serials, true),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestResourceACMECertificateDomains(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceACMECertificate().Schema, map[string]any{
+		"common_name":               "WWW.Bücher.example.",
+		"subject_alternative_names": []any{"www.xn--bcher-kva.example", "Mail.Example.com"},
+		"ip_addresses":              []any{"2001:DB8:0:0::1"},
+	})
+
+	actual, err := resourceACMECertificateDomains(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"www.xn--bcher-kva.example", "mail.example.com", "2001:db8::1"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected %#v, got %#v", expected, actual)
+	}
+}
+
+func TestValidateCertificateIPAddresses(t *testing.T) {
+	testCases := []struct {
+		name        string
+		raw         map[string]any
+		expectedErr bool
+	}{
+		{
+			name: "no IP addresses",
+			raw: map[string]any{
+				"common_name":   "www.example.com",
+				"dns_challenge": []any{map[string]any{"provider": "exec"}},
+			},
+		},
+		{
+			name: "http challenge",
+			raw: map[string]any{
+				"ip_addresses":   []any{"192.0.2.1", "2001:db8::1"},
+				"http_challenge": []any{map[string]any{}},
+			},
+		},
+		{
+			name: "dns challenge only",
+			raw: map[string]any{
+				"ip_addresses":  []any{"192.0.2.1"},
+				"dns_challenge": []any{map[string]any{"provider": "exec"}},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "wildcard without dns challenge",
+			raw: map[string]any{
+				"subject_alternative_names": []any{"*.example.com"},
+				"ip_addresses":              []any{"192.0.2.1"},
+				"tls_challenge":             []any{map[string]any{}},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "wildcard with dns and tls challenges",
+			raw: map[string]any{
+				"subject_alternative_names": []any{"*.example.com"},
+				"ip_addresses":              []any{"192.0.2.1"},
+				"dns_challenge":             []any{map[string]any{"provider": "exec"}},
+				"tls_challenge":             []any{map[string]any{}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, resourceACMECertificate().Schema, tc.raw)
+			err := validateCertificateIPAddresses(d)
+			if tc.expectedErr && err == nil {
+				t.Fatal("expected error, got none")
+			}
+
+			if !tc.expectedErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestResourceACMECertificateKeyExpired(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name      string
+		policy    string
+		createdAt string
+		expected  bool
+	}{
+		{
+			name:      "reuse",
+			policy:    keyRotationReuse,
+			createdAt: now.Add(time.Hour * -24 * 365).Format(time.RFC3339),
+			expected:  false,
+		},
+		{
+			name:      "max age not reached",
+			policy:    keyRotationMaxAgeDays,
+			createdAt: now.Add(time.Hour * -24 * 29).Format(time.RFC3339),
+			expected:  false,
+		},
+		{
+			name:      "max age reached",
+			policy:    keyRotationMaxAgeDays,
+			createdAt: now.Add(time.Hour * -24 * 30).Format(time.RFC3339),
+			expected:  true,
+		},
+		{
+			name:      "unknown age",
+			policy:    keyRotationMaxAgeDays,
+			createdAt: "",
+			expected:  false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := resourceACMECertificate().TestResourceData()
+			d.Set("key_rotation", tc.policy)
+			d.Set("key_rotation_max_age_days", 30)
+			d.Set("private_key_created_at", tc.createdAt)
+
+			actual, err := resourceACMECertificateKeyExpired(d, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.expected != actual {
+				t.Fatalf("expected %t, got %t", tc.expected, actual)
+			}
+
+			// With max_age_days, keys are rotated only when expired.
+			rotate, err := resourceACMECertificateShouldRotateKey(d, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.policy == keyRotationMaxAgeDays && rotate != tc.expected {
+				t.Fatalf("expected rotation to be %t, got %t", tc.expected, rotate)
+			}
+		})
+	}
+
+	// Keys supplied through a CSR are never rotated.
+	d := resourceACMECertificate().TestResourceData()
+	d.Set("key_rotation", keyRotationMaxAgeDays)
+	d.Set("key_rotation_max_age_days", 30)
+	d.Set("certificate_request_pem", "csr")
+	if actual, err := resourceACMECertificateKeyExpired(d, now); err != nil || actual {
+		t.Fatalf("expected key with CSR to not expire, got %t, err %v", actual, err)
+	}
+}
+
+func TestResourceACMECertificateManagesKey(t *testing.T) {
+	testCases := []struct {
+		name       string
+		attributes map[string]string
+		expected   bool
+	}{
+		{
+			name:       "generated",
+			attributes: map[string]string{"private_key_pem": "key"},
+			expected:   true,
+		},
+		{
+			name: "supplied",
+			attributes: map[string]string{
+				"private_key_pem":      "key",
+				"private_key_supplied": "true",
+			},
+		},
+		{
+			name:       "supplied write-only",
+			attributes: map[string]string{"private_key_supplied": "true"},
+		},
+		{
+			name:       "CSR",
+			attributes: map[string]string{"certificate_request_pem": "csr"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The configuration is null during refresh, so this is decided from
+			// state.
+			d := resourceACMECertificate().Data(&terraform.InstanceState{
+				ID:         "certificate",
+				Attributes: tc.attributes,
+			})
+			if actual := resourceACMECertificateManagesKey(d); actual != tc.expected {
+				t.Fatalf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
 func TestAccACMECertificate_preCheckDelay(t *testing.T) {
 	wantEnv := os.Environ()
 	var step1Start, step1End, step2Start, step2End time.Time
@@ -427,17 +885,34 @@ func TestAccACMECertificate_duplicateDomain(t *testing.T) {
 
 func TestAccACMECertificate_preferredChain(t *testing.T) {
 	wantEnv := os.Environ()
+	var serial string
 	resource.Test(t, resource.TestCase{
 		ProviderFactories: testAccProviders,
 		ExternalProviders: testAccExternalProviders,
 		Steps: []resource.TestStep{
 			{
-				Config: testAccACMECertificateConfigPreferredChain(),
+				Config: testAccACMECertificateConfigPreferredChain(alternateIntermediateURL, false),
 				Check: resource.ComposeTestCheckFunc(
 					resource.TestMatchResourceAttr("acme_certificate.certificate", "id", uuidRegexp),
 					resource.TestMatchResourceAttr("acme_certificate.certificate", "certificate_url", certURLRegexp),
 					testAccCheckACMECertificateValid("acme_certificate.certificate", "test-preferred", "test-preferred2"),
 					testAccCheckACMECertificateIntermediateEqual("acme_certificate.certificate", getPebbleCertificate(alternateIntermediateURL)),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "available_chains.#", "0"),
+					testAccCheckACMECertificateSaveSerial(&serial),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				// Switching the preferred chain re-selects the chain without
+				// reissuing the certificate.
+				Config: testAccACMECertificateConfigPreferredChain(mainIntermediateURL, true),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "certificate_url", certURLRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "test-preferred", "test-preferred2"),
+					testAccCheckACMECertificateIntermediateEqual("acme_certificate.certificate", getPebbleCertificate(mainIntermediateURL)),
+					resource.TestCheckResourceAttr("acme_certificate.certificate", "available_chains.#", "2"),
+					testAccCheckACMECertificateBundleFormats("acme_certificate.certificate"),
+					testAccCheckACMECertificateCheckSerialEqual(&serial, true),
 					testAccCheckEnvironNotChanged(wantEnv),
 				),
 			},
@@ -465,6 +940,24 @@ func TestAccACMECertificate_http(t *testing.T) {
 	})
 }
 
+func TestAccACMECertificate_httpIPAddress(t *testing.T) {
+	wantEnv := os.Environ()
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigHTTPIPAddress(),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateValid(standardResourceName, "test-http-ip", ""),
+					resource.TestCheckResourceAttr(standardResourceName, "certificate_ip_addresses.#", "1"),
+					resource.TestCheckResourceAttr(standardResourceName, "certificate_ip_addresses.0", "127.0.0.1"),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
 func TestAccACMECertificate_httpWebroot(t *testing.T) {
 	wantEnv := os.Environ()
 	closeServer, serverDir, err := testAccCheckACMECertificateWebrootTestServer()
@@ -842,27 +1335,167 @@ func TestAccACMECertificate_validityDays_validation(t *testing.T) {
 	})
 }
 
-type testAccCheckACMECertificateStandardOpts struct {
-	CommonName             string
-	SubjectAlternativeName string
-	IntermediateURL        string
-	ExpectedStatus         string
-	ExpectedEnv            []string
+func TestAccACMECertificate_renewalTriggers(t *testing.T) {
+	wantEnv := os.Environ()
+	var certSerial, id string
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigRenewalTriggers("1"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "id", uuidRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www35", ""),
+					testAccCheckACMECertificateSaveSerial(&certSerial),
+					func(s *terraform.State) error {
+						id = s.RootModule().Resources["acme_certificate.certificate"].Primary.ID
+						return nil
+					},
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				// The certificate is renewed in place, without replacing the
+				// resource.
+				Config: testAccACMECertificateConfigRenewalTriggers("2"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPtr("acme_certificate.certificate", "id", &id),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www35", ""),
+					testAccCheckACMECertificateCheckSerialEqual(&certSerial, false),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
 }
 
-func testAccCheckACMECertificateStandard(
-	opts testAccCheckACMECertificateStandardOpts,
-) resource.TestCheckFunc {
-	return resource.ComposeTestCheckFunc(
-		resource.TestMatchResourceAttr(
-			standardResourceName,
-			"id",
-			uuidRegexp,
-		),
-		resource.TestMatchResourceAttr(
-			standardResourceName,
-			"certificate_url",
-			certURLRegexp,
+func TestAccACMECertificate_deployHook(t *testing.T) {
+	wantEnv := os.Environ()
+	logPath := filepath.Join(t.TempDir(), "deploy.log")
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigDeployHook(logPath, "1"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www36", ""),
+					testAccCheckACMECertificateDeployLog(logPath, "create www36."+pebbleCertDomain),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				// The hook is run again on renewal.
+				Config: testAccACMECertificateConfigDeployHook(logPath, "2"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www36", ""),
+					testAccCheckACMECertificateDeployLog(
+						logPath,
+						"create www36."+pebbleCertDomain,
+						"renew www36."+pebbleCertDomain,
+					),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
+func TestAccACMECertificate_renewalWindow(t *testing.T) {
+	wantEnv := os.Environ()
+	var certSerial string
+	// An hour that is well away from the current time, so that the renewal is
+	// deferred.
+	hour := (time.Now().UTC().Hour() + 12) % 24
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigRenewalWindow(hour),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "id", uuidRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www34", ""),
+					testAccCheckACMECertificateSaveSerial(&certSerial),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+				// The renewal is due, but deferred until the renewal window.
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccACMECertificateConfigRenewalWindow(hour),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateCheckSerialEqual(&certSerial, true),
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "renewal_window_status", regexp.MustCompile(`^deferred until .*: outside the renewal window$`)),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+		},
+	})
+}
+
+func TestAccACMECertificate_validity(t *testing.T) {
+	wantEnv := os.Environ()
+	var certSerial string
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviders,
+		ExternalProviders: testAccExternalProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccACMECertificateConfigValidity("160h", "36h"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("acme_certificate.certificate", "id", uuidRegexp),
+					testAccCheckACMECertificateValid("acme_certificate.certificate", "www-vd3", ""),
+					testAccCheckACMECertificateNotAfterDuration("acme_certificate.certificate", 160*time.Hour),
+					testAccCheckACMECertificateSaveSerial(&certSerial),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				// Still outside of the renewal threshold.
+				Config: testAccACMECertificateConfigValidity("160h", "50%"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateCheckSerialEqual(&certSerial, true),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				Config: testAccACMECertificateConfigValidity("160h", "99.9%"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckACMECertificateCheckSerialEqual(&certSerial, false),
+					testAccCheckEnvironNotChanged(wantEnv),
+				),
+			},
+			{
+				Config:      testAccACMECertificateConfigValidity("36h", "36h"),
+				ExpectError: regexp.MustCompile(`validity \(36h0m0s\) is within renew_before`),
+			},
+		},
+	})
+}
+
+type testAccCheckACMECertificateStandardOpts struct {
+	CommonName             string
+	SubjectAlternativeName string
+	IntermediateURL        string
+	ExpectedStatus         string
+	ExpectedEnv            []string
+}
+
+func testAccCheckACMECertificateStandard(
+	opts testAccCheckACMECertificateStandardOpts,
+) resource.TestCheckFunc {
+	return resource.ComposeTestCheckFunc(
+		resource.TestMatchResourceAttr(
+			standardResourceName,
+			"id",
+			uuidRegexp,
+		),
+		resource.TestMatchResourceAttr(
+			standardResourceName,
+			"certificate_url",
+			certURLRegexp,
 		),
 		testAccCheckACMECertificateValid(
 			standardResourceName,
@@ -881,6 +1514,47 @@ func testAccCheckACMECertificateStandard(
 	)
 }
 
+// testAccCheckACMECertificateBundleFormats checks that the alternate formats
+// of the certificate match certificate_pem and issuer_pem.
+func testAccCheckACMECertificateBundleFormats(n string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Can't find ACME certificate: %s", n)
+		}
+
+		cert := rs.Primary.Attributes["certificate_pem"]
+		issuer := rs.Primary.Attributes["issuer_pem"]
+		if fullchain := rs.Primary.Attributes["certificate_pem_fullchain"]; fullchain != cert+issuer {
+			return fmt.Errorf("expected certificate_pem_fullchain to be certificate_pem followed by issuer_pem, got:\n%s", fullchain)
+		}
+
+		for attr, expected := range map[string]string{"certificate_der": cert, "issuer_der": issuer} {
+			certs, err := parsePEMBundle([]byte(expected))
+			if err != nil {
+				return err
+			}
+
+			der, err := base64.StdEncoding.DecodeString(rs.Primary.Attributes[attr])
+			if err != nil {
+				return fmt.Errorf("error decoding %s: %w", attr, err)
+			}
+
+			if !bytes.Equal(der, certs[0].Raw) {
+				return fmt.Errorf("%s does not match the first certificate in PEM format", attr)
+			}
+		}
+
+		for _, attr := range []string{"certificate_p7b", "certificate_jks"} {
+			if rs.Primary.Attributes[attr] == "" {
+				return fmt.Errorf("expected %s to be set", attr)
+			}
+		}
+
+		return nil
+	}
+}
+
 func testAccCheckACMECertificateValid(n, cn, san string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[n]
@@ -1287,6 +1961,43 @@ func testAccCheckACMECertificateRenewalInfo() resource.TestCheckFunc {
 	}
 }
 
+// testAccCheckACMECertificatePublicKey checks that the public key of the
+// certificate matches the public key of the supplied tls_private_key resource.
+func testAccCheckACMECertificatePublicKey(name, keyName string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("Can't find ACME certificate: %s", name)
+		}
+
+		ks, ok := s.RootModule().Resources[keyName]
+		if !ok {
+			return fmt.Errorf("Can't find private key: %s", keyName)
+		}
+
+		certs, err := parsePEMBundle([]byte(rs.Primary.Attributes["certificate_pem"]))
+		if err != nil {
+			return err
+		}
+
+		block, _ := pem.Decode([]byte(ks.Primary.Attributes["public_key_pem"]))
+		if block == nil {
+			return fmt.Errorf("cannot decode public key for %s", keyName)
+		}
+
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return err
+		}
+
+		if !reflect.DeepEqual(certs[0].PublicKey, pub) {
+			return fmt.Errorf("public key for cert and %s don't match", keyName)
+		}
+
+		return nil
+	}
+}
+
 func testAccCheckACMECertificateSaveCert(ptr *string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[standardResourceName]
@@ -1299,6 +2010,23 @@ func testAccCheckACMECertificateSaveCert(ptr *string) resource.TestCheckFunc {
 	}
 }
 
+// testAccCheckACMECertificateDeployLog checks the lines written to the log
+// file by the deploy hook.
+func testAccCheckACMECertificateDeployLog(path string, expected ...string) resource.TestCheckFunc {
+	return func(_ *terraform.State) error {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if actual := strings.Split(strings.TrimSpace(string(b)), "\n"); !reflect.DeepEqual(actual, expected) {
+			return fmt.Errorf("expected deploy hook log %q, got %q", expected, actual)
+		}
+
+		return nil
+	}
+}
+
 func testAccCheckACMECertificateSaveSerial(ptr *string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[standardResourceName]
@@ -1311,58 +2039,689 @@ func testAccCheckACMECertificateSaveSerial(ptr *string) resource.TestCheckFunc {
 	}
 }
 
-func testAccCheckACMECertificateCheckSerialEqual(want *string, equal bool) resource.TestCheckFunc {
-	return func(s *terraform.State) error {
-		rs, ok := s.RootModule().Resources[standardResourceName]
-		if !ok {
-			return fmt.Errorf("Can't find ACME certificate: %s", standardResourceName)
-		}
-
-		got := rs.Primary.Attributes["certificate_serial"]
-		if equal {
-			if *want != got {
-				return fmt.Errorf("certificate serial mismatch: want %q, got %q", *want, got)
-			}
-		} else {
-			if *want == got {
-				return fmt.Errorf("expected certificate serial (%s) to have changed", got)
-			}
-		}
+func testAccCheckACMECertificateCheckSerialEqual(want *string, equal bool) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[standardResourceName]
+		if !ok {
+			return fmt.Errorf("Can't find ACME certificate: %s", standardResourceName)
+		}
+
+		got := rs.Primary.Attributes["certificate_serial"]
+		if equal {
+			if *want != got {
+				return fmt.Errorf("certificate serial mismatch: want %q, got %q", *want, got)
+			}
+		} else {
+			if *want == got {
+				return fmt.Errorf("expected certificate serial (%s) to have changed", got)
+			}
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckACMECertificateSavePrivateKey(ptr *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[standardResourceName]
+		if !ok {
+			return fmt.Errorf("Can't find ACME certificate: %s", standardResourceName)
+		}
+
+		*ptr = rs.Primary.Attributes["private_key_pem"]
+		return nil
+	}
+}
+
+// testAccCheckACMECertificateEncryptedPrivateKey checks that
+// private_key_pem_encrypted decrypts with the passphrase to a key matching the
+// certificate. The key is compared to the one in ptr if it's set, otherwise it
+// is saved there.
+func testAccCheckACMECertificateEncryptedPrivateKey(passphrase string, ptr *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[standardResourceName]
+		if !ok {
+			return fmt.Errorf("Can't find ACME certificate: %s", standardResourceName)
+		}
+
+		key, err := decryptPrivateKeyPEM([]byte(rs.Primary.Attributes["private_key_pem_encrypted"]), passphrase)
+		if err != nil {
+			return err
+		}
+
+		certs, err := parsePEMBundle([]byte(rs.Primary.Attributes["certificate_pem"]))
+		if err != nil {
+			return err
+		}
+
+		if !reflect.DeepEqual(certs[0].PublicKey, key.(crypto.Signer).Public()) {
+			return errors.New("encrypted private key does not match the certificate")
+		}
+
+		if pk := rs.Primary.Attributes["private_key_pem"]; pk != "" {
+			plain, err := privateKeyFromPEM([]byte(pk))
+			if err != nil {
+				return err
+			}
+
+			if !reflect.DeepEqual(plain, key) {
+				return errors.New("encrypted private key does not match private_key_pem")
+			}
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return err
+		}
+
+		got := string(der)
+		if *ptr == "" {
+			*ptr = got
+		} else if *ptr != got {
+			return errors.New("expected encrypted private key to be unchanged")
+		}
+
+		return nil
+	}
+}
+
+// testAccCheckACMECertificateKeyTypes checks that the certificates in
+// certificate_pem_by_key_type match the keys in private_key_pem_by_key_type
+// and are for the right key type. The serials of the certificates are saved in
+// serials; if renewed is true, the serials are checked to have all changed
+// first.
+func testAccCheckACMECertificateKeyTypes(serials map[string]string, renewed bool) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[standardResourceName]
+		if !ok {
+			return fmt.Errorf("Can't find ACME certificate: %s", standardResourceName)
+		}
+
+		for kt, wantAlgorithm := range map[string]x509.PublicKeyAlgorithm{
+			"P256": x509.ECDSA,
+			"2048": x509.RSA,
+		} {
+			certs, err := parsePEMBundle([]byte(rs.Primary.Attributes["certificate_pem_by_key_type."+kt]))
+			if err != nil {
+				return fmt.Errorf("error reading %s certificate: %w", kt, err)
+			}
+
+			if certs[0].PublicKeyAlgorithm != wantAlgorithm {
+				return fmt.Errorf("expected %s certificate to have a %s key, got %s", kt, wantAlgorithm, certs[0].PublicKeyAlgorithm)
+			}
+
+			key, err := privateKeyFromPEM([]byte(rs.Primary.Attributes["private_key_pem_by_key_type."+kt]))
+			if err != nil {
+				return fmt.Errorf("error reading %s private key: %w", kt, err)
+			}
+
+			if !reflect.DeepEqual(certs[0].PublicKey, key.(crypto.Signer).Public()) {
+				return fmt.Errorf("%s private key does not match the certificate", kt)
+			}
+
+			serial := rs.Primary.Attributes["certificate_serial_by_key_type."+kt]
+			if renewed && serials[kt] == serial {
+				return fmt.Errorf("expected %s certificate to have been renewed", kt)
+			}
+
+			serials[kt] = serial
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckACMECertificateCheckPrivateKeyEqual(want *string, equal bool) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[standardResourceName]
+		if !ok {
+			return fmt.Errorf("Can't find ACME certificate: %s", standardResourceName)
+		}
+
+		got := rs.Primary.Attributes["private_key_pem"]
+		if equal {
+			if *want != got {
+				return errors.New("expected private key to be re-used")
+			}
+		} else {
+			if *want == got {
+				return errors.New("expected private key to have changed")
+			}
+		}
+
+		return nil
+	}
+}
+
+func testAccCheckACMECertificateNotAfter(name string, expectedDays int) resource.TestCheckFunc {
+	return testAccCheckACMECertificateNotAfterDuration(name, time.Duration(expectedDays)*24*time.Hour)
+}
+
+func testAccCheckACMECertificateNotAfterDuration(name string, expected time.Duration) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("Can't find ACME certificate: %s", name)
+		}
+
+		certPem := rs.Primary.Attributes["certificate_pem"]
+		certs, err := parsePEMBundle([]byte(certPem))
+		if err != nil {
+			return err
+		}
+		x509Cert := certs[0]
+
+		// We do a 20-minute skew here, which is 5m longer than our normal skew for
+		// validity_days and validity.
+		const skew = time.Minute * 20
+		expectedNotAfter := time.Now().Add(expected + skew)
+		if x509Cert.NotAfter.After(expectedNotAfter) {
+			return fmt.Errorf(
+				"certificate NotAfter (%s) is still after expected with %s of applied skew (%s)",
+				x509Cert.NotAfter.Local(), skew, expectedNotAfter,
+			)
+		}
+
+		return nil
+	}
+}
+
+func testAccACMECertificateConfig() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem           = "${acme_registration.reg.account_key_pem}"
+  common_name               = "www.${var.domain}"
+  subject_alternative_names = ["www2.${var.domain}"]
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateRevokeReasonConfig() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem           = "${acme_registration.reg.account_key_pem}"
+  common_name               = "www.${var.domain}"
+  subject_alternative_names = ["www2.${var.domain}"]
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  revoke_certificate_reason = "superseded"
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateCSRConfig() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "tls_private_key" "cert_private_key" {
+  algorithm = "RSA"
+}
+
+resource "tls_cert_request" "req" {
+  private_key_pem = "${tls_private_key.cert_private_key.private_key_pem}"
+  dns_names       = ["www3.${var.domain}", "www4.${var.domain}"]
+
+  subject {
+    common_name  = "www3.${var.domain}"
+  }
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem         = "${acme_registration.reg.account_key_pem}"
+  certificate_request_pem = "${tls_cert_request.req.cert_request_pem}"
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateCSRConfigWithPreferredChain() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "tls_private_key" "cert_private_key" {
+  algorithm = "RSA"
+}
+
+resource "tls_cert_request" "req" {
+  private_key_pem = "${tls_private_key.cert_private_key.private_key_pem}"
+  dns_names       = ["www3.${var.domain}", "www4.${var.domain}"]
+
+  subject {
+    common_name  = "www3.${var.domain}"
+  }
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem         = "${acme_registration.reg.account_key_pem}"
+	certificate_request_pem = "${tls_cert_request.req.cert_request_pem}"
+
+  recursive_nameservers        = ["%s"]
+	disable_complete_propagation = true
+	preferred_chain = "%s"
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		pebbleChallTestDNSSrv,
+		getPebbleCertificateIssuer(alternateIntermediateURL),
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateCSRConfigWithProfile() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "tls_private_key" "cert_private_key" {
+  algorithm = "RSA"
+}
+
+resource "tls_cert_request" "req" {
+  private_key_pem = "${tls_private_key.cert_private_key.private_key_pem}"
+  dns_names       = ["www3.${var.domain}"]
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem         = "${acme_registration.reg.account_key_pem}"
+  certificate_request_pem = "${tls_cert_request.req.cert_request_pem}"
+  profile                 = "tfacmetest"
+  min_days_remaining      = 1
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirProfile,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateForceRenewalConfig() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem    = "${acme_registration.reg.account_key_pem}"
+  common_name        = "www6.${var.domain}"
+  min_days_remaining = 18250
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateConfigRenewalTriggers(rotation string) string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem = "${acme_registration.reg.account_key_pem}"
+  common_name     = "www35.${var.domain}"
+
+  renewal_triggers = {
+    rotation = "%s"
+  }
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		rotation,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateConfigDeployHook(logPath, rotation string) string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem = "${acme_registration.reg.account_key_pem}"
+  common_name     = "www36.${var.domain}"
+
+  renewal_triggers = {
+    rotation = "%s"
+  }
+
+  deploy_hook {
+    command = "/bin/sh"
+    args = [
+      "-c",
+      "test -s \"$ACME_FULLCHAIN_PATH\" && test -s \"$ACME_PRIVATE_KEY_PATH\" && echo \"$ACME_DEPLOY_EVENT $ACME_CERTIFICATE_DOMAIN\" >> \"$DEPLOY_LOG\"",
+    ]
+    environment = {
+      DEPLOY_LOG = "%s"
+    }
+  }
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		rotation,
+		logPath,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateConfigRenewalWindow(hour int) string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem    = "${acme_registration.reg.account_key_pem}"
+  common_name        = "www34.${var.domain}"
+  min_days_remaining = 18250
+
+  renewal_window {
+    hours         = [%d]
+    timezone      = "UTC"
+    jitter        = "30m"
+    safety_margin = "1h"
+  }
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		hour,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateWildcardConfig() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
 
-		return nil
-	}
+variable "domain" {
+  default = "%s"
 }
 
-func testAccCheckACMECertificateNotAfter(name string, expectedDays int) resource.TestCheckFunc {
-	return func(s *terraform.State) error {
-		rs, ok := s.RootModule().Resources[name]
-		if !ok {
-			return fmt.Errorf("Can't find ACME certificate: %s", name)
-		}
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
 
-		certPem := rs.Primary.Attributes["certificate_pem"]
-		certs, err := parsePEMBundle([]byte(certPem))
-		if err != nil {
-			return err
-		}
-		x509Cert := certs[0]
+resource "acme_certificate" "certificate" {
+  account_key_pem = "${acme_registration.reg.account_key_pem}"
+  common_name     = "*.${var.domain}"
 
-		// We do a 20-minute skew here, which is 5m longer than our normal skew for
-		// validity_days.
-		const skew = time.Minute * 20
-		expectedNotAfter := time.Now().Add(time.Duration(expectedDays)*24*time.Hour + skew)
-		if x509Cert.NotAfter.After(expectedNotAfter) {
-			return fmt.Errorf(
-				"certificate NotAfter (%s) is still after expected with %s of applied skew (%s)",
-				x509Cert.NotAfter.Local(), skew, expectedNotAfter,
-			)
-		}
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
 
-		return nil
-	}
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
 }
 
-func testAccACMECertificateConfig() string {
+func testAccACMECertificateConfigP12Password(password string) string {
 	return fmt.Sprintf(`
 provider "acme" {
   server_url = "%s"
@@ -1376,14 +2735,19 @@ variable "domain" {
   default = "%s"
 }
 
+variable "password" {
+  default = "%s"
+}
+
 resource "acme_registration" "reg" {
   email_address   = "${var.email_address}"
 }
 
 resource "acme_certificate" "certificate" {
   account_key_pem           = "${acme_registration.reg.account_key_pem}"
-  common_name               = "www.${var.domain}"
-  subject_alternative_names = ["www2.${var.domain}"]
+  common_name               = "www12.${var.domain}"
+  subject_alternative_names = ["www13.${var.domain}"]
+  certificate_p12_password  = "${var.password}"
 
   recursive_nameservers        = ["%s"]
   disable_complete_propagation = true
@@ -1400,12 +2764,13 @@ resource "acme_certificate" "certificate" {
 		pebbleDirBasic,
 		pebbleCertDomain,
 		pebbleCertDomain,
+		password,
 		pebbleChallTestDNSSrv,
 		pebbleChallTestDNSScriptPath,
 	)
 }
 
-func testAccACMECertificateRevokeReasonConfig() string {
+func testAccACMECertificateConfigBundleFormats(encoding, friendlyName string) string {
 	return fmt.Sprintf(`
 provider "acme" {
   server_url = "%s"
@@ -1425,14 +2790,15 @@ resource "acme_registration" "reg" {
 
 resource "acme_certificate" "certificate" {
   account_key_pem           = "${acme_registration.reg.account_key_pem}"
-  common_name               = "www.${var.domain}"
-  subject_alternative_names = ["www2.${var.domain}"]
+  common_name               = "www31.${var.domain}"
+  subject_alternative_names = ["www32.${var.domain}"]
+  certificate_p12_password  = "changeit"
+  p12_encoding              = "%s"
+  certificate_friendly_name = "%s"
 
   recursive_nameservers        = ["%s"]
   disable_complete_propagation = true
 
-  revoke_certificate_reason = "superseded"
-
   dns_challenge {
     provider = "exec"
     config = {
@@ -1445,12 +2811,14 @@ resource "acme_certificate" "certificate" {
 		pebbleDirBasic,
 		pebbleCertDomain,
 		pebbleCertDomain,
+		encoding,
+		friendlyName,
 		pebbleChallTestDNSSrv,
 		pebbleChallTestDNSScriptPath,
 	)
 }
 
-func testAccACMECertificateCSRConfig() string {
+func testAccACMECertificateConfigVerifyChain(rootURL, mode string) string {
 	return fmt.Sprintf(`
 provider "acme" {
   server_url = "%s"
@@ -1468,22 +2836,12 @@ resource "acme_registration" "reg" {
   email_address   = "${var.email_address}"
 }
 
-resource "tls_private_key" "cert_private_key" {
-  algorithm = "RSA"
-}
-
-resource "tls_cert_request" "req" {
-  private_key_pem = "${tls_private_key.cert_private_key.private_key_pem}"
-  dns_names       = ["www3.${var.domain}", "www4.${var.domain}"]
-
-  subject {
-    common_name  = "www3.${var.domain}"
-  }
-}
-
 resource "acme_certificate" "certificate" {
-  account_key_pem         = "${acme_registration.reg.account_key_pem}"
-  certificate_request_pem = "${tls_cert_request.req.cert_request_pem}"
+  account_key_pem   = "${acme_registration.reg.account_key_pem}"
+  common_name       = "www33.${var.domain}"
+  trusted_roots_pem = <<EOT
+%sEOT
+  verify_chain      = "%s"
 
   recursive_nameservers        = ["%s"]
   disable_complete_propagation = true
@@ -1500,12 +2858,14 @@ resource "acme_certificate" "certificate" {
 		pebbleDirBasic,
 		pebbleCertDomain,
 		pebbleCertDomain,
+		getPebbleCertificatePEM(rootURL),
+		mode,
 		pebbleChallTestDNSSrv,
 		pebbleChallTestDNSScriptPath,
 	)
 }
 
-func testAccACMECertificateCSRConfigWithPreferredChain() string {
+func testAccACMECertificateConfigPrivateKey() string {
 	return fmt.Sprintf(`
 provider "acme" {
   server_url = "%s"
@@ -1524,25 +2884,18 @@ resource "acme_registration" "reg" {
 }
 
 resource "tls_private_key" "cert_private_key" {
-  algorithm = "RSA"
-}
-
-resource "tls_cert_request" "req" {
-  private_key_pem = "${tls_private_key.cert_private_key.private_key_pem}"
-  dns_names       = ["www3.${var.domain}", "www4.${var.domain}"]
-
-  subject {
-    common_name  = "www3.${var.domain}"
-  }
+  algorithm   = "ECDSA"
+  ecdsa_curve = "P256"
 }
 
 resource "acme_certificate" "certificate" {
-  account_key_pem         = "${acme_registration.reg.account_key_pem}"
-	certificate_request_pem = "${tls_cert_request.req.cert_request_pem}"
+  account_key_pem           = "${acme_registration.reg.account_key_pem}"
+  common_name               = "www18.${var.domain}"
+  subject_alternative_names = ["www19.${var.domain}"]
+  private_key_pem           = "${tls_private_key.cert_private_key.private_key_pem}"
 
   recursive_nameservers        = ["%s"]
-	disable_complete_propagation = true
-	preferred_chain = "%s"
+  disable_complete_propagation = true
 
   dns_challenge {
     provider = "exec"
@@ -1557,12 +2910,11 @@ resource "acme_certificate" "certificate" {
 		pebbleCertDomain,
 		pebbleCertDomain,
 		pebbleChallTestDNSSrv,
-		getPebbleCertificateIssuer(alternateIntermediateURL),
 		pebbleChallTestDNSScriptPath,
 	)
 }
 
-func testAccACMECertificateCSRConfigWithProfile() string {
+func testAccACMECertificateConfigPrivateKeyWriteOnly() string {
 	return fmt.Sprintf(`
 provider "acme" {
   server_url = "%s"
@@ -1582,18 +2934,15 @@ resource "acme_registration" "reg" {
 
 resource "tls_private_key" "cert_private_key" {
   algorithm = "RSA"
-}
-
-resource "tls_cert_request" "req" {
-  private_key_pem = "${tls_private_key.cert_private_key.private_key_pem}"
-  dns_names       = ["www3.${var.domain}"]
+  rsa_bits  = 2048
 }
 
 resource "acme_certificate" "certificate" {
-  account_key_pem         = "${acme_registration.reg.account_key_pem}"
-  certificate_request_pem = "${tls_cert_request.req.cert_request_pem}"
-  profile                 = "tfacmetest"
-  min_days_remaining      = 1
+  account_key_pem            = "${acme_registration.reg.account_key_pem}"
+  common_name                = "www20.${var.domain}"
+  subject_alternative_names  = ["www21.${var.domain}"]
+  private_key_pem_wo         = "${tls_private_key.cert_private_key.private_key_pem}"
+  private_key_pem_wo_version = 1
 
   recursive_nameservers        = ["%s"]
   disable_complete_propagation = true
@@ -1607,7 +2956,7 @@ resource "acme_certificate" "certificate" {
   }
 }
 `,
-		pebbleDirProfile,
+		pebbleDirBasic,
 		pebbleCertDomain,
 		pebbleCertDomain,
 		pebbleChallTestDNSSrv,
@@ -1615,7 +2964,7 @@ resource "acme_certificate" "certificate" {
 	)
 }
 
-func testAccACMECertificateForceRenewalConfig() string {
+func testAccACMECertificateConfigKeyRotation(commonName, policy string) string {
 	return fmt.Sprintf(`
 provider "acme" {
   server_url = "%s"
@@ -1635,8 +2984,9 @@ resource "acme_registration" "reg" {
 
 resource "acme_certificate" "certificate" {
   account_key_pem    = "${acme_registration.reg.account_key_pem}"
-  common_name        = "www6.${var.domain}"
+  common_name        = "%s.${var.domain}"
   min_days_remaining = 18250
+  key_rotation       = "%s"
 
   recursive_nameservers        = ["%s"]
   disable_complete_propagation = true
@@ -1653,12 +3003,14 @@ resource "acme_certificate" "certificate" {
 		pebbleDirBasic,
 		pebbleCertDomain,
 		pebbleCertDomain,
+		commonName,
+		policy,
 		pebbleChallTestDNSSrv,
 		pebbleChallTestDNSScriptPath,
 	)
 }
 
-func testAccACMECertificateWildcardConfig() string {
+func testAccACMECertificateConfigPrivateKeyEncrypted(omit bool) string {
 	return fmt.Sprintf(`
 provider "acme" {
   server_url = "%s"
@@ -1677,8 +3029,11 @@ resource "acme_registration" "reg" {
 }
 
 resource "acme_certificate" "certificate" {
-  account_key_pem = "${acme_registration.reg.account_key_pem}"
-  common_name     = "*.${var.domain}"
+  account_key_pem                = "${acme_registration.reg.account_key_pem}"
+  common_name                    = "www24.${var.domain}"
+  private_key_passphrase         = "correct horse battery staple"
+  private_key_passphrase_version = 1
+  omit_private_key_pem           = %t
 
   recursive_nameservers        = ["%s"]
   disable_complete_propagation = true
@@ -1695,12 +3050,13 @@ resource "acme_certificate" "certificate" {
 		pebbleDirBasic,
 		pebbleCertDomain,
 		pebbleCertDomain,
+		omit,
 		pebbleChallTestDNSSrv,
 		pebbleChallTestDNSScriptPath,
 	)
 }
 
-func testAccACMECertificateConfigP12Password(password string) string {
+func testAccACMECertificateConfigIDN() string {
 	return fmt.Sprintf(`
 provider "acme" {
   server_url = "%s"
@@ -1714,7 +3070,46 @@ variable "domain" {
   default = "%s"
 }
 
-variable "password" {
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem           = "${acme_registration.reg.account_key_pem}"
+  common_name               = "WWW26.${var.domain}."
+  subject_alternative_names = ["Bücher26.${var.domain}"]
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
+func testAccACMECertificateConfigKeyTypes() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
   default = "%s"
 }
 
@@ -1723,10 +3118,10 @@ resource "acme_registration" "reg" {
 }
 
 resource "acme_certificate" "certificate" {
-  account_key_pem           = "${acme_registration.reg.account_key_pem}"
-  common_name               = "www12.${var.domain}"
-  subject_alternative_names = ["www13.${var.domain}"]
-  certificate_p12_password  = "${var.password}"
+  account_key_pem    = "${acme_registration.reg.account_key_pem}"
+  common_name        = "www25.${var.domain}"
+  key_types          = ["P256", "2048"]
+  min_days_remaining = 18250
 
   recursive_nameservers        = ["%s"]
   disable_complete_propagation = true
@@ -1743,7 +3138,6 @@ resource "acme_certificate" "certificate" {
 		pebbleDirBasic,
 		pebbleCertDomain,
 		pebbleCertDomain,
-		password,
 		pebbleChallTestDNSSrv,
 		pebbleChallTestDNSScriptPath,
 	)
@@ -1882,7 +3276,7 @@ resource "acme_certificate" "certificate" {
 	)
 }
 
-func testAccACMECertificateConfigPreferredChain() string {
+func testAccACMECertificateConfigPreferredChain(intermediateURL string, refresh bool) string {
 	return fmt.Sprintf(`
 provider "acme" {
   server_url = "%s"
@@ -1908,6 +3302,7 @@ resource "acme_certificate" "certificate" {
   recursive_nameservers        = ["%s"]
   disable_complete_propagation = true
   preferred_chain = "%s"
+  refresh_chain   = %t
 
   dns_challenge {
     provider = "exec"
@@ -1922,7 +3317,8 @@ resource "acme_certificate" "certificate" {
 		pebbleCertDomain,
 		pebbleCertDomain,
 		pebbleChallTestDNSSrv,
-		getPebbleCertificateIssuer(alternateIntermediateURL),
+		getPebbleCertificateIssuer(intermediateURL),
+		refresh,
 		pebbleChallTestDNSScriptPath,
 	)
 }
@@ -1961,6 +3357,40 @@ resource "acme_certificate" "certificate" {
 	)
 }
 
+func testAccACMECertificateConfigHTTPIPAddress() string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address   = "${var.email_address}"
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem = "${acme_registration.reg.account_key_pem}"
+  common_name     = "test-http-ip.${var.domain}"
+  ip_addresses    = ["127.0.0.1"]
+
+  http_challenge {
+    port = 5002
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+	)
+}
+
 func testAccACMECertificateConfigHTTPWebroot(dir string) string {
 	return fmt.Sprintf(`
 provider "acme" {
@@ -2353,6 +3783,52 @@ resource "acme_certificate" "certificate" {
 	)
 }
 
+func testAccACMECertificateConfigValidity(validity, renewBefore string) string {
+	return fmt.Sprintf(`
+provider "acme" {
+  server_url = "%s"
+}
+
+variable "email_address" {
+  default = "nobody@%s"
+}
+
+variable "domain" {
+  default = "%s"
+}
+
+resource "acme_registration" "reg" {
+  email_address = var.email_address
+}
+
+resource "acme_certificate" "certificate" {
+  account_key_pem = acme_registration.reg.account_key_pem
+  common_name     = "www-vd3.${var.domain}"
+  validity        = "%s"
+  renew_before    = "%s"
+
+  recursive_nameservers        = ["%s"]
+  disable_complete_propagation = true
+
+  dns_challenge {
+    provider = "exec"
+    config = {
+      EXEC_PATH              = "%s"
+      EXEC_SEQUENCE_INTERVAL = "5"
+    }
+  }
+}
+`,
+		pebbleDirBasic,
+		pebbleCertDomain,
+		pebbleCertDomain,
+		validity,
+		renewBefore,
+		pebbleChallTestDNSSrv,
+		pebbleChallTestDNSScriptPath,
+	)
+}
+
 func testAccACMECertificateConfigRenewalInfo(enabled bool, maxSleep int, ignoreRetry bool) string {
 	return fmt.Sprintf(`
 provider "acme" {
@@ -33,8 +33,22 @@ func resourceACMERegistrationV2() *schema.Resource {
 					"account_key_algorithm",
 					"account_key_ecdsa_curve",
 					"account_key_rsa_bits",
+					"account_key_pkcs11_uri",
 				},
 			},
+			"account_key_pkcs11_uri": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				ForceNew:  true,
+				Sensitive: true,
+				ConflictsWith: []string{
+					"account_key_pem",
+					"account_key_algorithm",
+					"account_key_ecdsa_curve",
+					"account_key_rsa_bits",
+				},
+				ValidateFunc: validatePKCS11URI,
+			},
 			// https://letsencrypt.org/docs/integration-guide/#supported-key-algorithms
 			// NOTE: Our internal functions support more, but we need to restrict to
 			// what's listed here for Let's Encrypt Specifically. This also applies
@@ -75,10 +89,11 @@ func resourceACMERegistrationV2() *schema.Resource {
 				ForceNew: true,
 			},
 			"external_account_binding": {
-				Type:     schema.TypeList,
-				Optional: true,
-				MaxItems: 1,
-				ForceNew: true,
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ForceNew:      true,
+				ConflictsWith: []string{"eab_provisioning"},
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"key_id": {
@@ -96,6 +111,57 @@ func resourceACMERegistrationV2() *schema.Resource {
 					},
 				},
 			},
+			"eab_provisioning": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ForceNew:      true,
+				ConflictsWith: []string{"external_account_binding"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"type": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+							ValidateFunc: validation.StringInSlice(
+								[]string{eabProvisioningTypeZeroSSL, eabProvisioningTypeHTTP},
+								false,
+							),
+						},
+						"url": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+						},
+						"api_key": {
+							Type:      schema.TypeString,
+							Optional:  true,
+							Sensitive: true,
+							ForceNew:  true,
+						},
+						"headers": {
+							Type:      schema.TypeMap,
+							Optional:  true,
+							Sensitive: true,
+							ForceNew:  true,
+							Elem:      &schema.Schema{Type: schema.TypeString},
+						},
+						"key_id_field": {
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: true,
+							Default:  eabProvisioningHTTPDefaultKeyIDField,
+						},
+						"hmac_field": {
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: true,
+							Default:  eabProvisioningHTTPDefaultHMACField,
+						},
+					},
+				},
+			},
 			"registration_url": {
 				Type:     schema.TypeString,
 				Computed: true,
@@ -105,8 +171,9 @@ func resourceACMERegistrationV2() *schema.Resource {
 }
 
 func resourceACMERegistrationCreate(d *schema.ResourceData, meta any) error {
-	// If we do not have a private key, create one.
-	if d.Get("account_key_pem").(string) == "" {
+	// If we do not have a private key, create one, unless the key is held in a
+	// PKCS#11 token.
+	if d.Get("account_key_pem").(string) == "" && d.Get("account_key_pkcs11_uri").(string) == "" {
 		privateKeyPem, err := generatePrivateKey(
 			d.Get("account_key_algorithm").(string),
 			d.Get("account_key_rsa_bits").(int),
@@ -133,6 +200,20 @@ func resourceACMERegistrationCreate(d *schema.ResourceData, meta any) error {
 			Kid:                  v.([]any)[0].(map[string]any)["key_id"].(string),
 			HmacEncoded:          v.([]any)[0].(map[string]any)["hmac_base64"].(string),
 		})
+	} else if v, ok := d.GetOk("eab_provisioning"); ok {
+		// EAB provisioning enabled, fetch credentials first and then register
+		// with them.
+		var eab *eabCredentials
+		eab, err = provisionEABCredentials(v.([]any)[0].(map[string]any), d.Get("email_address").(string))
+		if err != nil {
+			return err
+		}
+
+		reg, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+			TermsOfServiceAgreed: true,
+			Kid:                  eab.KeyID,
+			HmacEncoded:          eab.HMACEncoded,
+		})
 	} else {
 		// Normal registration.
 		reg, err = client.Registration.Register(registration.RegisterOptions{
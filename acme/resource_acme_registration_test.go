@@ -276,6 +276,7 @@ func testAccCheckACMERegistrationResourceData(rs *terraform.ResourceState) *sche
 
 	d.SetId(rs.Primary.ID)
 	d.Set("account_key_pem", rs.Primary.Attributes["account_key_pem"])
+	d.Set("account_key_pkcs11_uri", rs.Primary.Attributes["account_key_pkcs11_uri"])
 	d.Set("email_address", rs.Primary.Attributes["email_address"])
 
 	return d
@@ -0,0 +1,65 @@
+// generate-ct-log-list fetches the CT log list published by Google, and
+// writes it to the file that is embedded in the provider as the default log
+// list for require_scts in acme_certificate.
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// The CT log list, in the v3 log list format.
+const logListURL = "https://www.gstatic.com/ct/log_list/v3/log_list.json"
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: generate-ct-log-list PATH")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(logListURL)
+	if err != nil {
+		log.Fatalf("error fetching CT log list: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("error fetching CT log list: unexpected response status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatalf("error reading CT log list: %s", err)
+	}
+
+	// Check that the list looks like a v3 log list before replacing the
+	// embedded list with it. The keys are parsed by the provider's tests.
+	var list struct {
+		Operators []struct {
+			Logs      []json.RawMessage `json:"logs"`
+			TiledLogs []json.RawMessage `json:"tiled_logs"`
+		} `json:"operators"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		log.Fatalf("error parsing CT log list: %s", err)
+	}
+
+	var logs int
+	for _, operator := range list.Operators {
+		logs += len(operator.Logs) + len(operator.TiledLogs)
+	}
+
+	if logs == 0 {
+		log.Fatal("error parsing CT log list: the list has no logs")
+	}
+
+	if err := os.WriteFile(os.Args[1], body, 0644); err != nil {
+		log.Fatalf("error writing CT log list: %s", err)
+	}
+
+	log.Printf("wrote %d logs from %d operators to %s", logs, len(list.Operators), os.Args[1])
+}
@@ -0,0 +1,14 @@
+#!/usr/bin/env bash
+
+case "$(uname)" in
+  "Darwin")
+    brew list softhsm > /dev/null 2>&1 || brew install softhsm
+    ;;
+
+  *)
+    # Assuming Ubuntu as that's what our CI runs on. YMMV here, might
+    # need to expand this into a separate function for distribution
+    # detection if need be.
+    sudo apt-get update && sudo apt-get -y install softhsm2
+    ;;
+esac